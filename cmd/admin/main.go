@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/config"
+	"github.com/ZigaoWang/zebra-server/internal/database"
+	"github.com/ZigaoWang/zebra-server/internal/digest"
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/gc"
+	"github.com/ZigaoWang/zebra-server/internal/mail"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+	"github.com/ZigaoWang/zebra-server/internal/repository/postgres"
+	"github.com/ZigaoWang/zebra-server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+)
+
+// command is a maintenance task run against the configured database
+type command struct {
+	usage string
+	run   func(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error
+}
+
+var commands = map[string]command{
+	"recompute-usage": {
+		usage: "rebuild per-user storage usage from stored files and audio",
+		run:   recomputeUsage,
+	},
+	"gc": {
+		usage: "report orphaned data and unreferenced blobs; -delete removes them",
+		run:   collectGarbage,
+	},
+	"purge-trash": {
+		usage: "permanently delete items past the trash retention period",
+		run:   purgeTrash,
+	},
+	"send-digests": {
+		usage: "send the weekly digests that are due; -at sends those due at another time",
+		run:   sendDigests,
+	},
+	"convert-work-logs": {
+		usage: "turn completed work logs that belong to a project into sessions",
+		run:   convertWorkLogs,
+	},
+}
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found")
+	}
+
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+
+	// Initialize configuration
+	cfg := config.New()
+
+	// Initialize database
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		log.Printf("Database initialization error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cmd.run(context.Background(), cfg, db, os.Args[2:]); err != nil {
+		log.Printf("%s failed: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: admin <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for name, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, cmd.usage)
+	}
+}
+
+func recomputeUsage(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	usages, err := postgres.NewStorageUsageRepository(db).Recompute(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, usage := range usages {
+		fmt.Printf("%s\tfiles=%d\taudio=%d\ttotal=%d\n", usage.UserID, usage.FileBytes, usage.AudioBytes, usage.Total())
+	}
+	fmt.Printf("Recomputed storage usage for %d users\n", len(usages))
+	return nil
+}
+
+func collectGarbage(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	remove := flags.Bool("delete", false, "delete what is found instead of only reporting it")
+	grace := flags.Duration("grace", time.Duration(cfg.GC.GraceMinutes)*time.Minute, "keep unreferenced content younger than this")
+	asJSON := flags.Bool("json", false, "print the full report as JSON")
+	flags.Parse(args)
+
+	store, err := storage.New(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	collector := gc.NewCollector(
+		postgres.NewMaintenanceRepository(db),
+		postgres.NewStorageUsageRepository(db),
+		store,
+		*grace,
+	)
+	report, err := collector.Run(ctx, *remove)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	printIDs := func(label string, ids []string) {
+		for _, id := range ids {
+			fmt.Printf("%s\t%s\n", label, id)
+		}
+	}
+	printIDs("session", uuidStrings(report.OrphanedSessions))
+	printIDs("record", uuidStrings(report.OrphanedRecords))
+	printIDs("file", uuidStrings(report.OrphanedFiles))
+	printIDs("thumbnail", uuidStrings(report.OrphanedThumbnails))
+	for _, m := range report.RefCountMismatches {
+		fmt.Printf("ref-count\t%s\t%d -> %d\n", m.SHA256, m.RefCount, m.Actual)
+	}
+	printIDs("blob", report.UnreferencedBlobs)
+	printIDs("object", report.UntrackedObjects)
+	printIDs("missing-row", report.MissingBlobRows)
+	printIDs("missing-content", report.MissingBlobContent)
+	fmt.Println(report.Summary())
+	return nil
+}
+
+func sendDigests(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("send-digests", flag.ExitOnError)
+	at := flags.String("at", "", "RFC 3339 time to send the digests due at, instead of now")
+	flags.Parse(args)
+
+	now := time.Now()
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("-at must be an RFC 3339 time: %w", err)
+		}
+		now = t
+	}
+
+	mailer, err := mail.New(cfg)
+	if err != nil {
+		return err
+	}
+	if mailer == nil {
+		return errors.New("MAIL_DRIVER is not set; use file to write messages to MAIL_PATH")
+	}
+
+	scheduler := digest.NewScheduler(
+		postgres.NewUserRepository(db),
+		digest.NewBuilder(postgres.NewReportRepository(db), postgres.NewGoalRepository(db)),
+		mailer,
+		cfg.Mail.From,
+		cfg.Server.PublicURL,
+	)
+	sent, err := scheduler.Run(ctx, now)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Sent %d digests\n", sent)
+	return nil
+}
+
+func purgeTrash(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("purge-trash", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only count what would be purged")
+	retention := flags.Duration("retention", cfg.Trash.Retention(), "purge items deleted longer ago than this")
+	flags.Parse(args)
+
+	if *retention <= 0 {
+		return errors.New("retention must be positive; TRASH_RETENTION_DAYS=0 keeps the trash forever")
+	}
+
+	store, err := storage.New(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	collector := gc.NewCollector(
+		postgres.NewMaintenanceRepository(db),
+		postgres.NewStorageUsageRepository(db),
+		store,
+		time.Duration(cfg.GC.GraceMinutes)*time.Minute,
+	)
+	counts, err := collector.PurgeTrash(ctx, *retention, !*dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Purged"
+	if *dryRun {
+		verb = "Would purge"
+	}
+	fmt.Printf("%s %d projects, %d sessions, %d records and %d files\n",
+		verb, counts.Projects, counts.Sessions, counts.Records, counts.Files)
+	return nil
+}
+
+func convertWorkLogs(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("convert-work-logs", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the work logs that would be converted")
+	flags.Parse(args)
+
+	workLogRepo := postgres.NewWorkLogRepository(db)
+	logEntryRepo := postgres.NewLogEntryRepository(db)
+	revisionRepo := postgres.NewRevisionRepository(db)
+	transactor := postgres.NewTransactor(db)
+
+	workLogs, err := workLogRepo.GetConvertible(ctx)
+	if err != nil {
+		return err
+	}
+
+	converted := 0
+	for i := range workLogs {
+		workLog := &workLogs[i]
+		if *dryRun {
+			fmt.Printf("%s\tproject=%s\t%s\n", workLog.ID, workLog.ProjectID, workLog.Title)
+			continue
+		}
+
+		entries, _, err := logEntryRepo.GetByWorkLogID(ctx, workLog.ID, "", 0, 0)
+		if err != nil {
+			return err
+		}
+		session := workLog.ToSession(entries)
+		// The session and its revisions are saved together or not at all
+		err = transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := workLogRepo.ConvertToSession(ctx, workLog, &session); err != nil {
+				return err
+			}
+
+			// Conversions run by an admin are recorded without an actor
+			revisions := []*domain.Revision{
+				domain.NewRevision(domain.EntitySession, session.ID, domain.RevisionCreate, nil, nil, session.Snapshot()),
+			}
+			for j := range session.Records {
+				record := &session.Records[j]
+				revisions = append(revisions, domain.NewRevision(domain.EntityRecord, record.ID, domain.RevisionCreate, nil, nil, record.Snapshot()))
+			}
+			for _, revision := range revisions {
+				if err := revisionRepo.Create(ctx, revision); err != nil {
+					return fmt.Errorf("save revision of %s %s: %w", revision.EntityType, revision.EntityID, err)
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, repository.ErrAlreadyConverted) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("work log %s: %w", workLog.ID, err)
+		}
+		fmt.Printf("%s\tsession=%s\trecords=%d\n", workLog.ID, session.ID, len(session.Records))
+		converted++
+	}
+
+	if *dryRun {
+		fmt.Printf("%d work logs can be converted\n", len(workLogs))
+	} else {
+		fmt.Printf("Converted %d work logs into sessions\n", converted)
+	}
+	return nil
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ZigaoWang/zebra-server/internal/config"
 	"github.com/ZigaoWang/zebra-server/internal/database"
+	"github.com/ZigaoWang/zebra-server/internal/digest"
+	"github.com/ZigaoWang/zebra-server/internal/gc"
+	"github.com/ZigaoWang/zebra-server/internal/mail"
+	"github.com/ZigaoWang/zebra-server/internal/repository/postgres"
 	"github.com/ZigaoWang/zebra-server/internal/server"
+	"github.com/ZigaoWang/zebra-server/internal/storage"
+	"github.com/ZigaoWang/zebra-server/internal/transcribe"
 	"github.com/joho/godotenv"
 )
 
@@ -26,8 +34,57 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Initialize file store
+	fileStore, err := storage.New(cfg, db)
+	if err != nil {
+		log.Printf("Storage initialization error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Initialize speech-to-text, which is optional
+	transcriber, err := transcribe.New(cfg)
+	if err != nil {
+		log.Printf("Transcription initialization error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Collect orphaned data and purge expired trash in the background
+	collector := gc.NewCollector(
+		postgres.NewMaintenanceRepository(db),
+		postgres.NewStorageUsageRepository(db),
+		fileStore,
+		time.Duration(cfg.GC.GraceMinutes)*time.Minute,
+	)
+	if cfg.GC.IntervalMinutes > 0 {
+		go collector.RunEvery(context.Background(), time.Duration(cfg.GC.IntervalMinutes)*time.Minute)
+	}
+	if cfg.Trash.RetentionDays > 0 && cfg.Trash.PurgeIntervalMinutes > 0 {
+		go collector.PurgeTrashEvery(context.Background(), cfg.Trash.Retention(),
+			time.Duration(cfg.Trash.PurgeIntervalMinutes)*time.Minute)
+	}
+
+	// Send weekly digests in the background when mail is configured
+	mailer, err := mail.New(cfg)
+	if err != nil {
+		log.Printf("Mail initialization error: %v\n", err)
+		os.Exit(1)
+	}
+	if mailer != nil && cfg.Digest.IntervalMinutes > 0 {
+		if cfg.Server.PublicURL == "" {
+			log.Printf("Warning: SERVER_PUBLIC_URL is not set, so digest unsubscribe links are relative")
+		}
+		scheduler := digest.NewScheduler(
+			postgres.NewUserRepository(db),
+			digest.NewBuilder(postgres.NewReportRepository(db), postgres.NewGoalRepository(db)),
+			mailer,
+			cfg.Mail.From,
+			cfg.Server.PublicURL,
+		)
+		go scheduler.RunEvery(context.Background(), time.Duration(cfg.Digest.IntervalMinutes)*time.Minute)
+	}
+
 	// Create and start server
-	srv := server.NewServer(cfg, db)
+	srv := server.NewServer(cfg, db, fileStore, transcriber)
 	if err := srv.Run(); err != nil {
 		log.Printf("Server error: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Supported container formats
+const (
+	FormatMP3  = "mp3"
+	FormatWAV  = "wav"
+	FormatOgg  = "ogg"
+	FormatWebM = "webm"
+	FormatM4A  = "m4a"
+)
+
+// ErrUnsupportedFormat is returned for data in an unknown container
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// ErrMalformed is returned when a known container cannot be parsed
+var ErrMalformed = errors.New("malformed audio data")
+
+// Info describes an audio recording
+type Info struct {
+	Format     string // container, one of the Format constants
+	Codec      string // mp3, pcm, opus, vorbis, aac, ...
+	DurationMs int64
+	SampleRate int
+	Channels   int
+
+	// pcm is set for uncompressed WAV data so peaks can be computed natively
+	pcm *pcmData
+}
+
+// Detect sniffs the container format from the leading magic bytes
+func Detect(data []byte) string {
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return FormatWAV
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return FormatOgg
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return FormatWebM
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return FormatM4A
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return FormatMP3
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return FormatMP3
+	}
+	return ""
+}
+
+// ContentType returns the MIME type used to serve a container format
+func ContentType(format string) string {
+	switch format {
+	case FormatWAV:
+		return "audio/wav"
+	case FormatOgg:
+		return "audio/ogg"
+	case FormatWebM:
+		return "audio/webm"
+	case FormatM4A:
+		return "audio/mp4"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// Extension returns the file extension used for a container format
+func Extension(format string) string {
+	if format == "" {
+		return FormatMP3
+	}
+	return format
+}
+
+// Analyze detects the container of an audio recording and reads its
+// duration, sample rate and channel count from the stream headers. Data
+// that trips up a parser is reported as malformed.
+func Analyze(data []byte) (info *Info, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			info, err = nil, ErrMalformed
+		}
+	}()
+
+	switch Detect(data) {
+	case FormatMP3:
+		return parseMP3(data)
+	case FormatWAV:
+		return parseWAV(data)
+	case FormatOgg:
+		return parseOgg(data)
+	case FormatWebM:
+		return parseWebM(data)
+	case FormatM4A:
+		return parseMP4(data)
+	}
+	return nil, ErrUnsupportedFormat
+}
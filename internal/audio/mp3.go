@@ -0,0 +1,130 @@
+package audio
+
+// MPEG audio versions as encoded in the frame header
+const (
+	mpeg25 = 0
+	mpeg2  = 2
+	mpeg1  = 3
+)
+
+var mp3Bitrates = map[[2]int][15]int{
+	{mpeg1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	{mpeg1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	{mpeg1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{mpeg2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	{mpeg2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	{mpeg2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+var mp3SampleRates = map[int][3]int{
+	mpeg1:  {44100, 48000, 32000},
+	mpeg2:  {22050, 24000, 16000},
+	mpeg25: {11025, 12000, 8000},
+}
+
+type mp3Frame struct {
+	length     int
+	samples    int
+	sampleRate int
+	channels   int
+}
+
+// parseMP3Frame decodes the 4 byte header at the start of b
+func parseMP3Frame(b []byte) (mp3Frame, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mp3Frame{}, false
+	}
+
+	version := int(b[1]>>3) & 0x03
+	layer := 4 - int(b[1]>>1)&0x03
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int(b[2]>>2) & 0x03
+	padding := int(b[2]>>1) & 0x01
+	mode := int(b[3] >> 6)
+
+	if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	tableVersion := version
+	if version == mpeg25 {
+		tableVersion = mpeg2
+	}
+	bitrate := mp3Bitrates[[2]int{tableVersion, layer}][bitrateIndex] * 1000
+	sampleRate := mp3SampleRates[version][rateIndex]
+
+	frame := mp3Frame{sampleRate: sampleRate, channels: 2}
+	if mode == 3 {
+		frame.channels = 1
+	}
+
+	switch {
+	case layer == 1:
+		frame.samples = 384
+		frame.length = (12*bitrate/sampleRate + padding) * 4
+	case layer == 3 && version != mpeg1:
+		frame.samples = 576
+		frame.length = 72*bitrate/sampleRate + padding
+	default:
+		frame.samples = 1152
+		frame.length = 144*bitrate/sampleRate + padding
+	}
+	if frame.length < 4 {
+		return mp3Frame{}, false
+	}
+	return frame, true
+}
+
+// parseMP3 walks every frame so that VBR files report an exact duration
+func parseMP3(data []byte) (*Info, error) {
+	pos := 0
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		size := int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+		pos = 10 + size
+		if data[5]&0x10 != 0 {
+			pos += 10
+		}
+	}
+
+	// Find the first frame, confirming it by the header of the next one
+	const maxScan = 64 * 1024
+	var first mp3Frame
+	found := false
+	for limit := pos + maxScan; pos+4 <= len(data) && pos < limit; pos++ {
+		frame, ok := parseMP3Frame(data[pos:])
+		if !ok {
+			continue
+		}
+		next := pos + frame.length
+		if next >= len(data) {
+			first, found = frame, true
+			break
+		}
+		if _, ok := parseMP3Frame(data[next:]); ok {
+			first, found = frame, true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrMalformed
+	}
+
+	var samples int64
+	for pos+4 <= len(data) {
+		frame, ok := parseMP3Frame(data[pos:])
+		if !ok || frame.sampleRate != first.sampleRate {
+			// Trailing ID3v1 or APE tags end the stream
+			break
+		}
+		samples += int64(frame.samples)
+		pos += frame.length
+	}
+
+	return &Info{
+		Format:     FormatMP3,
+		Codec:      "mp3",
+		DurationMs: samples * 1000 / int64(first.sampleRate),
+		SampleRate: first.sampleRate,
+		Channels:   first.channels,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package audio
+
+import "encoding/binary"
+
+// maxMP4Depth bounds how deep boxes are nested. The sound track's sample
+// description is five boxes down.
+const maxMP4Depth = 8
+
+// mp4Containers are the boxes descended into to find the sound track
+var mp4Containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+}
+
+type mp4Track struct {
+	handler    string
+	timescale  uint32
+	duration   uint64
+	codec      string
+	channels   int
+	sampleRate int
+}
+
+// parseMP4 reads the first sound track of an MP4/M4A file
+func parseMP4(data []byte) (*Info, error) {
+	var tracks []*mp4Track
+	var current *mp4Track
+
+	var walk func(data []byte, depth int)
+	walk = func(data []byte, depth int) {
+		if depth > maxMP4Depth {
+			return
+		}
+		pos := 0
+		for pos+8 <= len(data) {
+			size := uint64(binary.BigEndian.Uint32(data[pos:]))
+			kind := string(data[pos+4 : pos+8])
+			header := 8
+			switch size {
+			case 0:
+				size = uint64(len(data) - pos)
+			case 1:
+				if pos+16 > len(data) {
+					return
+				}
+				size = binary.BigEndian.Uint64(data[pos+8:])
+				header = 16
+			}
+			if size < uint64(header) || size > uint64(len(data)-pos) {
+				return
+			}
+			body := data[pos+header : pos+int(size)]
+
+			switch {
+			case kind == "trak":
+				current = &mp4Track{}
+				tracks = append(tracks, current)
+				walk(body, depth+1)
+			case mp4Containers[kind]:
+				walk(body, depth+1)
+			case current == nil:
+			case kind == "mdhd":
+				parseMDHD(body, current)
+			case kind == "hdlr" && len(body) >= 12:
+				current.handler = string(body[8:12])
+			case kind == "stsd":
+				parseSTSD(body, current)
+			}
+
+			pos += int(size)
+		}
+	}
+	walk(data, 0)
+
+	for _, track := range tracks {
+		if track.handler != "soun" || track.timescale == 0 {
+			continue
+		}
+		return &Info{
+			Format:     FormatM4A,
+			Codec:      track.codec,
+			DurationMs: int64(track.duration * 1000 / uint64(track.timescale)),
+			SampleRate: track.sampleRate,
+			Channels:   track.channels,
+		}, nil
+	}
+	return nil, ErrMalformed
+}
+
+func parseMDHD(body []byte, track *mp4Track) {
+	if len(body) < 1 {
+		return
+	}
+	if body[0] == 1 {
+		if len(body) >= 32 {
+			track.timescale = binary.BigEndian.Uint32(body[20:])
+			track.duration = binary.BigEndian.Uint64(body[24:])
+		}
+		return
+	}
+	if len(body) >= 20 {
+		track.timescale = binary.BigEndian.Uint32(body[12:])
+		track.duration = uint64(binary.BigEndian.Uint32(body[16:]))
+	}
+}
+
+// parseSTSD reads the first audio sample entry of a sample description box
+func parseSTSD(body []byte, track *mp4Track) {
+	// version/flags and entry count precede the entries
+	if len(body) < 8+36 {
+		return
+	}
+	entry := body[8:]
+	switch string(entry[4:8]) {
+	case "mp4a":
+		track.codec = "aac"
+	case "alac":
+		track.codec = "alac"
+	case "Opus":
+		track.codec = "opus"
+	case "fLaC":
+		track.codec = "flac"
+	default:
+		track.codec = string(entry[4:8])
+	}
+	track.channels = int(binary.BigEndian.Uint16(entry[24:]))
+	track.sampleRate = int(binary.BigEndian.Uint32(entry[32:]) >> 16)
+}
@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// box encodes an MP4 box with a 32-bit size
+func box(kind string, body ...[]byte) []byte {
+	size := 8
+	for _, b := range body {
+		size += len(b)
+	}
+	out := binary.BigEndian.AppendUint32(nil, uint32(size))
+	out = append(out, kind...)
+	for _, b := range body {
+		out = append(out, b...)
+	}
+	return out
+}
+
+// largeBox encodes a box header with a 64-bit size, which need not match
+// the data that follows
+func largeBox(kind string, size uint64) []byte {
+	out := binary.BigEndian.AppendUint32(nil, 1)
+	out = append(out, kind...)
+	return binary.BigEndian.AppendUint64(out, size)
+}
+
+// m4a builds a minimal file with one AAC sound track of 2.5 seconds
+func m4a() []byte {
+	mdhd := make([]byte, 20)
+	binary.BigEndian.PutUint32(mdhd[12:], 1000) // timescale
+	binary.BigEndian.PutUint32(mdhd[16:], 2500) // duration
+	hdlr := append(make([]byte, 8), "soun"...)
+	hdlr = append(hdlr, make([]byte, 12)...)
+
+	entry := make([]byte, 36)
+	copy(entry[4:8], "mp4a")
+	binary.BigEndian.PutUint16(entry[24:], 2)
+	binary.BigEndian.PutUint32(entry[32:], 44100<<16)
+	stsd := append(make([]byte, 8), entry...)
+
+	track := box("trak", box("mdia",
+		box("mdhd", mdhd),
+		box("hdlr", hdlr),
+		box("minf", box("stbl", box("stsd", stsd))),
+	))
+	return append(box("ftyp", []byte("M4A \x00\x00\x00\x00")), box("moov", track)...)
+}
+
+func TestParseMP4(t *testing.T) {
+	valid := m4a()
+	ftyp := valid[:16]
+
+	nested := box("stsd")
+	for i := 0; i < 1000; i++ {
+		nested = box("moov", nested)
+	}
+
+	zeroSized := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(zeroSized[16:], 0) // moov runs to the end
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"zero size runs to the end", zeroSized, false},
+		{"truncated", valid[:len(valid)-30], true},
+		{"truncated header", valid[:20], true},
+		{"huge 64-bit size", append(append([]byte{}, ftyp...), largeBox("moov", 1<<64-8)...), true},
+		{"64-bit size below its header", append(append([]byte{}, ftyp...), largeBox("moov", 8)...), true},
+		{"32-bit size past the end", append(append([]byte{}, ftyp...), 0xFF, 0xFF, 0xFF, 0xF0, 'm', 'o', 'o', 'v'), true},
+		{"deeply nested", append(append([]byte{}, ftyp...), nested...), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseMP4(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("err = %v, want ErrMalformed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if info.DurationMs != 2500 || info.Codec != "aac" || info.Channels != 2 || info.SampleRate != 44100 {
+				t.Fatalf("info = %+v", info)
+			}
+		})
+	}
+}
+
+func FuzzParseMP4(f *testing.F) {
+	f.Add(m4a())
+	f.Add(append(m4a()[:16], largeBox("moov", 1<<64-8)...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseMP4(data)
+	})
+}
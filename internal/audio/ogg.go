@@ -0,0 +1,80 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// parseOgg reads the codec header from the first page and the duration
+// from the granule position of the last page of the first logical stream
+func parseOgg(data []byte) (*Info, error) {
+	info := &Info{Format: FormatOgg}
+	var serial uint32
+	var preSkip, granule int64
+	granuleRate := 0
+
+	pos := 0
+	for first := true; pos+27 <= len(data); first = false {
+		if string(data[pos:pos+4]) != "OggS" {
+			break
+		}
+		pageGranule := int64(binary.LittleEndian.Uint64(data[pos+6:]))
+		pageSerial := binary.LittleEndian.Uint32(data[pos+14:])
+		segments := int(data[pos+26])
+		if pos+27+segments > len(data) {
+			break
+		}
+		bodySize := 0
+		for _, s := range data[pos+27 : pos+27+segments] {
+			bodySize += int(s)
+		}
+		body := pos + 27 + segments
+		end := body + bodySize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		if first {
+			serial = pageSerial
+			packet := data[body:end]
+			switch {
+			case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 16:
+				info.Codec = "opus"
+				info.Channels = int(packet[9])
+				preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+				info.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+				// Opus granule positions always count 48 kHz samples
+				granuleRate = 48000
+				if info.SampleRate == 0 {
+					info.SampleRate = 48000
+				}
+			case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+				info.Codec = "vorbis"
+				info.Channels = int(packet[11])
+				info.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+				granuleRate = info.SampleRate
+			case bytes.HasPrefix(packet, []byte("\x7fFLAC")) && len(packet) >= 30:
+				info.Codec = "flac"
+				// The STREAMINFO block follows the 13 byte mapping header
+				streamInfo := packet[17:]
+				info.SampleRate = int(streamInfo[10])<<12 | int(streamInfo[11])<<4 | int(streamInfo[12])>>4
+				info.Channels = int(streamInfo[12]>>1&0x07) + 1
+				granuleRate = info.SampleRate
+			default:
+				return nil, ErrUnsupportedFormat
+			}
+		} else if pageSerial == serial && pageGranule > 0 {
+			granule = pageGranule
+		}
+
+		pos = end
+	}
+
+	if granuleRate == 0 {
+		return nil, ErrMalformed
+	}
+	if granule > preSkip {
+		info.DurationMs = (granule - preSkip) * 1000 / int64(granuleRate)
+	}
+	return info, nil
+}
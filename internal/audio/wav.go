@@ -0,0 +1,76 @@
+package audio
+
+import "encoding/binary"
+
+// pcmData points at the raw samples of an uncompressed WAV file
+type pcmData struct {
+	samples       []byte
+	bitsPerSample int
+	float         bool
+}
+
+func parseWAV(data []byte) (*Info, error) {
+	info := &Info{Format: FormatWAV}
+	var byteRate, blockAlign int
+	var pcm *pcmData
+	formatTag := 0
+
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		body := pos + 8
+		end := body + size
+		// Streamed recordings may leave the data size unset
+		if end > len(data) || end < body {
+			end = len(data)
+		}
+
+		switch id {
+		case "fmt ":
+			if end-body < 16 {
+				return nil, ErrMalformed
+			}
+			formatTag = int(binary.LittleEndian.Uint16(data[body:]))
+			info.Channels = int(binary.LittleEndian.Uint16(data[body+2:]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(data[body+4:]))
+			byteRate = int(binary.LittleEndian.Uint32(data[body+8:]))
+			blockAlign = int(binary.LittleEndian.Uint16(data[body+12:]))
+			bits := int(binary.LittleEndian.Uint16(data[body+14:]))
+			// WAVE_FORMAT_EXTENSIBLE keeps the real format in the sub-format GUID
+			if formatTag == 0xFFFE && end-body >= 26 {
+				formatTag = int(binary.LittleEndian.Uint16(data[body+24:]))
+			}
+			pcm = &pcmData{bitsPerSample: bits, float: formatTag == 3}
+		case "data":
+			if pcm == nil || byteRate == 0 {
+				return nil, ErrMalformed
+			}
+			samples := data[body:end]
+			if blockAlign > 0 {
+				samples = samples[:len(samples)/blockAlign*blockAlign]
+			}
+			pcm.samples = samples
+			info.DurationMs = int64(len(samples)) * 1000 / int64(byteRate)
+		}
+
+		pos = end + size%2
+	}
+
+	if pcm == nil || pcm.samples == nil {
+		return nil, ErrMalformed
+	}
+
+	switch formatTag {
+	case 1, 3:
+		info.Codec = "pcm"
+		info.pcm = pcm
+	case 6:
+		info.Codec = "alaw"
+	case 7:
+		info.Codec = "mulaw"
+	default:
+		info.Codec = "unknown"
+	}
+	return info, nil
+}
@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os/exec"
+)
+
+// PeakCount is the number of waveform peaks computed per recording
+const PeakCount = 1000
+
+// decodeSampleRate is the rate compressed audio is decoded at for peaks
+const decodeSampleRate = 8000
+
+// Peaks computes downsampled waveform peaks scaled to 0-255. Uncompressed
+// WAV data is read directly; other formats are decoded with ffmpeg when
+// ffmpegPath is set. It returns nil when no decoder is available.
+func Peaks(ctx context.Context, data []byte, info *Info, ffmpegPath string) ([]byte, error) {
+	if info.pcm != nil {
+		return pcmPeaks(info.pcm, info.Channels), nil
+	}
+	if ffmpegPath == "" {
+		return nil, nil
+	}
+	if _, err := exec.LookPath(ffmpegPath); err != nil {
+		return nil, nil
+	}
+
+	// Decode to mono 16-bit PCM at a low rate, which is plenty for peaks
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-ac", "1", "-ar", fmt.Sprint(decodeSampleRate),
+		"-f", "s16le", "pipe:1",
+	)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %v: %s", err, stderr.String())
+	}
+
+	return pcmPeaks(&pcmData{samples: stdout.Bytes(), bitsPerSample: 16}, 1), nil
+}
+
+// pcmPeaks splits interleaved PCM into PeakCount buckets and returns the
+// largest absolute amplitude of each bucket across all channels
+func pcmPeaks(pcm *pcmData, channels int) []byte {
+	sampleSize := pcm.bitsPerSample / 8
+	if sampleSize == 0 || channels == 0 {
+		return nil
+	}
+	frameSize := sampleSize * channels
+	frames := len(pcm.samples) / frameSize
+	if frames == 0 {
+		return nil
+	}
+
+	buckets := PeakCount
+	if frames < buckets {
+		buckets = frames
+	}
+	peaks := make([]byte, buckets)
+
+	for i := 0; i < buckets; i++ {
+		start := i * frames / buckets
+		end := (i + 1) * frames / buckets
+		peak := 0.0
+		for f := start; f < end; f++ {
+			for ch := 0; ch < channels; ch++ {
+				offset := f*frameSize + ch*sampleSize
+				if v := math.Abs(sampleValue(pcm, pcm.samples[offset:offset+sampleSize])); v > peak {
+					peak = v
+				}
+			}
+		}
+		peaks[i] = byte(math.Min(peak, 1) * 255)
+	}
+	return peaks
+}
+
+// sampleValue converts a single PCM sample to the range -1..1
+func sampleValue(pcm *pcmData, b []byte) float64 {
+	switch {
+	case pcm.float && len(b) == 4:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case pcm.float && len(b) == 8:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	}
+
+	switch len(b) {
+	case 1:
+		// 8-bit WAV samples are unsigned
+		return (float64(b[0]) - 128) / 128
+	case 2:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / 32768
+	case 3:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		return float64(v) / 8388608
+	case 4:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648
+	}
+	return 0
+}
+
+// Downsample reduces peaks to at most n values by taking the maximum of
+// each group, and scales them to 0..1 for clients
+func Downsample(peaks []byte, n int) []float64 {
+	if n <= 0 || n > len(peaks) {
+		n = len(peaks)
+	}
+	out := make([]float64, n)
+	for i := 0; i < n; i++ {
+		start := i * len(peaks) / n
+		end := (i + 1) * len(peaks) / n
+		var peak byte
+		for _, p := range peaks[start:end] {
+			if p > peak {
+				peak = p
+			}
+		}
+		out[i] = math.Round(float64(peak)/255*1000) / 1000
+	}
+	return out
+}
@@ -0,0 +1,173 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+// Matroska element IDs used to read audio metadata
+const (
+	ebmlSegment        = 0x18538067
+	ebmlInfo           = 0x1549A966
+	ebmlTimestampScale = 0x2AD7B1
+	ebmlDuration       = 0x4489
+	ebmlTracks         = 0x1654AE6B
+	ebmlTrackEntry     = 0xAE
+	ebmlCodecID        = 0x86
+	ebmlAudio          = 0xE1
+	ebmlSamplingFreq   = 0xB5
+	ebmlChannels       = 0x9F
+	ebmlCluster        = 0x1F43B675
+	ebmlClusterTime    = 0xE7
+	ebmlBlockGroup     = 0xA0
+	ebmlBlock          = 0xA1
+	ebmlSimpleBlock    = 0xA3
+)
+
+// ebmlMasters are the elements whose children are read in place. Every
+// other element is skipped by its size, which also copes with the unknown
+// sized segments and clusters written by browser MediaRecorder.
+var ebmlMasters = map[uint32]bool{
+	ebmlSegment:    true,
+	ebmlInfo:       true,
+	ebmlTracks:     true,
+	ebmlTrackEntry: true,
+	ebmlAudio:      true,
+	ebmlCluster:    true,
+	ebmlBlockGroup: true,
+}
+
+// readVint reads an EBML variable length integer. IDs keep their length
+// marker bit, sizes have it removed.
+func readVint(data []byte, keepMarker bool) (uint64, int, bool) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, false
+	}
+	length := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || length > len(data) {
+		return 0, 0, false
+	}
+
+	value := uint64(data[0])
+	if !keepMarker {
+		value &= uint64(0xFF >> length)
+	}
+	allOnes := value == uint64(0xFF>>length)
+	for i := 1; i < length; i++ {
+		value = value<<8 | uint64(data[i])
+		allOnes = allOnes && data[i] == 0xFF
+	}
+	if !keepMarker && allOnes {
+		return math.MaxUint64, length, true
+	}
+	return value, length, true
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// parseWebM reads the track and segment info of a WebM/Matroska file. When
+// the segment carries no duration, as with MediaRecorder output, it is
+// derived from the timestamp of the last block.
+func parseWebM(data []byte) (*Info, error) {
+	info := &Info{Format: FormatWebM}
+	timestampScale := uint64(1000000)
+	var duration float64
+	var clusterTime, lastBlock int64
+	var codec string
+
+	pos := 0
+	for pos < len(data) {
+		id, idLen, ok := readVint(data[pos:], true)
+		if !ok {
+			break
+		}
+		size, sizeLen, ok := readVint(data[pos+idLen:], false)
+		if !ok {
+			break
+		}
+		body := pos + idLen + sizeLen
+
+		if ebmlMasters[uint32(id)] {
+			pos = body
+			continue
+		}
+		if size == math.MaxUint64 || body+int(size) > len(data) || int(size) < 0 {
+			break
+		}
+		value := data[body : body+int(size)]
+
+		switch uint32(id) {
+		case ebmlTimestampScale:
+			timestampScale = ebmlUint(value)
+		case ebmlDuration:
+			duration = ebmlFloat(value)
+		case ebmlCodecID:
+			if c := string(value); strings.HasPrefix(c, "A_") && codec == "" {
+				codec = c
+			}
+		case ebmlSamplingFreq:
+			if info.SampleRate == 0 {
+				info.SampleRate = int(ebmlFloat(value))
+			}
+		case ebmlChannels:
+			if info.Channels == 0 {
+				info.Channels = int(ebmlUint(value))
+			}
+		case ebmlClusterTime:
+			clusterTime = int64(ebmlUint(value))
+		case ebmlSimpleBlock, ebmlBlock:
+			if _, n, ok := readVint(value, false); ok && len(value) >= n+2 {
+				relative := int64(int16(binary.BigEndian.Uint16(value[n:])))
+				if t := clusterTime + relative; t > lastBlock {
+					lastBlock = t
+				}
+			}
+		}
+
+		pos = body + int(size)
+	}
+
+	if codec == "" {
+		return nil, ErrMalformed
+	}
+	if info.Channels == 0 {
+		info.Channels = 1
+	}
+
+	switch codec {
+	case "A_OPUS":
+		info.Codec = "opus"
+	case "A_VORBIS":
+		info.Codec = "vorbis"
+	case "A_AAC":
+		info.Codec = "aac"
+	default:
+		info.Codec = strings.ToLower(strings.TrimPrefix(codec, "A_"))
+	}
+
+	if duration == 0 {
+		duration = float64(lastBlock)
+	}
+	info.DurationMs = int64(duration * float64(timestampScale) / 1e6)
+	return info, nil
+}
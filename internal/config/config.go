@@ -3,17 +3,29 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Storage  StorageConfig
+	Audio    AudioConfig
+
+	Transcription TranscriptionConfig
+	GC            GCConfig
+	Trash         TrashConfig
+	Calendar      CalendarConfig
+	Stats         StatsConfig
+	Mail          MailConfig
+	Digest        DigestConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port      string
+	Mode      string
+	PublicURL string // base of links handed out, e.g. https://zebra.example.com; the request's host when empty
 }
 
 type DatabaseConfig struct {
@@ -30,11 +42,71 @@ type JWTConfig struct {
 	ExpiryMinutes int
 }
 
+type StorageConfig struct {
+	Driver string // database or local
+	Path   string // root directory for the local driver
+
+	QuotaMB int // per-user limit on uploaded data, 0 for unlimited
+}
+
+type AudioConfig struct {
+	FFmpegPath string // used to decode compressed audio for waveforms
+}
+
+type GCConfig struct {
+	IntervalMinutes int // how often the API server collects garbage, 0 to disable
+	GraceMinutes    int // minimum age of unreferenced objects before deletion
+}
+
+type TrashConfig struct {
+	RetentionDays        int // how long deleted items can be restored, 0 to keep them forever
+	PurgeIntervalMinutes int // how often the API server purges expired items
+}
+
+// Retention returns how long deleted items stay in the trash, or 0 when
+// they are kept until restored
+func (c TrashConfig) Retention() time.Duration {
+	return time.Duration(c.RetentionDays) * 24 * time.Hour
+}
+
+type CalendarConfig struct {
+	FeedDays       int // how many days back the calendar feed reaches
+	RefreshMinutes int // how often calendar apps are asked to refresh the feed
+}
+
+type StatsConfig struct {
+	CacheSeconds int // how long computed statistics are reused, 0 to always recompute
+}
+
+type MailConfig struct {
+	Driver string // empty to disable, file or smtp
+	From   string // sender address, e.g. Zebra <zebra@example.com>
+	Path   string // directory the file driver writes messages to
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+type DigestConfig struct {
+	IntervalMinutes int // how often the API server looks for digests due, 0 to disable
+}
+
+type TranscriptionConfig struct {
+	Driver         string // empty to disable, command or fake
+	Command        string // command line with {input} and {output} placeholders
+	TimeoutSeconds int
+	StaleMinutes   int // after which a record still processing is transcribed again
+}
+
 func New() *Config {
 	return &Config{
 		Server: ServerConfig{
 			Port: getEnv("SERVER_PORT", "8080"),
 			Mode: getEnv("SERVER_MODE", "debug"),
+
+			PublicURL: getEnv("SERVER_PUBLIC_URL", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -48,6 +120,49 @@ func New() *Config {
 			Secret:        getEnv("JWT_SECRET", "your-secret-key"),
 			ExpiryMinutes: getEnvAsInt("JWT_EXPIRY_MINUTES", 60*24), // 24 hours default
 		},
+		Storage: StorageConfig{
+			Driver: getEnv("STORAGE_DRIVER", "database"),
+			Path:   getEnv("STORAGE_PATH", "./data"),
+
+			QuotaMB: getEnvAsInt("STORAGE_QUOTA_MB", 0),
+		},
+		Audio: AudioConfig{
+			FFmpegPath: getEnv("AUDIO_FFMPEG_PATH", "ffmpeg"),
+		},
+		Transcription: TranscriptionConfig{
+			Driver:         getEnv("TRANSCRIPTION_DRIVER", ""),
+			Command:        getEnv("TRANSCRIPTION_COMMAND", ""),
+			TimeoutSeconds: getEnvAsInt("TRANSCRIPTION_TIMEOUT_SECONDS", 600),
+			StaleMinutes:   getEnvAsInt("TRANSCRIPTION_STALE_MINUTES", 30),
+		},
+		GC: GCConfig{
+			IntervalMinutes: getEnvAsInt("GC_INTERVAL_MINUTES", 0),
+			GraceMinutes:    getEnvAsInt("GC_GRACE_MINUTES", 60),
+		},
+		Trash: TrashConfig{
+			RetentionDays:        getEnvAsInt("TRASH_RETENTION_DAYS", 30),
+			PurgeIntervalMinutes: getEnvAsInt("TRASH_PURGE_INTERVAL_MINUTES", 60),
+		},
+		Calendar: CalendarConfig{
+			FeedDays:       getEnvAsInt("CALENDAR_FEED_DAYS", 365),
+			RefreshMinutes: getEnvAsInt("CALENDAR_REFRESH_MINUTES", 60),
+		},
+		Stats: StatsConfig{
+			CacheSeconds: getEnvAsInt("STATS_CACHE_SECONDS", 300),
+		},
+		Mail: MailConfig{
+			Driver: getEnv("MAIL_DRIVER", ""),
+			From:   getEnv("MAIL_FROM", "Zebra <zebra@localhost>"),
+			Path:   getEnv("MAIL_PATH", "./data/mail"),
+
+			SMTPHost:     getEnv("MAIL_SMTP_HOST", ""),
+			SMTPPort:     getEnvAsInt("MAIL_SMTP_PORT", 587),
+			SMTPUsername: getEnv("MAIL_SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("MAIL_SMTP_PASSWORD", ""),
+		},
+		Digest: DigestConfig{
+			IntervalMinutes: getEnvAsInt("DIGEST_INTERVAL_MINUTES", 15),
+		},
 	}
 }
 
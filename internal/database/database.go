@@ -31,15 +31,41 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 	if err := db.AutoMigrate(
 		&domain.User{},
 		&domain.WorkLog{},
+		&domain.WorkLogPause{},
 		&domain.LogEntry{},
 		&domain.Project{},
 		&domain.Session{},
 		&domain.Record{},
 		&domain.File{},
+		&domain.Thumbnail{},
+		&domain.StoredObject{},
+		&domain.Blob{},
+		&domain.StorageUsage{},
+		&domain.Revision{},
+		&domain.Document{},
+		&domain.InvoiceCounter{},
+		&domain.Goal{},
+		&domain.Notification{},
+		&domain.PlannedBlock{},
+		&domain.Club{},
+		&domain.ClubMembership{},
+		&domain.Challenge{},
+		&domain.ChallengeParticipant{},
+		&domain.ChallengeResult{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	if err := migrateSessionDurations(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate session durations: %v", err)
+	}
+	if err := migrateSessionTags(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate session tags: %v", err)
+	}
+	if err := migrateDigestOptIn(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate digest subscriptions: %v", err)
+	}
+
 	DB = db
 	log.Println("Database connected and migrated successfully")
 	return db, nil
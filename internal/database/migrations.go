@@ -0,0 +1,96 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// schemaMigration records a one-time data migration that has been applied
+type schemaMigration struct {
+	Name      string    `gorm:"primary_key"`
+	AppliedAt time.Time `gorm:"not null;default:now()"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// runOnce applies a data migration unless schema_migrations says it already
+// was, recording it in the same transaction
+func runOnce(db *gorm.DB, name string, migrate func(tx *gorm.DB) error) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&schemaMigration{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := migrate(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Name: name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// migrateSessionDurations converts session durations stored in milliseconds
+// to seconds, once. Only durations over 100 times the session's interval are
+// converted: no duration in seconds exceeds the interval, while one in
+// milliseconds does unless over 90% of the session was paused.
+func migrateSessionDurations(db *gorm.DB) error {
+	return runOnce(db, "session_durations_in_seconds", func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			UPDATE sessions SET duration = duration / 1000
+			WHERE end_time > start_time
+			AND duration > 100 * EXTRACT(EPOCH FROM end_time - start_time)`)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Converted %d session durations from milliseconds to seconds", result.RowsAffected)
+		}
+		return nil
+	})
+}
+
+// migrateDigestOptIn unsubscribes the users who were subscribed to the
+// weekly digest by the column's former default and never received one, once
+func migrateDigestOptIn(db *gorm.DB) error {
+	return runOnce(db, "digest_opt_in", func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			UPDATE users SET digest_enabled = false
+			WHERE digest_enabled AND digest_sent_week = '' AND unsubscribe_token IS NULL`)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Unsubscribed %d users from the weekly digest, which is now opt-in", result.RowsAffected)
+		}
+		return nil
+	})
+}
+
+// migrateSessionTags copies the tags of converted work logs onto their
+// sessions, which did not have tags of their own before, once. Sessions that
+// already have tags are left alone.
+func migrateSessionTags(db *gorm.DB) error {
+	return runOnce(db, "session_tags_from_work_logs", func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			UPDATE sessions SET tags = work_logs.tags
+			FROM work_logs
+			WHERE work_logs.session_id = sessions.id
+			AND sessions.tags IS NULL AND work_logs.tags IS NOT NULL`)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Copied work log tags onto %d sessions", result.RowsAffected)
+		}
+		return nil
+	})
+}
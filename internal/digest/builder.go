@@ -0,0 +1,94 @@
+package digest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+)
+
+// Limits on what a digest lists
+const (
+	maxNotes       = 3
+	maxNoteRunes   = 280
+	maxProjectRows = 10
+)
+
+// Builder collects a user's week from their sessions, records and goals
+type Builder struct {
+	reports repository.ReportRepository
+	goals   repository.GoalRepository
+}
+
+func NewBuilder(reports repository.ReportRepository, goals repository.GoalRepository) *Builder {
+	return &Builder{reports: reports, goals: goals}
+}
+
+// Build summarizes the user's time from from to to, which are the bounds of
+// an ISO week in loc
+func (b *Builder) Build(ctx context.Context, user *domain.User, from, to time.Time, week string, loc *time.Location) (*domain.Digest, error) {
+	digest := &domain.Digest{User: user, Week: week, From: from, To: to}
+	filter := repository.ReportFilter{UserID: user.ID, From: from, To: to, Timezone: loc.String()}
+
+	summary, err := b.reports.Summary(ctx, filter, domain.GroupByProject)
+	if err != nil {
+		return nil, err
+	}
+	digest.Duration = summary.Duration
+	digest.Sessions = summary.Sessions
+	digest.Projects = summary.Groups
+	sort.SliceStable(digest.Projects, func(i, j int) bool {
+		return digest.Projects[i].Duration > digest.Projects[j].Duration
+	})
+	if len(digest.Projects) > maxProjectRows {
+		digest.Projects = digest.Projects[:maxProjectRows]
+	}
+
+	activity, err := b.reports.Activity(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	first, last := from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02")
+	digest.Streak = domain.NewActivityStats(activity, first, last, last, loc.String(), time.Now()).CurrentStreak
+
+	digest.Notes, err = b.reports.TopNotes(ctx, filter, maxNotes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range digest.Notes {
+		digest.Notes[i].Text = truncate(strings.TrimSpace(digest.Notes[i].Text), maxNoteRunes)
+	}
+
+	goals, err := b.goals.GetByUserID(ctx, user.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Goals are shown as they stood when the week ended
+	end := to.Add(-time.Second)
+	for i := range goals {
+		goal := &goals[i]
+		periodFrom, periodTo, _ := goal.PeriodBounds(end, loc)
+		if periodTo == nil || periodTo.After(to) {
+			periodTo = &to
+		}
+		tracked, err := b.goals.Tracked(ctx, goal.ProjectID, periodFrom, periodTo)
+		if err != nil {
+			return nil, err
+		}
+		digest.Goals = append(digest.Goals, goal.Progress(tracked, end, loc))
+	}
+
+	return digest, nil
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
@@ -0,0 +1,75 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var funcs = map[string]interface{}{
+	"hours":   formatHours,
+	"date":    func(t time.Time) string { return t.Format("Mon 2 Jan") },
+	"lastDay": func(t time.Time) time.Time { return t.AddDate(0, 0, -1) },
+	"oneLine": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+	"period":  formatPeriod,
+	"status":  formatStatus,
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(funcs).ParseFS(templateFS, "templates/digest.html"))
+	textTemplate = texttemplate.Must(texttemplate.New("digest.txt").Funcs(funcs).ParseFS(templateFS, "templates/digest.txt"))
+)
+
+// Render returns the subject and the plain text and HTML bodies of the
+// digest email
+func Render(d *domain.Digest) (subject, text, html string, err error) {
+	subject = fmt.Sprintf("Your week in Zebra: %s tracked", formatHours(d.Duration))
+
+	var buf bytes.Buffer
+	if err := textTemplate.Execute(&buf, d); err != nil {
+		return "", "", "", err
+	}
+	text = buf.String()
+
+	buf.Reset()
+	if err := htmlTemplate.Execute(&buf, d); err != nil {
+		return "", "", "", err
+	}
+	return subject, text, buf.String(), nil
+}
+
+// formatHours writes seconds as hours and minutes, e.g. 12h 05m
+func formatHours(seconds int64) string {
+	minutes := seconds / 60
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+func formatPeriod(period string) string {
+	if period == domain.GoalTotal {
+		return "in total"
+	}
+	return "per " + period
+}
+
+func formatStatus(status string) string {
+	switch status {
+	case domain.GoalOnTrack:
+		return "on track"
+	case domain.GoalWarning:
+		return "nearly used up"
+	default:
+		return status
+	}
+}
@@ -0,0 +1,158 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/mail"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+)
+
+// Scheduler sends each subscribed user a digest of the previous week on
+// their chosen weekday and hour, in their time zone
+type Scheduler struct {
+	users     repository.UserRepository
+	builder   *Builder
+	mailer    mail.Mailer
+	from      string
+	publicURL string
+}
+
+// NewScheduler creates a scheduler. Unsubscribe links point to publicURL,
+// the base URL the API is reachable at.
+func NewScheduler(users repository.UserRepository, builder *Builder, mailer mail.Mailer, from, publicURL string) *Scheduler {
+	return &Scheduler{
+		users:     users,
+		builder:   builder,
+		mailer:    mailer,
+		from:      from,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+// Run sends the digests due at now and returns how many were sent. A
+// failure for one user is logged and does not stop the others.
+func (s *Scheduler) Run(ctx context.Context, now time.Time) (int, error) {
+	users, err := s.users.GetDigestSubscribers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range users {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		ok, err := s.send(ctx, &users[i], now)
+		if err != nil {
+			log.Printf("Failed to send digest to user %s: %v", users[i].ID, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// send mails the user's digest if it is due and reports whether it did.
+// The week is claimed before sending and released again on failure, so a
+// digest is never sent twice.
+func (s *Scheduler) send(ctx context.Context, user *domain.User, now time.Time) (bool, error) {
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil || user.Timezone == "" {
+		loc = time.UTC
+	}
+	from, to, week, due := user.DigestDue(now, loc)
+	if !due {
+		return false, nil
+	}
+
+	previous := user.DigestSentWeek
+	claimed, err := s.users.ClaimDigestWeek(ctx, user.ID, previous, week)
+	if err != nil || !claimed {
+		return false, err
+	}
+	user.DigestSentWeek = week
+	sent := false
+	defer func() {
+		if !sent {
+			if _, err := s.users.ClaimDigestWeek(ctx, user.ID, week, previous); err != nil {
+				log.Printf("Failed to release digest week %s of user %s: %v", week, user.ID, err)
+			}
+		}
+	}()
+
+	digest, err := s.builder.Build(ctx, user, from, to, week, loc)
+	if err != nil {
+		return false, err
+	}
+	if digest.Empty() {
+		// Nothing to report is not worth an email, but the week is done
+		sent = true
+		return false, nil
+	}
+
+	if user.UnsubscribeToken == nil {
+		token, err := domain.NewUnsubscribeToken()
+		if err != nil {
+			return false, err
+		}
+		if err := s.users.SetUnsubscribeToken(ctx, user.ID, token); err != nil {
+			return false, err
+		}
+		user.UnsubscribeToken = &token
+	}
+	unsubscribe := s.publicURL + "/digest/unsubscribe/" + url.PathEscape(*user.UnsubscribeToken)
+	digest.UnsubscribeURL = unsubscribe
+
+	subject, text, html, err := Render(digest)
+	if err != nil {
+		return false, err
+	}
+	msg := &mail.Message{
+		From:    s.from,
+		To:      user.Email,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return false, fmt.Errorf("sending: %w", err)
+	}
+
+	sent = true
+	return true, nil
+}
+
+// RunEvery sends the digests due on a fixed interval until ctx is
+// cancelled
+func (s *Scheduler) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sent, err := s.Run(ctx, now)
+			if err != nil {
+				log.Printf("Sending digests failed: %v", err)
+				continue
+			}
+			if sent > 0 {
+				log.Printf("Sent %d weekly digests", sent)
+			}
+		}
+	}
+}
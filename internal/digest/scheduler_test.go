@@ -0,0 +1,167 @@
+package digest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/mail"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeUsers keeps digest subscribers in memory
+type fakeUsers struct {
+	repository.UserRepository
+	users  []domain.User
+	claims []string // previous>week of each claim, in order
+	stolen bool     // another instance claims every week first
+	tokens map[uuid.UUID]string
+}
+
+func (f *fakeUsers) GetDigestSubscribers(context.Context) ([]domain.User, error) {
+	return append([]domain.User(nil), f.users...), nil
+}
+
+func (f *fakeUsers) ClaimDigestWeek(_ context.Context, userID uuid.UUID, previous, week string) (bool, error) {
+	for i := range f.users {
+		user := &f.users[i]
+		if user.ID != userID {
+			continue
+		}
+		if f.stolen || user.DigestSentWeek != previous {
+			return false, nil
+		}
+		f.claims = append(f.claims, previous+">"+week)
+		user.DigestSentWeek = week
+		return true, nil
+	}
+	return false, nil
+}
+
+func (f *fakeUsers) SetUnsubscribeToken(_ context.Context, userID uuid.UUID, token string) error {
+	f.tokens[userID] = token
+	return nil
+}
+
+// fakeReports reports the same week for everyone
+type fakeReports struct {
+	repository.ReportRepository
+	sessions int64
+}
+
+func (f *fakeReports) Summary(context.Context, repository.ReportFilter, string) (*domain.ReportSummary, error) {
+	summary := &domain.ReportSummary{Sessions: f.sessions, Duration: f.sessions * 3600}
+	if f.sessions > 0 {
+		summary.Groups = []domain.ReportGroup{{Key: uuid.NewString(), Label: "Thesis", Duration: summary.Duration, Sessions: f.sessions}}
+	}
+	return summary, nil
+}
+
+func (f *fakeReports) Activity(context.Context, repository.ReportFilter) (*domain.ActivityData, error) {
+	return &domain.ActivityData{}, nil
+}
+
+func (f *fakeReports) TopNotes(context.Context, repository.ReportFilter, int) ([]domain.DigestNote, error) {
+	return nil, nil
+}
+
+type fakeGoals struct {
+	repository.GoalRepository
+}
+
+func (fakeGoals) GetByUserID(context.Context, uuid.UUID, []uuid.UUID) ([]domain.Goal, error) {
+	return nil, nil
+}
+
+func TestSchedulerRun(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	// Monday of 2026-W11, so the digest of 2026-W10 is due from 8:00
+	monday := func(hour int) time.Time { return time.Date(2026, 3, 9, hour, 30, 0, 0, berlin) }
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		sentWeek   string
+		sessions   int64
+		failMail   bool
+		stolen     bool
+		wantSent   int
+		wantClaims []string
+		wantMails  int
+	}{
+		{name: "due", now: monday(9), sessions: 3, wantSent: 1, wantClaims: []string{">2026-W10"}, wantMails: 1},
+		{name: "before the hour", now: monday(7), sessions: 3},
+		{name: "already sent", now: monday(9), sentWeek: "2026-W10", sessions: 3},
+		{name: "empty week is claimed without mail", now: monday(9), wantClaims: []string{">2026-W10"}},
+		{name: "failed mail releases the claim", now: monday(9), sessions: 3, failMail: true, wantClaims: []string{">2026-W10", "2026-W10>"}},
+		{name: "claimed elsewhere", now: monday(9), sessions: 3, stolen: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := domain.User{
+				ID:             uuid.New(),
+				Name:           "Ada",
+				Email:          "ada@example.com",
+				Timezone:       "Europe/Berlin",
+				DigestEnabled:  true,
+				DigestWeekday:  1,
+				DigestHour:     8,
+				DigestSentWeek: tt.sentWeek,
+				CreatedAt:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			users := &fakeUsers{users: []domain.User{user}, stolen: tt.stolen, tokens: map[uuid.UUID]string{}}
+
+			dir := t.TempDir()
+			mailer := &mail.FileMailer{Dir: filepath.Join(dir, "mail")}
+			if tt.failMail {
+				// A file where the directory should be makes every send fail
+				if err := os.WriteFile(mailer.Dir, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			builder := NewBuilder(&fakeReports{sessions: tt.sessions}, fakeGoals{})
+			scheduler := NewScheduler(users, builder, mailer, "digest@example.com", "https://zebra.example/")
+			sent, err := scheduler.Run(context.Background(), tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent != tt.wantSent {
+				t.Errorf("sent %d digests, want %d", sent, tt.wantSent)
+			}
+			if strings.Join(users.claims, " ") != strings.Join(tt.wantClaims, " ") {
+				t.Errorf("claims = %v, want %v", users.claims, tt.wantClaims)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(mailer.Dir, "*.eml"))
+			if len(files) != tt.wantMails {
+				t.Fatalf("wrote %d mails, want %d", len(files), tt.wantMails)
+			}
+			if tt.wantMails == 0 {
+				return
+			}
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := users.tokens[user.ID]
+			for _, want := range []string{
+				"To: ada@example.com",
+				"List-Unsubscribe: <https://zebra.example/digest/unsubscribe/" + token + ">",
+				"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+			} {
+				if token == "" || !strings.Contains(string(data), want) {
+					t.Errorf("mail lacks %q:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// dateLayout is the form of local dates in activity statistics
+const dateLayout = "2006-01-02"
+
+// mostActiveHoursCount is how many hours of the week are listed as the most
+// active
+const mostActiveHoursCount = 5
+
+// ActivityDay is the tracked time of one local day. Level ranks the day
+// from 0 (nothing tracked) to 4 (as much as the busiest day) for heatmap
+// colors.
+type ActivityDay struct {
+	Date     string `json:"date"`
+	Duration int64  `json:"duration"` // in seconds
+	Sessions int64  `json:"sessions"`
+	Level    int    `json:"level" gorm:"-"`
+}
+
+// HourActivity is the time tracked in one hour of the week, by wall clock
+type HourActivity struct {
+	Weekday  int   `json:"weekday"` // 1 for Monday to 7 for Sunday
+	Hour     int   `json:"hour"`
+	Duration int64 `json:"duration"`
+}
+
+// Streak is a run of consecutive days with tracked time
+type Streak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// ActivityData is what activity statistics are computed from
+type ActivityData struct {
+	Days        []ActivityDay  // days in the range that have sessions, in order
+	ActiveDates []string       // every day the user tracked time on, in order
+	Hours       []HourActivity // hours of the week in the range with tracked time
+}
+
+// ActivityStats describe when a user works, for heatmaps and streaks
+type ActivityStats struct {
+	From                 string         `json:"from"` // first and last day, inclusive
+	To                   string         `json:"to"`
+	Timezone             string         `json:"timezone"`
+	Days                 []ActivityDay  `json:"days"` // every day from From to To
+	Duration             int64          `json:"duration"`
+	Sessions             int64          `json:"sessions"`
+	ActiveDays           int            `json:"active_days"`
+	AverageSessionLength int64          `json:"average_session_length"` // in seconds
+	CurrentStreak        Streak         `json:"current_streak"`
+	LongestStreak        Streak         `json:"longest_streak"`
+	HoursOfWeek          [7][24]int64   `json:"hours_of_week"` // seconds by weekday, Monday first, and hour
+	MostActiveHours      []HourActivity `json:"most_active_hours"`
+	GeneratedAt          time.Time      `json:"generated_at"`
+}
+
+// NewActivityStats computes the statistics of the days from-to, which are
+// dates as YYYY-MM-DD. Streaks span the user's history up to today; the
+// current streak is still running if it ended yesterday, since today may
+// not be tracked yet.
+func NewActivityStats(data *ActivityData, from, to, today, timezone string, now time.Time) *ActivityStats {
+	stats := &ActivityStats{
+		From:            from,
+		To:              to,
+		Timezone:        timezone,
+		Days:            []ActivityDay{},
+		MostActiveHours: []HourActivity{},
+		GeneratedAt:     now,
+	}
+
+	byDate := make(map[string]ActivityDay, len(data.Days))
+	var busiest int64
+	for _, day := range data.Days {
+		byDate[day.Date] = day
+		stats.Duration += day.Duration
+		stats.Sessions += day.Sessions
+		if day.Duration > 0 {
+			stats.ActiveDays++
+		}
+		busiest = max(busiest, day.Duration)
+	}
+	if stats.Sessions > 0 {
+		stats.AverageSessionLength = stats.Duration / stats.Sessions
+	}
+
+	start, _ := time.Parse(dateLayout, from)
+	end, _ := time.Parse(dateLayout, to)
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		day := byDate[d.Format(dateLayout)]
+		day.Date = d.Format(dateLayout)
+		if day.Duration > 0 {
+			day.Level = int((4*day.Duration + busiest - 1) / busiest)
+		}
+		stats.Days = append(stats.Days, day)
+	}
+
+	stats.CurrentStreak, stats.LongestStreak = streaks(data.ActiveDates, today)
+
+	for _, hour := range data.Hours {
+		if hour.Weekday >= 1 && hour.Weekday <= 7 && hour.Hour >= 0 && hour.Hour < 24 {
+			stats.HoursOfWeek[hour.Weekday-1][hour.Hour] = hour.Duration
+		}
+	}
+	hours := make([]HourActivity, 0, len(data.Hours))
+	for _, hour := range data.Hours {
+		if hour.Duration > 0 {
+			hours = append(hours, hour)
+		}
+	}
+	sort.SliceStable(hours, func(i, j int) bool { return hours[i].Duration > hours[j].Duration })
+	if len(hours) > mostActiveHoursCount {
+		hours = hours[:mostActiveHoursCount]
+	}
+	stats.MostActiveHours = append(stats.MostActiveHours, hours...)
+
+	return stats
+}
+
+// streaks finds the current and the longest run of consecutive dates in
+// dates, which are sorted and distinct. Dates after today are left out.
+func streaks(dates []string, today string) (current, longest Streak) {
+	var run Streak
+	var previous time.Time
+	for _, date := range dates {
+		if date > today {
+			break
+		}
+		d, err := time.Parse(dateLayout, date)
+		if err != nil {
+			continue
+		}
+		if run.Days > 0 && d.Equal(previous.AddDate(0, 0, 1)) {
+			run.Days++
+			run.End = date
+		} else {
+			run = Streak{Days: 1, Start: date, End: date}
+		}
+		previous = d
+		if run.Days > longest.Days {
+			longest = run
+		}
+	}
+
+	t, err := time.Parse(dateLayout, today)
+	if err == nil && run.Days > 0 {
+		if run.End == today || run.End == t.AddDate(0, 0, -1).Format(dateLayout) {
+			current = run
+		}
+	}
+	return current, longest
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreaks(t *testing.T) {
+	tests := []struct {
+		name             string
+		dates            []string
+		today            string
+		current, longest Streak
+	}{
+		{"no activity", nil, "2026-03-10", Streak{}, Streak{}},
+		{
+			"running through today",
+			[]string{"2026-03-08", "2026-03-09", "2026-03-10"}, "2026-03-10",
+			Streak{3, "2026-03-08", "2026-03-10"}, Streak{3, "2026-03-08", "2026-03-10"},
+		},
+		{
+			"ended yesterday still runs",
+			[]string{"2026-03-08", "2026-03-09"}, "2026-03-10",
+			Streak{2, "2026-03-08", "2026-03-09"}, Streak{2, "2026-03-08", "2026-03-09"},
+		},
+		{
+			"broken two days ago",
+			[]string{"2026-03-07", "2026-03-08"}, "2026-03-10",
+			Streak{}, Streak{2, "2026-03-07", "2026-03-08"},
+		},
+		{
+			"longest earlier than current",
+			[]string{"2026-02-01", "2026-02-02", "2026-02-03", "2026-03-09", "2026-03-10"}, "2026-03-10",
+			Streak{2, "2026-03-09", "2026-03-10"}, Streak{3, "2026-02-01", "2026-02-03"},
+		},
+		{
+			"first of equal runs is the longest",
+			[]string{"2026-03-01", "2026-03-02", "2026-03-05", "2026-03-06"}, "2026-03-10",
+			Streak{}, Streak{2, "2026-03-01", "2026-03-02"},
+		},
+		{
+			"across months and a leap day",
+			[]string{"2028-02-28", "2028-02-29", "2028-03-01"}, "2028-03-01",
+			Streak{3, "2028-02-28", "2028-03-01"}, Streak{3, "2028-02-28", "2028-03-01"},
+		},
+		{
+			"future dates are left out",
+			[]string{"2026-03-09", "2026-03-10", "2026-03-11", "2026-03-12"}, "2026-03-10",
+			Streak{2, "2026-03-09", "2026-03-10"}, Streak{2, "2026-03-09", "2026-03-10"},
+		},
+		{
+			"unparsable dates are skipped",
+			[]string{"2026-02-30", "2026-03-09", "2026-03-10"}, "2026-03-10",
+			Streak{2, "2026-03-09", "2026-03-10"}, Streak{2, "2026-03-09", "2026-03-10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, longest := streaks(tt.dates, tt.today)
+			if current != tt.current {
+				t.Errorf("current = %+v, want %+v", current, tt.current)
+			}
+			if longest != tt.longest {
+				t.Errorf("longest = %+v, want %+v", longest, tt.longest)
+			}
+		})
+	}
+}
+
+func TestNewActivityStats(t *testing.T) {
+	data := &ActivityData{
+		Days: []ActivityDay{
+			{Date: "2026-03-02", Duration: 3600, Sessions: 2},
+			{Date: "2026-03-04", Duration: 900, Sessions: 1},
+		},
+		ActiveDates: []string{"2026-03-02", "2026-03-03", "2026-03-04"},
+		Hours: []HourActivity{
+			{Weekday: 1, Hour: 9, Duration: 3600},
+			{Weekday: 3, Hour: 14, Duration: 900},
+			{Weekday: 8, Hour: 0, Duration: 60}, // out of range
+		},
+	}
+	stats := NewActivityStats(data, "2026-03-01", "2026-03-04", "2026-03-05", "UTC", time.Now())
+
+	if len(stats.Days) != 4 || stats.Days[0].Date != "2026-03-01" || stats.Days[3].Date != "2026-03-04" {
+		t.Fatalf("days = %+v", stats.Days)
+	}
+	levels := []int{0, 4, 0, 1}
+	for i, day := range stats.Days {
+		if day.Level != levels[i] {
+			t.Errorf("level of %s = %d, want %d", day.Date, day.Level, levels[i])
+		}
+	}
+	if stats.Duration != 4500 || stats.Sessions != 3 || stats.ActiveDays != 2 || stats.AverageSessionLength != 1500 {
+		t.Errorf("totals = %d s, %d sessions, %d days, %d s average", stats.Duration, stats.Sessions, stats.ActiveDays, stats.AverageSessionLength)
+	}
+	if stats.CurrentStreak.Days != 3 || stats.LongestStreak.Days != 3 {
+		t.Errorf("streaks = %+v, %+v", stats.CurrentStreak, stats.LongestStreak)
+	}
+	if stats.HoursOfWeek[0][9] != 3600 || stats.HoursOfWeek[2][14] != 900 {
+		t.Errorf("hours of week not filled in")
+	}
+	if len(stats.MostActiveHours) != 3 || stats.MostActiveHours[0].Duration != 3600 {
+		t.Errorf("most active hours = %+v", stats.MostActiveHours)
+	}
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// calendarUIDSuffix marks the UIDs of events published for sessions
+const calendarUIDSuffix = "@zebra"
+
+// CalendarEvent is a session as published in a user's calendar feed
+type CalendarEvent struct {
+	SessionID   uuid.UUID
+	ICalUID     *string // UID of the event the session was imported from
+	ProjectName string
+	StartTime   time.Time
+	EndTime     time.Time
+	Tags        StringArray
+	Notes       string // record texts in order, separated by blank lines
+	UpdatedAt   time.Time
+}
+
+// UID identifies the event. Imported sessions keep the UID of their event so
+// calendars that hold both do not show them twice.
+func (e *CalendarEvent) UID() string {
+	if e.ICalUID != nil {
+		return *e.ICalUID
+	}
+	return SessionEventUID(e.SessionID)
+}
+
+// SplitPartUID returns the import UID of a part split off a session that
+// was imported under uid, or nil for sessions that were not imported. The
+// event's own UID stays with the first part, so parts are published as
+// separate events and remain recognizable as imported.
+func SplitPartUID(uid *string, partID uuid.UUID) *string {
+	if uid == nil {
+		return nil
+	}
+	part := *uid + "/" + partID.String()
+	return &part
+}
+
+// SessionEventUID is the UID under which a session is published
+func SessionEventUID(id uuid.UUID) string {
+	return id.String() + calendarUIDSuffix
+}
+
+// SessionIDFromEventUID returns the session a published UID refers to
+func SessionIDFromEventUID(uid string) (uuid.UUID, bool) {
+	s, found := strings.CutSuffix(uid, calendarUIDSuffix)
+	if !found {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(s)
+	return id, err == nil
+}
+
+// NewCalendarToken returns a random secret for a calendar feed URL
+func NewCalendarToken() (string, error) {
+	return newToken()
+}
+
+// newToken returns a random secret for use in URLs
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
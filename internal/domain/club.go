@@ -0,0 +1,288 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Club member roles
+const (
+	ClubOwner  = "owner"
+	ClubMember = "member"
+)
+
+// Metrics clubs are ranked by
+const (
+	MetricTime     = "time"     // tracked seconds
+	MetricSessions = "sessions" // number of sessions
+	MetricStreak   = "streak"   // longest run of days with tracked time
+)
+
+// Leaderboard windows, the current period in the club's time zone
+const (
+	WindowWeek  = "week" // ISO 8601 week
+	WindowMonth = "month"
+	WindowYear  = "year"
+)
+
+// maxChallengeDays bounds how long a challenge may run
+const maxChallengeDays = 366
+
+// ValidMetric reports whether metric names a supported metric
+func ValidMetric(metric string) bool {
+	switch metric {
+	case MetricTime, MetricSessions, MetricStreak:
+		return true
+	}
+	return false
+}
+
+// Club is a group of users who compare their tracked time. Members join
+// with the invite code.
+type Club struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	Name       string    `json:"name" gorm:"not null"`
+	OwnerID    uuid.UUID `json:"owner_id" gorm:"type:uuid;not null;index"`
+	Timezone   string    `json:"timezone" gorm:"not null;default:UTC"` // IANA name windows and days are split in
+	InviteCode string    `json:"invite_code,omitempty" gorm:"not null;uniqueIndex"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	Members []ClubMembership `json:"members,omitempty" gorm:"-"`
+}
+
+// Validate checks the club's name and time zone
+func (c *Club) Validate() error {
+	verr := &ValidationError{}
+
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		verr.add("name", "must not be empty")
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil || c.Timezone == "" || c.Timezone == "Local" {
+		verr.add("timezone", "must be an IANA time zone such as Europe/Berlin")
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// NewInviteCode makes the secret users join a club with
+func NewInviteCode() (string, error) {
+	return newToken()
+}
+
+// ClubMembership is a user's membership of a club. Members appear on the
+// club's leaderboards only once they share their stats. The owner may set
+// the rate the club bills a member's time at.
+type ClubMembership struct {
+	ClubID     uuid.UUID `json:"club_id" gorm:"type:uuid;primary_key"`
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key;index"`
+	Role       string    `json:"role" gorm:"not null"`
+	ShareStats bool      `json:"share_stats" gorm:"not null;default:false"`
+	HourlyRate *int64    `json:"hourly_rate,omitempty"` // minor units per hour, nil for the member's own rate
+	JoinedAt   time.Time `json:"joined_at"`
+
+	UserName string `json:"user_name,omitempty" gorm:"->;-:migration"` // read when listing members
+}
+
+// Rate returns the member's hourly rate in the club, or userRate when the
+// owner has not set one
+func (m *ClubMembership) Rate(userRate int64) int64 {
+	if m.HourlyRate != nil {
+		return *m.HourlyRate
+	}
+	return userRate
+}
+
+// Challenge is a time-boxed competition of a club's members on one metric.
+// Members join to take part, and a positive Goal marks who reached it.
+type Challenge struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	ClubID      uuid.UUID  `json:"club_id" gorm:"type:uuid;not null;index"`
+	Name        string     `json:"name" gorm:"not null"`
+	Metric      string     `json:"metric" gorm:"not null"`
+	Goal        int64      `json:"goal"` // in the metric's unit, 0 for none
+	StartTime   time.Time  `json:"start_time" gorm:"not null"`
+	EndTime     time.Time  `json:"end_time" gorm:"not null"`
+	CreatedBy   uuid.UUID  `json:"created_by" gorm:"type:uuid;not null"`
+	FinalizedAt *time.Time `json:"finalized_at,omitempty"` // when the final standings were kept
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Validate checks the challenge's name, metric, goal and times
+func (ch *Challenge) Validate() error {
+	verr := &ValidationError{}
+
+	ch.Name = strings.TrimSpace(ch.Name)
+	if ch.Name == "" {
+		verr.add("name", "must not be empty")
+	}
+	if !ValidMetric(ch.Metric) {
+		verr.add("metric", "must be time, sessions or streak")
+	}
+	if ch.Goal < 0 {
+		verr.add("goal", "must not be negative")
+	}
+	if !ch.EndTime.After(ch.StartTime) {
+		verr.add("end_time", "must be after start_time")
+	} else if ch.EndTime.Sub(ch.StartTime) > maxChallengeDays*24*time.Hour {
+		verr.add("end_time", "must be at most %d days after start_time", maxChallengeDays)
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// Ended reports whether the challenge is over at now, which makes its
+// standings final
+func (ch *Challenge) Ended(now time.Time) bool {
+	return !now.Before(ch.EndTime)
+}
+
+// ChallengeParticipant is a member taking part in a challenge. Joining
+// shares the challenge's metric with the club, whatever ShareStats says.
+type ChallengeParticipant struct {
+	ChallengeID uuid.UUID `json:"challenge_id" gorm:"type:uuid;primary_key"`
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key;index"`
+	JoinedAt    time.Time `json:"joined_at"`
+
+	UserName string `json:"user_name,omitempty" gorm:"->;-:migration"` // read when listing participants
+}
+
+// ChallengeResult is a participant's final standing, kept once the
+// challenge has ended so that later edits of sessions do not change it
+type ChallengeResult struct {
+	ChallengeID uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID      uuid.UUID `gorm:"type:uuid;primary_key"`
+	Rank        int       `gorm:"not null"`
+	Value       int64     `gorm:"not null"`
+	GoalReached bool      `gorm:"not null;default:false"`
+
+	UserName string `gorm:"->;-:migration"` // read with the results
+}
+
+// MemberActivity is the time a user tracked in a window
+type MemberActivity struct {
+	UserID      uuid.UUID
+	Name        string
+	Duration    int64 // in seconds
+	Sessions    int64
+	ActiveDates []string `gorm:"-"` // days with tracked time in the club's time zone, in order
+}
+
+// Standing is a user's place on a leaderboard
+type Standing struct {
+	Rank        int       `json:"rank"` // tied values share a rank
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name"`
+	Value       int64     `json:"value"`
+	GoalReached bool      `json:"goal_reached,omitempty"`
+}
+
+// Leaderboard ranks users by a metric over a window
+type Leaderboard struct {
+	Metric    string     `json:"metric"`
+	From      time.Time  `json:"from"`
+	To        time.Time  `json:"to"`
+	Timezone  string     `json:"timezone"`
+	Final     bool       `json:"final"` // the window is over
+	Standings []Standing `json:"standings"`
+}
+
+// Results returns the standings of the challenge's leaderboard to be kept
+func (b *Leaderboard) Results(challengeID uuid.UUID) []ChallengeResult {
+	results := make([]ChallengeResult, len(b.Standings))
+	for i, s := range b.Standings {
+		results[i] = ChallengeResult{ChallengeID: challengeID, UserID: s.UserID, Rank: s.Rank, Value: s.Value, GoalReached: s.GoalReached}
+	}
+	return results
+}
+
+// FinalStandings is the leaderboard of an ended challenge from its kept
+// results, which are in order
+func (ch *Challenge) FinalStandings(results []ChallengeResult, loc *time.Location) *Leaderboard {
+	board := &Leaderboard{
+		Metric:    ch.Metric,
+		From:      ch.StartTime,
+		To:        ch.EndTime,
+		Timezone:  loc.String(),
+		Final:     true,
+		Standings: make([]Standing, len(results)),
+	}
+	for i, r := range results {
+		board.Standings[i] = Standing{Rank: r.Rank, UserID: r.UserID, Name: r.UserName, Value: r.Value, GoalReached: r.GoalReached}
+	}
+	return board
+}
+
+// WindowBounds returns the week, month or year containing now in loc
+func WindowBounds(window string, now time.Time, loc *time.Location) (from, to time.Time, ok bool) {
+	day := StartOfDay(now, loc)
+	switch window {
+	case WindowWeek:
+		from = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return from, from.AddDate(0, 0, 7), true
+	case WindowMonth:
+		from = day.AddDate(0, 0, 1-day.Day())
+		return from, from.AddDate(0, 1, 0), true
+	case WindowYear:
+		from = day.AddDate(0, 0, 1-day.YearDay())
+		return from, from.AddDate(1, 0, 0), true
+	}
+	return from, to, false
+}
+
+// RankMembers builds the leaderboard of the users' activity from-to. Users
+// without activity rank last with a value of 0, and goal, when positive,
+// marks who reached it.
+func RankMembers(metric string, activity []MemberActivity, goal int64, from, to time.Time, loc *time.Location, now time.Time) *Leaderboard {
+	board := &Leaderboard{
+		Metric:    metric,
+		From:      from,
+		To:        to,
+		Timezone:  loc.String(),
+		Final:     !now.Before(to),
+		Standings: make([]Standing, 0, len(activity)),
+	}
+
+	last := to.Add(-time.Nanosecond).In(loc).Format(dateLayout)
+	for _, a := range activity {
+		standing := Standing{UserID: a.UserID, Name: a.Name}
+		switch metric {
+		case MetricTime:
+			standing.Value = a.Duration
+		case MetricSessions:
+			standing.Value = a.Sessions
+		case MetricStreak:
+			_, longest := streaks(a.ActiveDates, last)
+			standing.Value = int64(longest.Days)
+		}
+		standing.GoalReached = goal > 0 && standing.Value >= goal
+		board.Standings = append(board.Standings, standing)
+	}
+
+	sort.SliceStable(board.Standings, func(i, j int) bool {
+		if board.Standings[i].Value != board.Standings[j].Value {
+			return board.Standings[i].Value > board.Standings[j].Value
+		}
+		return board.Standings[i].Name < board.Standings[j].Name
+	})
+	for i := range board.Standings {
+		if i > 0 && board.Standings[i].Value == board.Standings[i-1].Value {
+			board.Standings[i].Rank = board.Standings[i-1].Rank
+		} else {
+			board.Standings[i].Rank = i + 1
+		}
+	}
+
+	return board
+}
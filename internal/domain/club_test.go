@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWindowBounds(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		name     string
+		window   string
+		now      time.Time
+		from, to time.Time
+	}{
+		{"week from a Wednesday", WindowWeek, time.Date(2026, 3, 4, 15, 0, 0, 0, berlin), date(2026, 3, 2), date(2026, 3, 9)},
+		{"week on a Sunday night", WindowWeek, time.Date(2026, 3, 8, 23, 59, 0, 0, berlin), date(2026, 3, 2), date(2026, 3, 9)},
+		{"week on a Monday", WindowWeek, date(2026, 3, 9), date(2026, 3, 9), date(2026, 3, 16)},
+		// 23:30 UTC on Sunday is already Monday in Berlin
+		{"week in the club's zone", WindowWeek, time.Date(2026, 3, 8, 23, 30, 0, 0, time.UTC), date(2026, 3, 9), date(2026, 3, 16)},
+		{"week across the clock change", WindowWeek, date(2026, 3, 25), date(2026, 3, 23), date(2026, 3, 30)},
+		{"month", WindowMonth, time.Date(2026, 2, 28, 12, 0, 0, 0, berlin), date(2026, 2, 1), date(2026, 3, 1)},
+		{"year", WindowYear, time.Date(2026, 12, 31, 23, 0, 0, 0, berlin), date(2026, 1, 1), date(2027, 1, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, ok := WindowBounds(tt.window, tt.now, berlin)
+			if !ok || !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("got %s - %s (%v), want %s - %s", from, to, ok, tt.from, tt.to)
+			}
+		})
+	}
+
+	if _, _, ok := WindowBounds("decade", time.Now(), berlin); ok {
+		t.Error("unknown window accepted")
+	}
+}
+
+func TestRankMembers(t *testing.T) {
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	ada, bob, cy, dee := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	activity := []MemberActivity{
+		{UserID: bob, Name: "Bob", Duration: 3600, Sessions: 4, ActiveDates: []string{"2026-03-02", "2026-03-04"}},
+		{UserID: ada, Name: "Ada", Duration: 7200, Sessions: 2, ActiveDates: []string{"2026-03-02", "2026-03-03", "2026-03-04"}},
+		{UserID: cy, Name: "Cy", Duration: 3600, Sessions: 1, ActiveDates: []string{"2026-03-08"}},
+		{UserID: dee, Name: "Dee"},
+	}
+
+	tests := []struct {
+		metric string
+		goal   int64
+		order  []uuid.UUID
+		ranks  []int
+		values []int64
+		goals  []bool
+	}{
+		{MetricTime, 3600, []uuid.UUID{ada, bob, cy, dee}, []int{1, 2, 2, 4}, []int64{7200, 3600, 3600, 0}, []bool{true, true, true, false}},
+		{MetricSessions, 0, []uuid.UUID{bob, ada, cy, dee}, []int{1, 2, 3, 4}, []int64{4, 2, 1, 0}, []bool{false, false, false, false}},
+		{MetricStreak, 2, []uuid.UUID{ada, bob, cy, dee}, []int{1, 2, 2, 4}, []int64{3, 1, 1, 0}, []bool{true, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			board := RankMembers(tt.metric, activity, tt.goal, from, to, time.UTC, from.AddDate(0, 0, 3))
+			if board.Final || board.Metric != tt.metric || len(board.Standings) != len(tt.order) {
+				t.Fatalf("board = %+v", board)
+			}
+			for i, s := range board.Standings {
+				if s.UserID != tt.order[i] || s.Rank != tt.ranks[i] || s.Value != tt.values[i] || s.GoalReached != tt.goals[i] {
+					t.Errorf("standing %d = %+v, want %s ranked %d with %d (goal %v)", i, s, tt.order[i], tt.ranks[i], tt.values[i], tt.goals[i])
+				}
+			}
+		})
+	}
+
+	if board := RankMembers(MetricTime, nil, 0, from, to, time.UTC, to); !board.Final || board.Standings == nil {
+		t.Errorf("ended board = %+v, want final and no standings", board)
+	}
+}
+
+func TestChallengeFinalStandings(t *testing.T) {
+	challenge := &Challenge{ID: uuid.New(), Metric: MetricTime, StartTime: time.Now().Add(-time.Hour), EndTime: time.Now()}
+	live := &Leaderboard{Standings: []Standing{
+		{Rank: 1, UserID: uuid.New(), Name: "Ada", Value: 7200, GoalReached: true},
+		{Rank: 2, UserID: uuid.New(), Name: "Bob", Value: 0},
+	}}
+
+	results := live.Results(challenge.ID)
+	for i := range results {
+		if results[i].ChallengeID != challenge.ID {
+			t.Fatalf("result %d kept for challenge %s", i, results[i].ChallengeID)
+		}
+		results[i].UserName = live.Standings[i].Name
+	}
+	board := challenge.FinalStandings(results, time.UTC)
+	if !board.Final || board.Metric != MetricTime || !board.To.Equal(challenge.EndTime) {
+		t.Errorf("board = %+v", board)
+	}
+	for i, s := range board.Standings {
+		if s != live.Standings[i] {
+			t.Errorf("standing %d = %+v, want %+v", i, s, live.Standings[i])
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValidDigestSchedule reports whether weekday (1 for Monday to 7 for
+// Sunday) and hour can schedule a digest
+func ValidDigestSchedule(weekday, hour int) bool {
+	return weekday >= 1 && weekday <= 7 && hour >= 0 && hour <= 23
+}
+
+// DigestDue returns the week a digest sent at now covers, which is the ISO
+// week before the current one in loc. It is due from the user's weekday and
+// hour on, unless that week was already sent or predates the user.
+func (u *User) DigestDue(now time.Time, loc *time.Location) (from, to time.Time, week string, due bool) {
+	day := StartOfDay(now, loc)
+	to = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	from = to.AddDate(0, 0, -7)
+	year, number := from.ISOWeek()
+	week = fmt.Sprintf("%d-W%02d", year, number)
+
+	scheduled := to.AddDate(0, 0, u.DigestWeekday-1)
+	scheduled = time.Date(scheduled.Year(), scheduled.Month(), scheduled.Day(), u.DigestHour, 0, 0, 0, loc)
+	due = u.DigestEnabled && !now.Before(scheduled) && u.DigestSentWeek != week && u.CreatedAt.Before(to)
+	return from, to, week, due
+}
+
+// DigestNote is a record's text shown in a digest
+type DigestNote struct {
+	ProjectName string    `json:"project_name"`
+	Text        string    `json:"text"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Digest summarizes a user's week of tracked time for the weekly email
+type Digest struct {
+	User     *User
+	Week     string    // ISO week, e.g. 2026-W07
+	From     time.Time // start of the week in the user's time zone
+	To       time.Time // start of the next week
+	Duration int64     // in seconds
+	Sessions int64
+	Projects []ReportGroup // by tracked time, most first
+	Streak   Streak        // as of the end of the week
+	Notes    []DigestNote
+	Goals    []GoalProgress // in the periods containing the end of the week
+
+	UnsubscribeURL string
+}
+
+// Empty reports whether there is nothing to tell the user about
+func (d *Digest) Empty() bool {
+	return d.Sessions == 0 && len(d.Goals) == 0
+}
+
+// NewUnsubscribeToken returns a random secret for unsubscribe links
+func NewUnsubscribeToken() (string, error) {
+	return newToken()
+}
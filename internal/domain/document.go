@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Document kinds
+const (
+	DocumentTimesheet = "timesheet"
+	DocumentInvoice   = "invoice"
+)
+
+// Line item granularities
+const (
+	LineItemsPerSession = "session"
+	LineItemsPerDay     = "day" // one line per project and day
+)
+
+// Document is a generated PDF timesheet or invoice. Invoices are numbered
+// sequentially per issuer: the club a member invoices for, or else the user.
+type Document struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_documents_user_sequence,where:club_id IS NULL"`
+	ClubID     *uuid.UUID `json:"club_id,omitempty" gorm:"type:uuid;uniqueIndex:idx_documents_club_sequence"`
+	Kind       string     `json:"kind" gorm:"not null"`
+	Sequence   *int       `json:"-" gorm:"uniqueIndex:idx_documents_user_sequence;uniqueIndex:idx_documents_club_sequence"` // set for invoices only
+	Number     string     `json:"number,omitempty"`                                                                         // invoice number, e.g. INV-0007
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Timezone   string     `json:"timezone"`
+	Currency   string     `json:"currency,omitempty"`
+	Duration   int64      `json:"duration"`           // rounded seconds of all lines
+	Subtotal   int64      `json:"subtotal,omitempty"` // in minor units, e.g. cents
+	TaxRate    float64    `json:"tax_rate,omitempty"` // percent
+	Tax        int64      `json:"tax,omitempty"`
+	Total      int64      `json:"total,omitempty"`
+	Lines      int        `json:"lines"`
+	StorageKey string     `json:"-"`
+	Size       int64      `json:"size"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// DocumentKey is the file store key of a document's PDF
+func DocumentKey(id uuid.UUID) string {
+	return "documents/" + id.String() + ".pdf"
+}
+
+// Issuer is the club the document was issued for, or else its user
+func (d *Document) Issuer() uuid.UUID {
+	if d.ClubID != nil {
+		return *d.ClubID
+	}
+	return d.UserID
+}
+
+// InvoiceNumber formats the number of an issuer's seq-th invoice
+func InvoiceNumber(seq int) string {
+	return fmt.Sprintf("INV-%04d", seq)
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// InvoiceCounter holds the last invoice number of an issuer, a user or club
+type InvoiceCounter struct {
+	IssuerID uuid.UUID `gorm:"type:uuid;primary_key"`
+	Last     int       `gorm:"not null"`
+}
+
+// DocumentLine is one line item of a timesheet or invoice
+type DocumentLine struct {
+	Date        time.Time
+	ProjectID   uuid.UUID
+	ProjectName string
+	Description string
+	Duration    int64 // rounded seconds
+	Rate        int64 // minor units per hour
+	Amount      int64 // minor units
+}
+
+// Rate returns the hourly rate billed for the session: its project's rate,
+// or defaultRate when the project has none, which is the member's rate in
+// the club invoiced for or the user's own
+func (r *ExportRow) Rate(defaultRate int64) int64 {
+	if r.ProjectRate != nil {
+		return *r.ProjectRate
+	}
+	return defaultRate
+}
+
+// amount bills seconds at an hourly rate, rounding half cents up
+func amount(seconds, rate int64) int64 {
+	return (seconds*rate + 1800) / 3600
+}
+
+// BuildDocumentLines turns exported sessions into line items, one per
+// session or one per project and local day. Rounded durations are billed.
+func BuildDocumentLines(rows []ExportRow, lineItems string, loc *time.Location, defaultRate int64) []DocumentLine {
+	var lines []DocumentLine
+	if lineItems != LineItemsPerDay {
+		for _, row := range rows {
+			rate := row.Rate(defaultRate)
+			lines = append(lines, DocumentLine{
+				Date:        row.StartTime.In(loc),
+				ProjectID:   row.ProjectID,
+				ProjectName: row.ProjectName,
+				Description: row.FirstNote,
+				Duration:    row.RoundedDuration,
+				Rate:        rate,
+				Amount:      amount(row.RoundedDuration, rate),
+			})
+		}
+		return lines
+	}
+
+	type dayKey struct {
+		date    string
+		project uuid.UUID
+	}
+	index := map[dayKey]int{}
+	sessions := map[dayKey]int{}
+	for _, row := range rows {
+		start := row.StartTime.In(loc)
+		key := dayKey{start.Format("2006-01-02"), row.ProjectID}
+		i, ok := index[key]
+		if !ok {
+			i = len(lines)
+			index[key] = i
+			lines = append(lines, DocumentLine{
+				Date:        time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc),
+				ProjectID:   row.ProjectID,
+				ProjectName: row.ProjectName,
+				Rate:        row.Rate(defaultRate),
+			})
+		}
+		lines[i].Duration += row.RoundedDuration
+		sessions[key]++
+	}
+
+	for key, i := range index {
+		lines[i].Amount = amount(lines[i].Duration, lines[i].Rate)
+		if sessions[key] == 1 {
+			lines[i].Description = "1 session"
+		} else {
+			lines[i].Description = fmt.Sprintf("%d sessions", sessions[key])
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if !lines[i].Date.Equal(lines[j].Date) {
+			return lines[i].Date.Before(lines[j].Date)
+		}
+		return lines[i].ProjectName < lines[j].ProjectName
+	})
+	return lines
+}
+
+// ApplyLines sets the totals of a document from its line items. Only
+// invoices carry amounts; tax is rounded to the nearest minor unit.
+func (d *Document) ApplyLines(lines []DocumentLine) {
+	d.Lines = len(lines)
+	d.Duration, d.Subtotal = 0, 0
+	for _, line := range lines {
+		d.Duration += line.Duration
+		d.Subtotal += line.Amount
+	}
+	if d.Kind != DocumentInvoice {
+		d.Subtotal, d.TaxRate = 0, 0
+	}
+	d.Tax = int64(math.Round(float64(d.Subtotal) * d.TaxRate / 100))
+	d.Total = d.Subtotal + d.Tax
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBuildDocumentLinesRates(t *testing.T) {
+	projectRate := int64(12000)
+	own := ExportRow{ProjectID: uuid.New(), ProjectName: "Own rate", ProjectRate: &projectRate}
+	inherited := ExportRow{ProjectID: uuid.New(), ProjectName: "No rate"}
+
+	memberRate := int64(9000)
+	tests := []struct {
+		name       string
+		membership *ClubMembership
+		want       [2]int64 // rates of the two lines
+	}{
+		{"personal invoice", nil, [2]int64{12000, 5000}},
+		{"club without a member rate", &ClubMembership{}, [2]int64{12000, 5000}},
+		{"club with a member rate", &ClubMembership{HourlyRate: &memberRate}, [2]int64{12000, 9000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate := int64(5000) // the user's own
+			if tt.membership != nil {
+				rate = tt.membership.Rate(rate)
+			}
+
+			rows := []ExportRow{own, inherited}
+			for i := range rows {
+				rows[i].StartTime = time.Date(2026, 3, 2, 9+i, 0, 0, 0, time.UTC)
+				rows[i].RoundedDuration = 5400
+			}
+			lines := BuildDocumentLines(rows, LineItemsPerSession, time.UTC, rate)
+			if len(lines) != 2 {
+				t.Fatalf("got %d lines, want 2", len(lines))
+			}
+			for i, line := range lines {
+				if line.Rate != tt.want[i] || line.Amount != tt.want[i]*3/2 {
+					t.Errorf("line %d billed %d at %d, want %d at %d", i, line.Amount, line.Rate, tt.want[i]*3/2, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBuildDocumentLinesPerDay(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*3600)
+	project := uuid.New()
+	rows := []ExportRow{
+		// 23:30 UTC is the next local day
+		{ProjectID: project, ProjectName: "A", StartTime: time.Date(2026, 3, 2, 23, 30, 0, 0, time.UTC), RoundedDuration: 1800},
+		{ProjectID: project, ProjectName: "A", StartTime: time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC), RoundedDuration: 3600},
+		{ProjectID: project, ProjectName: "A", StartTime: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), RoundedDuration: 900},
+	}
+	lines := BuildDocumentLines(rows, LineItemsPerDay, loc, 6000)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if lines[0].Duration != 4500 || lines[0].Description != "2 sessions" || lines[0].Amount != 7500 || lines[0].Date.Day() != 2 {
+		t.Errorf("first day = %+v", lines[0])
+	}
+	if lines[1].Duration != 1800 || lines[1].Description != "1 session" || lines[1].Date.Day() != 3 {
+		t.Errorf("second day = %+v", lines[1])
+	}
+}
+
+func TestDocumentApplyLines(t *testing.T) {
+	lines := []DocumentLine{{Duration: 3600, Amount: 10000}, {Duration: 1800, Amount: 2555}}
+
+	invoice := Document{Kind: DocumentInvoice, TaxRate: 19}
+	invoice.ApplyLines(lines)
+	if invoice.Duration != 5400 || invoice.Subtotal != 12555 || invoice.Tax != 2385 || invoice.Total != 14940 || invoice.Lines != 2 {
+		t.Errorf("invoice = %+v", invoice)
+	}
+
+	timesheet := Document{Kind: DocumentTimesheet, TaxRate: 19}
+	timesheet.ApplyLines(lines)
+	if timesheet.Duration != 5400 || timesheet.Subtotal != 0 || timesheet.Tax != 0 || timesheet.Total != 0 {
+		t.Errorf("timesheet = %+v", timesheet)
+	}
+}
+
+func TestDocumentIssuer(t *testing.T) {
+	user, club := uuid.New(), uuid.New()
+	if got := (&Document{UserID: user}).Issuer(); got != user {
+		t.Errorf("personal document issued by %s, want the user", got)
+	}
+	if got := (&Document{UserID: user, ClubID: &club}).Issuer(); got != club {
+		t.Errorf("club document issued by %s, want the club", got)
+	}
+}
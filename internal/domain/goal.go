@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Goal kinds: targets are time to reach, budgets time not to exceed
+const (
+	GoalTarget = "target"
+	GoalBudget = "budget"
+)
+
+// Goal periods. Total goals count all time and may have a deadline.
+const (
+	GoalDay   = "day"
+	GoalWeek  = "week" // ISO 8601 week, starting on Monday
+	GoalMonth = "month"
+	GoalTotal = "total"
+)
+
+// Goal progress statuses
+const (
+	GoalOnTrack  = "on_track"
+	GoalBehind   = "behind"   // less tracked than the elapsed share of the period
+	GoalAchieved = "achieved" // target reached
+	GoalMissed   = "missed"   // deadline passed before the target was reached
+	GoalOK       = "ok"
+	GoalWarning  = "warning"  // a budget threshold below 100% was crossed
+	GoalExceeded = "exceeded" // budget used up
+)
+
+// DefaultBudgetThresholds are the percentages of a budget that notify the
+// user when none are given
+var DefaultBudgetThresholds = IntArray{80, 100}
+
+// maxBudgetThresholds bounds the thresholds of one budget
+const maxBudgetThresholds = 5
+
+// Goal is a target or budget of tracked time on a project, counted in
+// rounded durations in the user's time zone
+type Goal struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	ProjectID  uuid.UUID  `json:"project_id" gorm:"type:uuid;not null;index"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	Kind       string     `json:"kind" gorm:"not null"`
+	Period     string     `json:"period" gorm:"not null"`
+	Seconds    int64      `json:"seconds" gorm:"not null"`                    // time to reach or not to exceed per period
+	Deadline   *time.Time `json:"deadline,omitempty"`                         // total targets only
+	Thresholds IntArray   `json:"thresholds,omitempty" gorm:"type:integer[]"` // percentages that notify, budgets only
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	ProjectName string `json:"project_name,omitempty" gorm:"->;-:migration"` // read when listing goals
+}
+
+// Validate checks the goal's settings and orders its thresholds
+func (g *Goal) Validate() error {
+	verr := &ValidationError{}
+
+	if g.Kind != GoalTarget && g.Kind != GoalBudget {
+		verr.add("kind", "must be target or budget")
+	}
+	switch g.Period {
+	case GoalDay, GoalWeek, GoalMonth, GoalTotal:
+	default:
+		verr.add("period", "must be day, week, month or total")
+	}
+	if g.Seconds <= 0 {
+		verr.add("seconds", "must be positive")
+	}
+	if g.Deadline != nil && (g.Kind != GoalTarget || g.Period != GoalTotal) {
+		verr.add("deadline", "is only allowed on total targets")
+	}
+
+	if g.Kind == GoalBudget {
+		if len(g.Thresholds) == 0 {
+			g.Thresholds = append(IntArray{}, DefaultBudgetThresholds...)
+		}
+		sort.Ints(g.Thresholds)
+		if len(g.Thresholds) > maxBudgetThresholds {
+			verr.add("thresholds", "must have at most %d entries", maxBudgetThresholds)
+		}
+		for i, t := range g.Thresholds {
+			if t < 1 || t > 1000 {
+				verr.add("thresholds", "must be percentages between 1 and 1000")
+				break
+			}
+			if i > 0 && t == g.Thresholds[i-1] {
+				verr.add("thresholds", "must not repeat")
+				break
+			}
+		}
+	} else if len(g.Thresholds) > 0 {
+		verr.add("thresholds", "are only allowed on budgets")
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// PeriodBounds returns the local period containing now and a key naming it,
+// such as 2026-W07. Total goals have no bounds and the key "total".
+func (g *Goal) PeriodBounds(now time.Time, loc *time.Location) (start, end *time.Time, key string) {
+	local := now.In(loc)
+	day := StartOfDay(now, loc)
+
+	var from, to time.Time
+	switch g.Period {
+	case GoalDay:
+		from, to = day, day.AddDate(0, 0, 1)
+		key = from.Format("2006-01-02")
+	case GoalWeek:
+		from = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		to = from.AddDate(0, 0, 7)
+		year, week := from.ISOWeek()
+		key = fmt.Sprintf("%d-W%02d", year, week)
+	case GoalMonth:
+		from = time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+		to = from.AddDate(0, 1, 0)
+		key = from.Format("2006-01")
+	default:
+		return nil, nil, GoalTotal
+	}
+	return &from, &to, key
+}
+
+// GoalProgress is a goal with the time tracked towards it in its current
+// period
+type GoalProgress struct {
+	Goal
+	PeriodStart *time.Time `json:"period_start,omitempty"`
+	PeriodEnd   *time.Time `json:"period_end,omitempty"`
+	PeriodKey   string     `json:"period_key"`
+	Tracked     int64      `json:"tracked"`            // rounded seconds
+	Remaining   int64      `json:"remaining"`          // until the target is reached or the budget used up
+	Expected    int64      `json:"expected,omitempty"` // what a target needs by now to be on track
+	Percent     float64    `json:"percent"`
+	Status      string     `json:"status"`
+}
+
+// Progress evaluates the goal with the time tracked in its current period.
+// Targets are on track while they keep pace with the elapsed share of the
+// period, or of the time from creation to the deadline.
+func (g *Goal) Progress(tracked int64, now time.Time, loc *time.Location) GoalProgress {
+	p := GoalProgress{Goal: *g, Tracked: tracked}
+	p.PeriodStart, p.PeriodEnd, p.PeriodKey = g.PeriodBounds(now, loc)
+	p.Remaining = max(g.Seconds-tracked, 0)
+	p.Percent = float64(int64(float64(tracked)*10000/float64(g.Seconds))) / 100
+
+	if g.Kind == GoalBudget {
+		p.Status = GoalOK
+		if len(p.CrossedThresholds()) > 0 {
+			p.Status = GoalWarning
+		}
+		if tracked >= g.Seconds {
+			p.Status = GoalExceeded
+		}
+		return p
+	}
+
+	start, end := p.PeriodStart, p.PeriodEnd
+	if g.Deadline != nil {
+		start, end = &g.CreatedAt, g.Deadline
+	}
+	switch {
+	case tracked >= g.Seconds:
+		p.Status = GoalAchieved
+	case g.Deadline != nil && now.After(*g.Deadline):
+		p.Status = GoalMissed
+	case start == nil || end == nil || !end.After(*start):
+		p.Status = GoalOnTrack
+	default:
+		elapsed := float64(now.Sub(*start)) / float64(end.Sub(*start))
+		p.Expected = int64(float64(g.Seconds) * min(max(elapsed, 0), 1))
+		p.Status = GoalOnTrack
+		if tracked < p.Expected {
+			p.Status = GoalBehind
+		}
+	}
+	return p
+}
+
+// CrossedThresholds returns the budget thresholds reached by the tracked
+// time, lowest first
+func (p *GoalProgress) CrossedThresholds() []int {
+	var crossed []int
+	for _, t := range p.Thresholds {
+		if p.Tracked*100 >= int64(t)*p.Seconds {
+			crossed = append(crossed, t)
+		}
+	}
+	return crossed
+}
@@ -1,54 +1,252 @@
 package domain
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-	Email     string    `json:"email" gorm:"unique;not null"`
-	Password  string    `json:"-" gorm:"not null"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID               uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	Email            string    `json:"email" gorm:"unique;not null"`
+	Password         string    `json:"-" gorm:"not null"`
+	Name             string    `json:"name"`
+	OverlapPolicy    string    `json:"overlap_policy" gorm:"not null;default:warn"`  // allow, warn or reject
+	Timezone         string    `json:"timezone" gorm:"not null;default:UTC"`         // IANA name used for reports
+	HourlyRate       int64     `json:"hourly_rate" gorm:"not null;default:0"`        // default rate in minor units per hour
+	Currency         string    `json:"currency" gorm:"not null;default:EUR"`         // ISO 4217 code used on invoices
+	CalendarToken    *string   `json:"-" gorm:"uniqueIndex"`                         // secret of the calendar feed URL, nil when disabled
+	DigestEnabled    bool      `json:"digest_enabled" gorm:"not null;default:false"` // weekly summary email, opt-in
+	DigestWeekday    int       `json:"digest_weekday" gorm:"not null;default:1"`     // 1 for Monday to 7 for Sunday
+	DigestHour       int       `json:"digest_hour" gorm:"not null;default:8"`        // local hour the digest is sent at
+	DigestSentWeek   string    `json:"-" gorm:"not null;default:''"`                 // ISO week of the last digest sent, e.g. 2026-W07
+	UnsubscribeToken *string   `json:"-" gorm:"uniqueIndex"`                         // secret of unsubscribe links, made with the first digest
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 type WorkLog struct {
-	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	StartTime   time.Time `json:"start_time"`
-	EndTime     time.Time `json:"end_time,omitempty"`
-	Duration    int64     `json:"duration"` // in seconds
-	Status      string    `json:"status"`   // active, paused, completed
-	Tags        []string  `json:"tags" gorm:"type:text[]"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key"`
+	UserID      uuid.UUID      `json:"user_id" gorm:"type:uuid"`
+	ProjectID   *uuid.UUID     `json:"project_id,omitempty" gorm:"type:uuid;index"`
+	SessionID   *uuid.UUID     `json:"session_id,omitempty" gorm:"type:uuid"` // Session the log was converted into
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	StartTime   time.Time      `json:"start_time"`
+	EndTime     time.Time      `json:"end_time,omitempty"`
+	Duration    int64          `json:"duration"` // active time in seconds, excluding pauses
+	Status      string         `json:"status"`   // active, paused, completed
+	Tags        StringArray    `json:"tags" gorm:"type:text[]"`
+	Pauses      []WorkLogPause `json:"pauses,omitempty" gorm:"foreignKey:WorkLogID"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
 }
 
+// Work log statuses
+const (
+	WorkLogActive    = "active"
+	WorkLogPaused    = "paused"
+	WorkLogCompleted = "completed"
+)
+
 type LogEntry struct {
 	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-	WorkLogID uuid.UUID `json:"work_log_id" gorm:"type:uuid"`
-	Type      string    `json:"type"` // commit, note, voice, media
+	WorkLogID uuid.UUID `json:"work_log_id" gorm:"type:uuid;index"`
+	Type      string    `json:"type" gorm:"index"` // commit, note, voice, media, status
 	Content   string    `json:"content"`
 	Metadata  JSON      `json:"metadata" gorm:"type:jsonb"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Log entry types
+const (
+	LogEntryCommit = "commit"
+	LogEntryNote   = "note"
+	LogEntryVoice  = "voice"
+	LogEntryMedia  = "media"
+
+	// Written by the server for every status transition, read-only
+	LogEntryStatus = "status"
+)
+
+// ValidLogEntryType reports whether t is a log entry type clients may create
+func ValidLogEntryType(t string) bool {
+	switch t {
+	case LogEntryCommit, LogEntryNote, LogEntryVoice, LogEntryMedia:
+		return true
+	}
+	return false
+}
+
 type Project struct {
-	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	GitHubRepo  string    `json:"github_repo,omitempty"`
-	Sessions    []Session `json:"sessions" gorm:"foreignKey:ProjectID"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              uuid.UUID      `json:"id" gorm:"type:uuid;primary_key"`
+	UserID          uuid.UUID      `json:"user_id" gorm:"type:uuid"`
+	Name            string         `json:"name"`
+	Description     string         `json:"description"`
+	GitHubRepo      string         `json:"github_repo,omitempty"`
+	RoundingMode    string         `json:"rounding_mode,omitempty"`    // nearest, up or down; empty for none
+	RoundingMinutes int            `json:"rounding_minutes,omitempty"` // 1, 5, 6 or 15
+	HourlyRate      *int64         `json:"hourly_rate,omitempty"`      // minor units per hour, nil for the user's rate
+	Sessions        []Session      `json:"sessions" gorm:"foreignKey:ProjectID"`
+	Goals           []GoalProgress `json:"goals,omitempty" gorm:"-"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"` // Set while the project is in the trash
 }
 
 // JSON is a wrapper for handling JSONB in PostgreSQL
 type JSON map[string]interface{}
+
+func (j JSON) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	return json.Marshal(j)
+}
+
+func (j *JSON) Scan(value interface{}) error {
+	if value == nil {
+		*j = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("invalid JSON value")
+	}
+	return json.Unmarshal(data, j)
+}
+
+// StringArray is a []string stored as a PostgreSQL text[]
+type StringArray []string
+
+func (a StringArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, s := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for _, r := range s {
+			if r == '"' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+func (a *StringArray) Scan(value interface{}) error {
+	var literal string
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		literal = string(v)
+	case string:
+		literal = v
+	case []string:
+		*a = append(StringArray{}, v...)
+		return nil
+	default:
+		return errors.New("invalid string array value")
+	}
+
+	if len(literal) < 2 || literal[0] != '{' || literal[len(literal)-1] != '}' {
+		return errors.New("invalid string array literal")
+	}
+	literal = literal[1 : len(literal)-1]
+
+	result := StringArray{}
+	for len(literal) > 0 {
+		var elem strings.Builder
+		if literal[0] == '"' {
+			i := 1
+			for ; i < len(literal) && literal[i] != '"'; i++ {
+				if literal[i] == '\\' && i+1 < len(literal) {
+					i++
+				}
+				elem.WriteByte(literal[i])
+			}
+			if i >= len(literal) {
+				return errors.New("invalid string array literal")
+			}
+			literal = literal[i+1:]
+		} else {
+			end := strings.IndexByte(literal, ',')
+			if end < 0 {
+				end = len(literal)
+			}
+			elem.WriteString(literal[:end])
+			literal = literal[end:]
+		}
+		result = append(result, elem.String())
+		literal = strings.TrimPrefix(literal, ",")
+	}
+	*a = result
+	return nil
+}
+
+// IntArray is a []int stored as a PostgreSQL integer[]
+type IntArray []int
+
+func (a IntArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	parts := make([]string, len(a))
+	for i, n := range a {
+		parts[i] = strconv.Itoa(n)
+	}
+	return "{" + strings.Join(parts, ",") + "}", nil
+}
+
+func (a *IntArray) Scan(value interface{}) error {
+	var literal string
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		literal = string(v)
+	case string:
+		literal = v
+	default:
+		return errors.New("invalid integer array value")
+	}
+
+	if len(literal) < 2 || literal[0] != '{' || literal[len(literal)-1] != '}' {
+		return errors.New("invalid integer array literal")
+	}
+	result := IntArray{}
+	if literal = literal[1 : len(literal)-1]; literal != "" {
+		for _, part := range strings.Split(literal, ",") {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return errors.New("invalid integer array literal")
+			}
+			result = append(result, n)
+		}
+	}
+	*a = result
+	return nil
+}
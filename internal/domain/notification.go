@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification kinds
+const (
+	NotificationBudgetThreshold = "budget_threshold"
+)
+
+// Notification is an event shown to a user in the app. Key makes it unique
+// per user, so the same event is never reported twice.
+type Notification struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_notifications_user_key;index"`
+	Kind      string     `json:"kind" gorm:"not null"`
+	Key       string     `json:"-" gorm:"not null;uniqueIndex:idx_notifications_user_key"`
+	Message   string     `json:"message"`
+	Data      JSON       `json:"data" gorm:"type:jsonb"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index"`
+}
+
+// NewBudgetNotification reports that a budget reached threshold percent in
+// its current period
+func NewBudgetNotification(p *GoalProgress, threshold int, now time.Time) *Notification {
+	period := map[string]string{
+		GoalDay:   "daily ",
+		GoalWeek:  "weekly ",
+		GoalMonth: "monthly ",
+		GoalTotal: "",
+	}[p.Period]
+
+	message := fmt.Sprintf("%s has used %d%% of its %sbudget", p.ProjectName, threshold, period)
+	if threshold >= 100 {
+		message = fmt.Sprintf("%s has used up its %sbudget", p.ProjectName, period)
+		if threshold > 100 {
+			message = fmt.Sprintf("%s is at %d%% of its %sbudget", p.ProjectName, threshold, period)
+		}
+	}
+
+	return &Notification{
+		ID:      uuid.New(),
+		UserID:  p.UserID,
+		Kind:    NotificationBudgetThreshold,
+		Key:     fmt.Sprintf("goal:%s:%s:%d", p.ID, p.PeriodKey, threshold),
+		Message: message,
+		Data: JSON{
+			"goal_id":    p.ID,
+			"project_id": p.ProjectID,
+			"period":     p.PeriodKey,
+			"threshold":  threshold,
+			"tracked":    p.Tracked,
+			"budget":     p.Seconds,
+		},
+		CreatedAt: now,
+	}
+}
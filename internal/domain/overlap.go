@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Overlap policies decide what happens when a new or changed session
+// overlaps another session of the same user
+const (
+	OverlapAllow  = "allow"  // save silently
+	OverlapWarn   = "warn"   // save and return the conflicts
+	OverlapReject = "reject" // refuse with the conflicts
+)
+
+// ValidOverlapPolicy reports whether p is a known overlap policy
+func ValidOverlapPolicy(p string) bool {
+	return p == OverlapAllow || p == OverlapWarn || p == OverlapReject
+}
+
+// SessionConflict is another session overlapping the one being saved
+type SessionConflict struct {
+	SessionID uuid.UUID `json:"session_id"`
+	ProjectID uuid.UUID `json:"project_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Overlap   int64     `json:"overlap"` // in seconds
+}
+
+// FindConflicts lists the sessions in others that overlap s. Sessions that only
+// touch at their boundaries do not overlap.
+func (s *Session) FindConflicts(others []Session) []SessionConflict {
+	var conflicts []SessionConflict
+	for _, other := range others {
+		if other.ID == s.ID {
+			continue
+		}
+		start, end := other.StartTime, other.EndTime
+		if s.StartTime.After(start) {
+			start = s.StartTime
+		}
+		if s.EndTime.Before(end) {
+			end = s.EndTime
+		}
+		if !end.After(start) {
+			continue
+		}
+		conflicts = append(conflicts, SessionConflict{
+			SessionID: other.ID,
+			ProjectID: other.ProjectID,
+			StartTime: other.StartTime,
+			EndTime:   other.EndTime,
+			Overlap:   int64(end.Sub(start) / time.Second),
+		})
+	}
+	return conflicts
+}
+
+// DurationBetween returns the part of the session's duration falling between
+// start and end. Pauses are not tracked, so the duration is taken to be
+// spread evenly over the session's interval.
+func (s *Session) DurationBetween(start, end time.Time) int64 {
+	interval := int64(s.EndTime.Sub(s.StartTime) / time.Second)
+	if interval <= 0 {
+		return 0
+	}
+	return overlapSeconds(s.StartTime, s.EndTime, start, end) * s.Duration / interval
+}
+
+// Overlap resolution strategies
+const (
+	ResolveTrim  = "trim"  // shorten conflicting sessions
+	ResolveSplit = "split" // like trim, but split sessions covering the target
+)
+
+// Overlap adjustment actions
+const (
+	AdjustTrimmed    = "trimmed"
+	AdjustSplit      = "split"
+	AdjustUnresolved = "unresolved" // lies entirely within the target
+)
+
+// OverlapAdjustment is the change made to one conflicting session. For
+// splits, the part after the target becomes a new session holding the
+// records from NewStartTime on. Records timestamped in the time given up
+// move to the target. Durations keep the share of the session's duration
+// falling into the new times.
+type OverlapAdjustment struct {
+	SessionID      uuid.UUID   `json:"session_id"`
+	Action         string      `json:"action"`
+	StartTime      time.Time   `json:"start_time"`
+	EndTime        time.Time   `json:"end_time"`
+	Duration       int64       `json:"duration"` // in seconds
+	NewSessionID   *uuid.UUID  `json:"new_session_id,omitempty"`
+	NewStartTime   *time.Time  `json:"new_start_time,omitempty"`
+	NewEndTime     *time.Time  `json:"new_end_time,omitempty"`
+	NewDuration    *int64      `json:"new_duration,omitempty"`
+	MovedRecordIDs []uuid.UUID `json:"moved_record_ids,omitempty"` // set once applied
+}
+
+// PlanOverlapResolution works out how to change others so none of them
+// overlaps target any more. The target itself is never changed.
+func PlanOverlapResolution(target Session, others []Session, strategy string) []OverlapAdjustment {
+	var adjustments []OverlapAdjustment
+	for _, other := range others {
+		if other.ID == target.ID || !other.StartTime.Before(target.EndTime) || !other.EndTime.After(target.StartTime) {
+			continue
+		}
+
+		adjustment := OverlapAdjustment{
+			SessionID: other.ID,
+			Action:    AdjustTrimmed,
+			StartTime: other.StartTime,
+			EndTime:   other.EndTime,
+		}
+		startsBefore := other.StartTime.Before(target.StartTime)
+		endsAfter := other.EndTime.After(target.EndTime)
+
+		switch {
+		case startsBefore && endsAfter && strategy == ResolveSplit:
+			id := uuid.New()
+			newStart, newEnd := target.EndTime, other.EndTime
+			newDuration := other.DurationBetween(newStart, newEnd)
+			adjustment.Action = AdjustSplit
+			adjustment.EndTime = target.StartTime
+			adjustment.NewSessionID = &id
+			adjustment.NewStartTime = &newStart
+			adjustment.NewEndTime = &newEnd
+			adjustment.NewDuration = &newDuration
+		case startsBefore && endsAfter:
+			// Keep the longer side of a session covering the target
+			if target.StartTime.Sub(other.StartTime) >= other.EndTime.Sub(target.EndTime) {
+				adjustment.EndTime = target.StartTime
+			} else {
+				adjustment.StartTime = target.EndTime
+			}
+		case startsBefore:
+			adjustment.EndTime = target.StartTime
+		case endsAfter:
+			adjustment.StartTime = target.EndTime
+		default:
+			adjustment.Action = AdjustUnresolved
+		}
+		adjustment.Duration = other.Duration
+		if adjustment.Action != AdjustUnresolved {
+			adjustment.Duration = other.DurationBetween(adjustment.StartTime, adjustment.EndTime)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	return adjustments
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var overlapBase = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// minute returns the time minutes after 9:00
+func minute(minutes int) time.Time {
+	return overlapBase.Add(time.Duration(minutes) * time.Minute)
+}
+
+func testSession(start, end int, duration int64) Session {
+	return Session{ID: uuid.New(), StartTime: minute(start), EndTime: minute(end), Duration: duration}
+}
+
+func TestPlanOverlapResolution(t *testing.T) {
+	target := testSession(60, 120, 3600)
+
+	tests := []struct {
+		name         string
+		other        Session
+		strategy     string
+		action       string
+		start, end   int
+		duration     int64
+		newStart     int
+		newEnd       int
+		newDuration  int64
+		hasNewResult bool
+	}{
+		{
+			name:  "ends inside, unpaused",
+			other: testSession(0, 90, 5400), strategy: ResolveTrim,
+			action: AdjustTrimmed, start: 0, end: 60, duration: 3600,
+		},
+		{
+			name:  "ends inside, half paused",
+			other: testSession(0, 90, 2700), strategy: ResolveTrim,
+			action: AdjustTrimmed, start: 0, end: 60, duration: 1800,
+		},
+		{
+			name:  "starts inside",
+			other: testSession(90, 180, 2700), strategy: ResolveTrim,
+			action: AdjustTrimmed, start: 120, end: 180, duration: 1800,
+		},
+		{
+			name:  "covers target, trim keeps the longer side",
+			other: testSession(30, 150, 3600), strategy: ResolveTrim,
+			action: AdjustTrimmed, start: 30, end: 60, duration: 900,
+		},
+		{
+			name:  "covers target, split",
+			other: testSession(0, 240, 7200), strategy: ResolveSplit,
+			action: AdjustSplit, start: 0, end: 60, duration: 1800,
+			newStart: 120, newEnd: 240, newDuration: 3600, hasNewResult: true,
+		},
+		{
+			name:  "within target",
+			other: testSession(70, 80, 300), strategy: ResolveSplit,
+			action: AdjustUnresolved, start: 70, end: 80, duration: 300,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adjustments := PlanOverlapResolution(target, []Session{tt.other}, tt.strategy)
+			if len(adjustments) != 1 {
+				t.Fatalf("got %d adjustments, want 1", len(adjustments))
+			}
+			adj := adjustments[0]
+			if adj.SessionID != tt.other.ID || adj.Action != tt.action {
+				t.Fatalf("adjustment = %+v, want action %s", adj, tt.action)
+			}
+			if !adj.StartTime.Equal(minute(tt.start)) || !adj.EndTime.Equal(minute(tt.end)) || adj.Duration != tt.duration {
+				t.Errorf("kept %s-%s for %ds, want %s-%s for %ds",
+					adj.StartTime.Format("15:04"), adj.EndTime.Format("15:04"), adj.Duration,
+					minute(tt.start).Format("15:04"), minute(tt.end).Format("15:04"), tt.duration)
+			}
+			if !tt.hasNewResult {
+				if adj.NewSessionID != nil {
+					t.Errorf("unexpected new session %+v", adj)
+				}
+				return
+			}
+			if adj.NewSessionID == nil || !adj.NewStartTime.Equal(minute(tt.newStart)) ||
+				!adj.NewEndTime.Equal(minute(tt.newEnd)) || *adj.NewDuration != tt.newDuration {
+				t.Errorf("new part = %+v, want %d-%d for %ds", adj, tt.newStart, tt.newEnd, tt.newDuration)
+			}
+		})
+	}
+}
+
+func TestPlanOverlapResolutionSkipsNonOverlapping(t *testing.T) {
+	target := testSession(60, 120, 3600)
+	others := []Session{target, testSession(0, 60, 3600), testSession(120, 180, 3600)}
+	if adjustments := PlanOverlapResolution(target, others, ResolveTrim); len(adjustments) != 0 {
+		t.Fatalf("got %+v, want no adjustments", adjustments)
+	}
+}
+
+func TestDurationBetween(t *testing.T) {
+	s := testSession(0, 100, 50*60)
+	tests := []struct {
+		start, end int
+		want       int64
+	}{
+		{0, 100, 3000},
+		{0, 50, 1500},
+		{-10, 10, 300},
+		{100, 200, 0},
+	}
+	for _, tt := range tests {
+		if got := s.DurationBetween(minute(tt.start), minute(tt.end)); got != tt.want {
+			t.Errorf("DurationBetween(%d, %d) = %d, want %d", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	empty := testSession(10, 10, 0)
+	if got := empty.DurationBetween(minute(0), minute(20)); got != 0 {
+		t.Errorf("empty interval: got %d, want 0", got)
+	}
+}
@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Plan statuses, comparing planned with tracked time
+const (
+	PlanUpcoming = "upcoming" // not over yet
+	PlanSkipped  = "skipped"  // nothing tracked
+	PlanPartial  = "partial"  // less tracked than planned
+	PlanDone     = "done"
+	PlanExceeded = "exceeded" // more tracked than planned
+)
+
+// maxPlanTolerance caps how far tracked time may miss the plan and still be
+// done, which is otherwise a tenth of the planned time
+const maxPlanTolerance = 15 * 60
+
+// PlannedBlock is time a user schedules to work on a project
+type PlannedBlock struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index:idx_planned_blocks_user_start"`
+	ProjectID uuid.UUID `json:"project_id" gorm:"type:uuid;not null;index"`
+	StartTime time.Time `json:"start_time" gorm:"not null;index:idx_planned_blocks_user_start"`
+	EndTime   time.Time `json:"end_time" gorm:"not null"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectName string `json:"project_name,omitempty" gorm:"->;-:migration"` // read when listing blocks
+}
+
+// Validate checks the block's times. New blocks must not have ended yet,
+// since plans are made ahead.
+func (b *PlannedBlock) Validate(now time.Time, created bool) error {
+	verr := &ValidationError{}
+
+	if b.StartTime.Before(earliestSessionTime) {
+		verr.add("start_time", "must be after %s", earliestSessionTime.Format(time.RFC3339))
+	}
+	interval := b.EndTime.Sub(b.StartTime)
+	if interval <= 0 {
+		verr.add("end_time", "must be after start_time")
+	} else if interval > MaxSessionDuration {
+		verr.add("end_time", "block must not be longer than %s", MaxSessionDuration)
+	}
+	if created && !b.EndTime.After(now) {
+		verr.add("end_time", "must be in the future")
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// Duration returns the planned time in seconds
+func (b *PlannedBlock) Duration() int64 {
+	return int64(b.EndTime.Sub(b.StartTime) / time.Second)
+}
+
+// BlockComparison is a planned block with the time tracked on its project
+// while it was planned. Overrun is tracked time of the same sessions just
+// before or after the block.
+type BlockComparison struct {
+	PlannedBlock
+	Planned int64  `json:"planned"` // in seconds
+	Tracked int64  `json:"tracked"`
+	Overrun int64  `json:"overrun"`
+	Status  string `json:"status"`
+}
+
+// PlanDay compares the time planned on a project for one local day with
+// all the time tracked on it that day, planned or not
+type PlanDay struct {
+	Date        string    `json:"date"`
+	ProjectID   uuid.UUID `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	Planned     int64     `json:"planned"` // in seconds
+	Tracked     int64     `json:"tracked"`
+	Blocks      int       `json:"blocks"`
+	Status      string    `json:"status"`
+}
+
+// PlanComparison is planned against tracked time over a range
+type PlanComparison struct {
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	Timezone string            `json:"timezone"`
+	Planned  int64             `json:"planned"`
+	Tracked  int64             `json:"tracked"` // within planned blocks
+	Blocks   []BlockComparison `json:"blocks"`
+	Days     []PlanDay         `json:"days"`
+	Statuses map[string]int    `json:"statuses"` // number of blocks by status
+}
+
+// ComparePlans compares the blocks with the sessions overlapping them. Days
+// are split in loc, and blocks not over at now are upcoming. A session's
+// duration is spread evenly over its interval, so paused time is not tracked.
+func ComparePlans(blocks []PlannedBlock, sessions []Session, from, to time.Time, loc *time.Location, now time.Time) *PlanComparison {
+	comparison := &PlanComparison{
+		From:     from,
+		To:       to,
+		Timezone: loc.String(),
+		Blocks:   []BlockComparison{},
+		Days:     []PlanDay{},
+		Statuses: map[string]int{},
+	}
+
+	byProject := make(map[uuid.UUID][]Session)
+	for _, session := range sessions {
+		byProject[session.ProjectID] = append(byProject[session.ProjectID], session)
+	}
+
+	type dayKey struct {
+		date      string
+		projectID uuid.UUID
+	}
+	days := make(map[dayKey]*PlanDay)
+	var order []dayKey
+
+	for _, block := range blocks {
+		bc := BlockComparison{PlannedBlock: block, Planned: block.Duration()}
+		for _, session := range byProject[block.ProjectID] {
+			inside := session.DurationBetween(block.StartTime, block.EndTime)
+			if inside == 0 {
+				continue
+			}
+			bc.Tracked += inside
+			bc.Overrun += session.Duration - inside
+		}
+		bc.Status = planStatus(bc.Planned, bc.Tracked, bc.Tracked+bc.Overrun, block.EndTime.After(now))
+		comparison.Blocks = append(comparison.Blocks, bc)
+		comparison.Statuses[bc.Status]++
+		comparison.Planned += bc.Planned
+		comparison.Tracked += bc.Tracked
+
+		// Blocks spanning midnight count towards both days
+		for dayStart := StartOfDay(block.StartTime, loc); dayStart.Before(block.EndTime); dayStart = dayStart.AddDate(0, 0, 1) {
+			key := dayKey{dayStart.Format(dateLayout), block.ProjectID}
+			day, ok := days[key]
+			if !ok {
+				day = &PlanDay{Date: key.date, ProjectID: block.ProjectID, ProjectName: block.ProjectName}
+				for _, session := range byProject[block.ProjectID] {
+					day.Tracked += session.DurationBetween(dayStart, dayStart.AddDate(0, 0, 1))
+				}
+				days[key] = day
+				order = append(order, key)
+			}
+			day.Planned += overlapSeconds(block.StartTime, block.EndTime, dayStart, dayStart.AddDate(0, 0, 1))
+			day.Blocks++
+			if block.EndTime.After(now) {
+				day.Status = PlanUpcoming
+			}
+		}
+	}
+
+	for _, key := range order {
+		day := days[key]
+		if day.Status != PlanUpcoming {
+			day.Status = planStatus(day.Planned, day.Tracked, day.Tracked, false)
+		}
+		comparison.Days = append(comparison.Days, *day)
+	}
+	sort.SliceStable(comparison.Days, func(i, j int) bool {
+		if comparison.Days[i].Date != comparison.Days[j].Date {
+			return comparison.Days[i].Date < comparison.Days[j].Date
+		}
+		return comparison.Days[i].ProjectName < comparison.Days[j].ProjectName
+	})
+
+	return comparison
+}
+
+// planStatus rates tracked against planned time. Within is the part of the
+// tracked time spent while planned, total all of it.
+func planStatus(planned, within, total int64, upcoming bool) string {
+	tolerance := min(planned/10, maxPlanTolerance)
+	switch {
+	case upcoming:
+		return PlanUpcoming
+	case within == 0:
+		return PlanSkipped
+	case total > planned+tolerance:
+		return PlanExceeded
+	case within < planned-tolerance:
+		return PlanPartial
+	default:
+		return PlanDone
+	}
+}
+
+// overlapSeconds returns how long the intervals a and b overlap
+func overlapSeconds(aStart, aEnd, bStart, bEnd time.Time) int64 {
+	if bStart.After(aStart) {
+		aStart = bStart
+	}
+	if bEnd.Before(aEnd) {
+		aEnd = bEnd
+	}
+	if !aEnd.After(aStart) {
+		return 0
+	}
+	return int64(aEnd.Sub(aStart) / time.Second)
+}
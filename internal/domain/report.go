@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Report groupings
+const (
+	GroupByProject = "project"
+	GroupByDay     = "day"
+	GroupByWeek    = "week" // ISO 8601 week
+	GroupByMonth   = "month"
+	GroupByTag     = "tag"
+)
+
+// ValidReportGrouping reports whether groupBy names a supported grouping
+func ValidReportGrouping(groupBy string) bool {
+	switch groupBy {
+	case GroupByProject, GroupByDay, GroupByWeek, GroupByMonth, GroupByTag:
+		return true
+	}
+	return false
+}
+
+// StartOfDay returns the start of t's day in loc
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// ReportGroup is the tracked time of one project, day, week, month or tag
+type ReportGroup struct {
+	Key             string `json:"key"`              // project ID, 2024-02-13, 2024-W07, 2024-02 or tag
+	Label           string `json:"label"`            // project name, otherwise the key
+	Duration        int64  `json:"duration"`         // in seconds
+	RoundedDuration int64  `json:"rounded_duration"` // after each project's rounding rule
+	Sessions        int64  `json:"sessions"`
+}
+
+// ReportSummary totals the sessions in a time range. Sessions count towards
+// the day, week and month they start in. Sessions with several tags appear
+// in each of their groups, so group totals may exceed the overall total.
+type ReportSummary struct {
+	GroupBy         string        `json:"group_by"`
+	From            time.Time     `json:"from"`
+	To              time.Time     `json:"to"`
+	Timezone        string        `json:"timezone"`
+	Duration        int64         `json:"duration"`
+	RoundedDuration int64         `json:"rounded_duration"`
+	Sessions        int64         `json:"sessions"`
+	Groups          []ReportGroup `json:"groups"`
+}
+
+// ExportRow is one session of a timesheet export
+type ExportRow struct {
+	SessionID       uuid.UUID
+	ProjectID       uuid.UUID
+	ProjectName     string
+	StartTime       time.Time
+	EndTime         time.Time
+	Duration        int64 // in seconds
+	RoundedDuration int64
+	Manual          bool
+	Tags            StringArray
+	Records         int64
+	FirstNote       string // text of the earliest record that has any
+	ProjectRate     *int64 // hourly rate of the project, if it has its own
+}
@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of entities with a revision history
+const (
+	EntityProject = "project"
+	EntitySession = "session"
+	EntityRecord  = "record"
+)
+
+// Revision actions
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore" // brought back from the trash
+	RevisionRevert  = "revert"
+)
+
+// Revision is an immutable snapshot of one change to a project, session or
+// record. Before is empty for creations and After for deletions.
+type Revision struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	EntityType   string     `json:"entity_type" gorm:"not null;index:idx_revisions_entity"`
+	EntityID     uuid.UUID  `json:"entity_id" gorm:"type:uuid;not null;index:idx_revisions_entity"`
+	ActorID      *uuid.UUID `json:"actor_id,omitempty" gorm:"type:uuid"` // nil for changes made by the server itself
+	Action       string     `json:"action" gorm:"not null"`
+	Before       JSON       `json:"before,omitempty" gorm:"type:jsonb"`
+	After        JSON       `json:"after,omitempty" gorm:"type:jsonb"`
+	Changes      JSON       `json:"changes,omitempty" gorm:"type:jsonb"`      // field -> {before, after}
+	RevertedFrom *uuid.UUID `json:"reverted_from,omitempty" gorm:"type:uuid"` // revision restored by a revert
+	CreatedAt    time.Time  `json:"created_at" gorm:"index"`
+}
+
+// NewRevision builds a revision with the changes between before and after
+func NewRevision(entityType string, entityID uuid.UUID, action string, actorID *uuid.UUID, before, after JSON) *Revision {
+	return &Revision{
+		ID:         uuid.New(),
+		EntityType: entityType,
+		EntityID:   entityID,
+		ActorID:    actorID,
+		Action:     action,
+		Before:     before,
+		After:      after,
+		Changes:    DiffSnapshots(before, after),
+		CreatedAt:  time.Now(),
+	}
+}
+
+// DiffSnapshots returns the fields that differ between two snapshots, each
+// as {"before": ..., "after": ...}
+func DiffSnapshots(before, after JSON) JSON {
+	changes := JSON{}
+	for field, value := range after {
+		if old, ok := before[field]; !ok || !reflect.DeepEqual(old, value) {
+			changes[field] = map[string]interface{}{"before": before[field], "after": value}
+		}
+	}
+	for field, old := range before {
+		if _, ok := after[field]; !ok {
+			changes[field] = map[string]interface{}{"before": old, "after": nil}
+		}
+	}
+	return changes
+}
+
+// The fields kept in revisions. Bulky data such as audio and file contents
+// is left out; it is immutable once uploaded.
+type projectSnapshot struct {
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	RoundingMode    string `json:"rounding_mode"`
+	RoundingMinutes int    `json:"rounding_minutes"`
+	HourlyRate      *int64 `json:"hourly_rate"`
+}
+
+type sessionSnapshot struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Duration  int64     `json:"duration"`
+	Manual    bool      `json:"manual"`
+	Tags      []string  `json:"tags"`
+}
+
+type recordSnapshot struct {
+	SessionID        uuid.UUID `json:"session_id"`
+	Text             string    `json:"text"`
+	GitLink          string    `json:"git_link"`
+	Timestamp        time.Time `json:"timestamp"`
+	TranscriptStatus string    `json:"transcript_status"`
+	Transcript       string    `json:"transcript"`
+}
+
+// toJSON round-trips a snapshot through encoding/json so snapshots taken
+// in memory compare equal to ones loaded from the database
+func toJSON(v interface{}) JSON {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var snapshot JSON
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+func fromJSON(snapshot JSON, v interface{}) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Snapshot returns the revisioned fields of the project
+func (p *Project) Snapshot() JSON {
+	return toJSON(projectSnapshot{
+		Name:            p.Name,
+		Description:     p.Description,
+		RoundingMode:    p.RoundingMode,
+		RoundingMinutes: p.RoundingMinutes,
+		HourlyRate:      p.HourlyRate,
+	})
+}
+
+// Snapshot returns the revisioned fields of the session
+func (s *Session) Snapshot() JSON {
+	return toJSON(sessionSnapshot{
+		ProjectID: s.ProjectID,
+		StartTime: s.StartTime.UTC(),
+		EndTime:   s.EndTime.UTC(),
+		Duration:  s.Duration,
+		Manual:    s.Manual,
+		Tags:      s.Tags,
+	})
+}
+
+// RestoreSnapshot sets the session's times and tags back to those of a
+// snapshot. The project is kept, since moving a session has its own
+// endpoint.
+func (s *Session) RestoreSnapshot(snapshot JSON) error {
+	var snap sessionSnapshot
+	if err := fromJSON(snapshot, &snap); err != nil {
+		return err
+	}
+	s.StartTime = snap.StartTime
+	s.EndTime = snap.EndTime
+	s.Duration = snap.Duration
+	s.Manual = snap.Manual
+	s.Tags = snap.Tags
+	return nil
+}
+
+// Snapshot returns the revisioned fields of the record
+func (r *Record) Snapshot() JSON {
+	return toJSON(recordSnapshot{
+		SessionID:        r.SessionID,
+		Text:             r.Text,
+		GitLink:          r.GitLink,
+		Timestamp:        r.Timestamp.UTC(),
+		TranscriptStatus: r.TranscriptStatus,
+		Transcript:       r.Transcript,
+	})
+}
+
+// RestoreSnapshot sets the record's text, link and timestamp back to those
+// of a snapshot. Transcripts belong to the audio and are left alone.
+func (r *Record) RestoreSnapshot(snapshot JSON) error {
+	var snap recordSnapshot
+	if err := fromJSON(snapshot, &snap); err != nil {
+		return err
+	}
+	r.Text = snap.Text
+	r.GitLink = snap.GitLink
+	r.Timestamp = snap.Timestamp
+	return nil
+}
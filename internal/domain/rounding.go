@@ -0,0 +1,66 @@
+package domain
+
+// Rounding modes for reported durations. Raw durations are always kept;
+// rounding only applies to what is reported and billed.
+const (
+	RoundNone    = ""
+	RoundNearest = "nearest"
+	RoundUp      = "up"
+	RoundDown    = "down"
+)
+
+// RoundingIncrements are the allowed rounding steps in minutes
+var RoundingIncrements = []int{1, 5, 6, 15}
+
+// ValidRounding reports whether mode and minutes form a supported rounding
+// rule. Without a mode, minutes must be zero.
+func ValidRounding(mode string, minutes int) bool {
+	switch mode {
+	case RoundNone:
+		return minutes == 0
+	case RoundNearest, RoundUp, RoundDown:
+		for _, increment := range RoundingIncrements {
+			if minutes == increment {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RoundDuration rounds a duration in seconds to a multiple of minutes
+// using mode. Halfway values round up with RoundNearest.
+func RoundDuration(seconds int64, mode string, minutes int) int64 {
+	step := int64(minutes) * 60
+	if mode == RoundNone || step <= 0 || seconds <= 0 {
+		return seconds
+	}
+
+	remainder := seconds % step
+	if remainder == 0 {
+		return seconds
+	}
+	switch mode {
+	case RoundUp:
+		return seconds - remainder + step
+	case RoundDown:
+		return seconds - remainder
+	default:
+		if remainder*2 >= step {
+			return seconds - remainder + step
+		}
+		return seconds - remainder
+	}
+}
+
+// RoundDuration rounds a duration in seconds with the project's rule
+func (p *Project) RoundDuration(seconds int64) int64 {
+	return RoundDuration(seconds, p.RoundingMode, p.RoundingMinutes)
+}
+
+// ApplyRounding sets RoundedDuration on the given sessions of the project
+func (p *Project) ApplyRounding(sessions []Session) {
+	for i := range sessions {
+		sessions[i].RoundedDuration = p.RoundDuration(sessions[i].Duration)
+	}
+}
@@ -0,0 +1,54 @@
+package domain
+
+import "testing"
+
+func TestRoundDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds int64
+		mode    string
+		minutes int
+		want    int64
+	}{
+		{"none keeps seconds", 61, RoundNone, 0, 61},
+		{"zero step keeps seconds", 61, RoundUp, 0, 61},
+		{"zero duration", 0, RoundUp, 15, 0},
+		{"negative duration", -30, RoundUp, 15, -30},
+		{"exact multiple", 900, RoundUp, 15, 900},
+		{"up", 901, RoundUp, 15, 1800},
+		{"down", 1799, RoundDown, 15, 900},
+		{"down below one step", 100, RoundDown, 5, 0},
+		{"nearest below half", 449, RoundNearest, 15, 0},
+		{"nearest at half", 450, RoundNearest, 15, 900},
+		{"nearest above half", 1400, RoundNearest, 15, 1800},
+		{"six minutes", 7*60 + 1, RoundUp, 6, 12 * 60},
+		{"one minute", 90, RoundNearest, 1, 120},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoundDuration(tt.seconds, tt.mode, tt.minutes); got != tt.want {
+				t.Errorf("RoundDuration(%d, %q, %d) = %d, want %d", tt.seconds, tt.mode, tt.minutes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidRounding(t *testing.T) {
+	tests := []struct {
+		mode    string
+		minutes int
+		want    bool
+	}{
+		{RoundNone, 0, true},
+		{RoundNone, 15, false},
+		{RoundNearest, 15, true},
+		{RoundUp, 6, true},
+		{RoundDown, 10, false},
+		{"sideways", 15, false},
+	}
+	for _, tt := range tests {
+		if got := ValidRounding(tt.mode, tt.minutes); got != tt.want {
+			t.Errorf("ValidRounding(%q, %d) = %v, want %v", tt.mode, tt.minutes, got, tt.want)
+		}
+	}
+}
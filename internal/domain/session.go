@@ -1,45 +1,126 @@
 package domain
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Session struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-	ProjectID uuid.UUID `json:"project_id" gorm:"type:uuid"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time,omitempty"`
-	Duration  int64     `json:"duration"` // Duration in milliseconds
-	Records   []Record  `json:"records,omitempty" gorm:"foreignKey:SessionID"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uuid.UUID         `json:"id" gorm:"type:uuid;primary_key"`
+	ProjectID       uuid.UUID         `json:"project_id" gorm:"type:uuid;uniqueIndex:idx_sessions_project_ical_uid"`
+	StartTime       time.Time         `json:"start_time"`
+	EndTime         time.Time         `json:"end_time,omitempty"`
+	Duration        int64             `json:"duration"`                  // Duration in seconds
+	RoundedDuration int64             `json:"rounded_duration" gorm:"-"` // Duration after the project's rounding rule
+	Manual          bool              `json:"manual"`                    // Entered by hand rather than tracked
+	Tags            StringArray       `json:"tags" gorm:"type:text[]"`
+	ICalUID         *string           `json:"ical_uid,omitempty" gorm:"column:ical_uid;uniqueIndex:idx_sessions_project_ical_uid"` // UID of the calendar event it was imported from
+	Records         []Record          `json:"records,omitempty" gorm:"foreignKey:SessionID"`
+	Conflicts       []SessionConflict `json:"conflicts,omitempty" gorm:"-"` // Overlapping sessions found when saving
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt    `json:"deleted_at,omitempty" gorm:"index"` // Set while the session is in the trash
 }
 
 // Record represents a record of a session
 type Record struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	SessionID uuid.UUID `json:"session_id" gorm:"type:uuid;not null"`
-	Text      string    `json:"text"`
-	GitLink   string    `json:"git_link"`
-	AudioURL  string    `json:"audio_url"`
-	AudioData []byte    `json:"audio_data" gorm:"type:bytea"` // Actual audio data
-	Timestamp time.Time `json:"timestamp" gorm:"not null;default:now()"`
-	Files     []File    `json:"files" gorm:"foreignKey:RecordID"`
-	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now()"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:now()"`
+	ID               uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SessionID        uuid.UUID       `json:"session_id" gorm:"type:uuid;not null"`
+	Text             string          `json:"text"`
+	GitLink          string          `json:"git_link"`
+	AudioURL         string          `json:"audio_url"`
+	AudioData        []byte          `json:"audio_data" gorm:"type:bytea"` // Actual audio data
+	AudioFormat      string          `json:"audio_format,omitempty"`       // Container format, e.g. mp3, webm
+	AudioCodec       string          `json:"audio_codec,omitempty"`        // Codec inside the container
+	AudioDuration    int64           `json:"audio_duration,omitempty"`     // Duration in milliseconds
+	AudioSampleRate  int             `json:"audio_sample_rate,omitempty"`  // Samples per second
+	AudioChannels    int             `json:"audio_channels,omitempty"`
+	AudioPeaks       []byte          `json:"-" gorm:"type:bytea"`         // Waveform peaks scaled to 0-255
+	TranscriptStatus string          `json:"transcript_status,omitempty"` // pending, processing, completed, failed
+	Transcript       string          `json:"transcript,omitempty"`
+	TranscriptWords  TranscriptWords `json:"transcript_words,omitempty" gorm:"type:jsonb"`
+	TranscriptError  string          `json:"transcript_error,omitempty"`
+	Timestamp        time.Time       `json:"timestamp" gorm:"not null;default:now()"`
+	Files            []File          `json:"files" gorm:"foreignKey:RecordID"`
+	CreatedAt        time.Time       `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt        time.Time       `json:"updated_at" gorm:"not null;default:now()"`
+	DeletedAt        gorm.DeletedAt  `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 // File represents a file uploaded by a user
 type File struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	RecordID  uuid.UUID `json:"record_id" gorm:"type:uuid;not null"`
-	Name      string    `json:"name" gorm:"not null"`
-	URL       string    `json:"url"`
-	Type      string    `json:"type"`
-	Size      int64     `json:"size"`
-	Data      []byte    `json:"data" gorm:"type:bytea"` // Actual file data
-	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now()"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:now()"`
+	ID         uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	RecordID   uuid.UUID      `json:"record_id" gorm:"type:uuid;not null"`
+	Name       string         `json:"name" gorm:"not null"`
+	URL        string         `json:"url"`
+	Type       string         `json:"type"`
+	Size       int64          `json:"size"`
+	Data       []byte         `json:"data" gorm:"type:bytea"`        // Upload payload, only kept inline by legacy rows
+	SHA256     string         `json:"sha256,omitempty" gorm:"index"` // Content hash of the blob holding the data
+	Width      int            `json:"width,omitempty"`               // Pixel width for images
+	Height     int            `json:"height,omitempty"`              // Pixel height for images
+	Thumbnails []Thumbnail    `json:"thumbnails,omitempty" gorm:"foreignKey:FileID"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt  time.Time      `json:"updated_at" gorm:"not null;default:now()"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// Thumbnail is a downscaled rendition of an image file kept in the file store
+type Thumbnail struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	FileID      uuid.UUID `json:"file_id" gorm:"type:uuid;not null;uniqueIndex:idx_thumbnail_file_size"`
+	Size        string    `json:"size" gorm:"not null;uniqueIndex:idx_thumbnail_file_size"` // small, medium, large
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	ContentType string    `json:"content_type"`
+	StorageKey  string    `json:"-" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null;default:now()"`
+}
+
+// Transcript statuses of a record with audio
+const (
+	TranscriptPending    = "pending"
+	TranscriptProcessing = "processing"
+	TranscriptCompleted  = "completed"
+	TranscriptFailed     = "failed"
+)
+
+// TranscriptWord is a single transcribed word with its offsets into the
+// recording in milliseconds
+type TranscriptWord struct {
+	Word  string `json:"word"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+}
+
+// TranscriptWords is stored as a JSONB array
+type TranscriptWords []TranscriptWord
+
+func (w TranscriptWords) Value() (driver.Value, error) {
+	if w == nil {
+		return nil, nil
+	}
+	return json.Marshal(w)
+}
+
+func (w *TranscriptWords) Scan(value interface{}) error {
+	if value == nil {
+		*w = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("invalid transcript words value")
+	}
+	return json.Unmarshal(data, w)
 }
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StoredObject is a blob kept by the database-backed file store
+type StoredObject struct {
+	Key       string    `gorm:"primary_key"`
+	Data      []byte    `gorm:"type:bytea"`
+	Size      int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null;default:now()"`
+}
+
+// Blob is a content-addressed file body shared by every file with the same
+// SHA-256. The content itself lives in the file store under BlobKey.
+type Blob struct {
+	SHA256    string    `json:"sha256" gorm:"primary_key"`
+	Size      int64     `json:"size" gorm:"not null"`
+	RefCount  int64     `json:"-" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now()"`
+	UsedAt    time.Time `json:"-" gorm:"not null;default:now()"` // last claimed by an upload, which keeps it from collection
+}
+
+// BlobKey returns the file store key of a blob
+func BlobKey(sha256 string) string {
+	return "blobs/" + sha256
+}
+
+// BlobRefCountMismatch is a blob whose stored reference count differs from
+// the number of files referencing it
+type BlobRefCountMismatch struct {
+	SHA256   string `json:"sha256"`
+	RefCount int64  `json:"ref_count"`
+	Actual   int64  `json:"actual"`
+}
+
+// StorageUsage is the number of bytes of file and audio data a user has
+// uploaded, counted by logical size so shared blobs count for every owner
+type StorageUsage struct {
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key"`
+	FileBytes  int64     `json:"file_bytes" gorm:"not null;default:0"`
+	AudioBytes int64     `json:"audio_bytes" gorm:"not null;default:0"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Total returns the bytes counted against the user's quota
+func (u *StorageUsage) Total() int64 {
+	return u.FileBytes + u.AudioBytes
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of items that can be moved to the trash
+const (
+	TrashProject = "projects"
+	TrashSession = "sessions"
+	TrashRecord  = "records"
+)
+
+// ValidTrashKind reports whether kind names a kind of trashable item
+func ValidTrashKind(kind string) bool {
+	return kind == TrashProject || kind == TrashSession || kind == TrashRecord
+}
+
+// TrashItem is an item deleted on its own rather than together with its
+// parent. Restoring it also restores the children deleted along with it.
+type TrashItem struct {
+	Kind      string     `json:"kind"`
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"-"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"` // project of a session, session of a record
+	Label     string     `json:"label"`               // project name, session start or record text
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+
+	// Whether the parent is in the trash too, so it has to be restored first
+	ParentDeleted bool `json:"parent_deleted"`
+}
+
+// TrashCounts counts trashed rows by kind
+type TrashCounts struct {
+	Projects int64 `json:"projects"`
+	Sessions int64 `json:"sessions"`
+	Records  int64 `json:"records"`
+	Files    int64 `json:"files"`
+}
+
+// Total returns the number of rows counted
+func (c TrashCounts) Total() int64 {
+	return c.Projects + c.Sessions + c.Records + c.Files
+}
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limits used when validating session times. Durations are in seconds
+// everywhere except Record.AudioDuration, which is in milliseconds.
+const (
+	MaxSessionDuration = 24 * time.Hour
+	MaxClockSkew       = 5 * time.Minute
+
+	// Durations may exceed EndTime - StartTime by this much or by
+	// DurationTolerancePercent of the interval, whichever is larger. They
+	// may be shorter by any amount, since pauses are not tracked.
+	DurationTolerance        = 5 * time.Second
+	DurationTolerancePercent = 1
+)
+
+// earliestSessionTime rejects zero-ish and otherwise absurd start times
+var earliestSessionTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FieldError describes one invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every invalid field so clients can show them
+// all at once
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the session's times and duration. A zero Duration is
+// allowed and means it is derived from the interval.
+func (s *Session) Validate(now time.Time) error {
+	verr := &ValidationError{}
+
+	if s.StartTime.Before(earliestSessionTime) {
+		verr.add("start_time", "must be after %s", earliestSessionTime.Format(time.RFC3339))
+	}
+	if s.StartTime.After(now.Add(MaxClockSkew)) {
+		verr.add("start_time", "must not be in the future")
+	}
+	if s.EndTime.After(now.Add(MaxClockSkew)) {
+		verr.add("end_time", "must not be in the future")
+	}
+
+	interval := s.EndTime.Sub(s.StartTime)
+	if interval < 0 {
+		verr.add("end_time", "must not be before start_time")
+	} else if interval > MaxSessionDuration {
+		verr.add("end_time", "session must not be longer than %s", MaxSessionDuration)
+	}
+
+	if s.Duration < 0 {
+		verr.add("duration", "must not be negative")
+	} else if s.Duration > 0 && interval >= 0 {
+		tolerance := interval * DurationTolerancePercent / 100
+		if tolerance < DurationTolerance {
+			tolerance = DurationTolerance
+		}
+		duration := time.Duration(s.Duration) * time.Second
+		if duration-interval > tolerance {
+			verr.add("duration", "must not exceed end_time - start_time (%d seconds); durations are in seconds",
+				int64(interval/time.Second))
+		}
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkLogPause is an interval during which a work log was paused. EndTime is
+// nil while the pause is still open.
+type WorkLogPause struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	WorkLogID uuid.UUID  `json:"work_log_id" gorm:"type:uuid;index"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+// TransitionError is returned for a status change the state machine does
+// not allow
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change work log status from %s to %s", e.From, e.To)
+}
+
+// transitions lists the statuses each status may move to
+var transitions = map[string][]string{
+	WorkLogActive: {WorkLogPaused, WorkLogCompleted},
+	WorkLogPaused: {WorkLogActive, WorkLogCompleted},
+}
+
+// CanTransition reports whether a work log may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the work log to status at the given time, opening or
+// closing a pause as needed and recomputing Duration. Pauses must be loaded.
+func (w *WorkLog) Transition(status string, at time.Time) error {
+	if !CanTransition(w.Status, status) {
+		return &TransitionError{From: w.Status, To: status}
+	}
+	if at.Before(w.StartTime) {
+		at = w.StartTime
+	}
+
+	if w.Status == WorkLogPaused {
+		if pause := w.openPause(); pause != nil {
+			end := at
+			pause.EndTime = &end
+		}
+	}
+	if status == WorkLogPaused {
+		w.Pauses = append(w.Pauses, WorkLogPause{
+			ID:        uuid.New(),
+			WorkLogID: w.ID,
+			StartTime: at,
+		})
+	}
+	if status == WorkLogCompleted {
+		w.EndTime = at
+	}
+
+	w.Status = status
+	w.Duration = int64(w.ActiveDuration(at) / time.Second)
+	w.UpdatedAt = at
+	return nil
+}
+
+// ActiveDuration returns the time spent active between StartTime and now,
+// or EndTime once the log is completed
+func (w *WorkLog) ActiveDuration(now time.Time) time.Duration {
+	end := now
+	if w.Status == WorkLogCompleted && !w.EndTime.IsZero() {
+		end = w.EndTime
+	}
+
+	active := end.Sub(w.StartTime)
+	for _, pause := range w.Pauses {
+		pauseEnd := end
+		if pause.EndTime != nil && pause.EndTime.Before(end) {
+			pauseEnd = *pause.EndTime
+		}
+		if pauseEnd.After(pause.StartTime) {
+			active -= pauseEnd.Sub(pause.StartTime)
+		}
+	}
+	if active < 0 {
+		return 0
+	}
+	return active
+}
+
+// ToSession builds the project session a completed work log converts into.
+// Entries become records; status entries are dropped since the session has
+// no pauses.
+func (w *WorkLog) ToSession(entries []LogEntry) Session {
+	session := Session{
+		ID:        uuid.New(),
+		StartTime: w.StartTime,
+		EndTime:   w.EndTime,
+		Duration:  int64(w.ActiveDuration(w.EndTime) / time.Second),
+		Tags:      w.Tags,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if w.ProjectID != nil {
+		session.ProjectID = *w.ProjectID
+	}
+
+	for _, entry := range entries {
+		if entry.Type == LogEntryStatus {
+			continue
+		}
+		record := Record{
+			ID:        uuid.New(),
+			SessionID: session.ID,
+			Text:      entry.Content,
+			Timestamp: entry.CreatedAt,
+			CreatedAt: entry.CreatedAt,
+			UpdatedAt: entry.UpdatedAt,
+		}
+		if entry.Type == LogEntryCommit {
+			if link, ok := entry.Metadata["url"].(string); ok {
+				record.GitLink = link
+			}
+		}
+		session.Records = append(session.Records, record)
+	}
+	return session
+}
+
+func (w *WorkLog) openPause() *WorkLogPause {
+	for i := range w.Pauses {
+		if w.Pauses[i].EndTime == nil {
+			return &w.Pauses[i]
+		}
+	}
+	return nil
+}
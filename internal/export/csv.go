@@ -0,0 +1,67 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// formulaPrefixes start cells that spreadsheet apps evaluate as formulas
+const formulaPrefixes = "=+-@\t\r"
+
+type csvWriter struct {
+	out     io.Writer
+	w       *csv.Writer
+	locale  Locale
+	columns []Column
+}
+
+func newCSVWriter(w io.Writer, locale Locale) Writer {
+	cw := csv.NewWriter(w)
+	cw.Comma = locale.Separator
+	return &csvWriter{out: w, w: cw, locale: locale}
+}
+
+func (w *csvWriter) WriteHeader(columns []Column) error {
+	w.columns = columns
+
+	// A byte order mark makes spreadsheet apps read the file as UTF-8
+	if _, err := io.WriteString(w.out, "\uFEFF"); err != nil {
+		return err
+	}
+
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Header
+	}
+	return w.w.Write(headers)
+}
+
+func (w *csvWriter) WriteRow(values []interface{}) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = w.locale.formatText(w.columns[i].kind, value)
+		if w.columns[i].kind == kindText {
+			cells[i] = escapeFormula(cells[i])
+		}
+	}
+	if err := w.w.Write(cells); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// escapeFormula quotes text that would be read as a formula, such as a
+// note starting with =, so opening an export cannot run it
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune(formulaPrefixes, rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
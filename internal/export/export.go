@@ -0,0 +1,112 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+)
+
+// Supported file formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatJSON = "json"
+)
+
+// ErrUnknownColumn is returned when a requested column does not exist
+var ErrUnknownColumn = errors.New("unknown column")
+
+// Kinds of column values, which decide how each format writes them
+const (
+	kindText  = iota // string
+	kindHours        // float64 hours
+	kindInt          // int64
+	kindTime         // time.Time with date and time
+	kindDate         // time.Time with only the date
+	kindBool         // bool
+)
+
+// Column is one column of an export
+type Column struct {
+	Key    string
+	Header string
+	kind   int
+	value  func(row *domain.ExportRow, loc *time.Location) interface{}
+}
+
+func hours(seconds int64) float64 {
+	return float64(seconds) / 3600
+}
+
+// Columns lists every column that can be exported
+var Columns = []Column{
+	{"project", "Project", kindText, func(r *domain.ExportRow, _ *time.Location) interface{} { return r.ProjectName }},
+	{"project_id", "Project ID", kindText, func(r *domain.ExportRow, _ *time.Location) interface{} { return r.ProjectID.String() }},
+	{"session_id", "Session ID", kindText, func(r *domain.ExportRow, _ *time.Location) interface{} { return r.SessionID.String() }},
+	{"date", "Date", kindDate, func(r *domain.ExportRow, loc *time.Location) interface{} { return r.StartTime.In(loc) }},
+	{"start", "Start", kindTime, func(r *domain.ExportRow, loc *time.Location) interface{} { return r.StartTime.In(loc) }},
+	{"end", "End", kindTime, func(r *domain.ExportRow, loc *time.Location) interface{} { return r.EndTime.In(loc) }},
+	{"duration", "Hours", kindHours, func(r *domain.ExportRow, _ *time.Location) interface{} { return hours(r.Duration) }},
+	{"rounded_duration", "Rounded hours", kindHours, func(r *domain.ExportRow, _ *time.Location) interface{} { return hours(r.RoundedDuration) }},
+	{"duration_seconds", "Seconds", kindInt, func(r *domain.ExportRow, _ *time.Location) interface{} { return r.Duration }},
+	{"records", "Records", kindInt, func(r *domain.ExportRow, _ *time.Location) interface{} { return r.Records }},
+	{"note", "Note", kindText, func(r *domain.ExportRow, _ *time.Location) interface{} { return r.FirstNote }},
+	{"tags", "Tags", kindText, func(r *domain.ExportRow, _ *time.Location) interface{} { return strings.Join(r.Tags, ", ") }},
+	{"manual", "Manual", kindBool, func(r *domain.ExportRow, _ *time.Location) interface{} { return r.Manual }},
+}
+
+// DefaultColumns are exported when no columns are requested
+var DefaultColumns = []string{"project", "start", "end", "duration", "records", "note"}
+
+// SelectColumns returns the named columns in the given order
+func SelectColumns(keys []string) ([]Column, error) {
+	columns := make([]Column, 0, len(keys))
+	for _, key := range keys {
+		found := false
+		for _, column := range Columns {
+			if column.Key == key {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, key)
+		}
+	}
+	return columns, nil
+}
+
+// Values returns the cells of a row for the given columns
+func Values(columns []Column, row *domain.ExportRow, loc *time.Location) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = column.value(row, loc)
+	}
+	return values
+}
+
+// Writer writes a table one row at a time so exports can be streamed
+type Writer interface {
+	WriteHeader(columns []Column) error
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// Format describes an export file format
+type Format struct {
+	ContentType string
+	Extension   string
+	New         func(w io.Writer, locale Locale) Writer
+}
+
+// Formats maps format names to their writers
+var Formats = map[string]Format{
+	FormatCSV:  {"text/csv; charset=utf-8", "csv", newCSVWriter},
+	FormatXLSX: {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx", newXLSXWriter},
+	FormatJSON: {"application/json", "json", newJSONWriter},
+}
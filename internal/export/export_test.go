@@ -0,0 +1,232 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+)
+
+var berlin = time.FixedZone("CET", 3600)
+
+func testRow() *domain.ExportRow {
+	start := time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)
+	return &domain.ExportRow{
+		SessionID:   uuid.New(),
+		ProjectID:   uuid.New(),
+		ProjectName: "Thesis & notes",
+		StartTime:   start,
+		EndTime:     start.Add(90 * time.Minute),
+		Duration:    5400,
+		Manual:      true,
+		Tags:        domain.StringArray{"write", "read"},
+		FirstNote:   "=HYPERLINK(\"x\")",
+	}
+}
+
+// export writes the header and rows with format and returns the file
+func export(t *testing.T, format, locale string, keys []string, rows ...*domain.ExportRow) []byte {
+	t.Helper()
+	columns, err := SelectColumns(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, ok := LookupLocale(locale)
+	if !ok {
+		t.Fatalf("unknown locale %s", locale)
+	}
+
+	var buf bytes.Buffer
+	w := Formats[format].New(&buf, loc)
+	if err := w.WriteHeader(columns); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(Values(columns, row, berlin)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	keys := []string{"project", "date", "start", "duration", "manual", "tags", "note"}
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"iso", "\uFEFFProject,Date,Start,Hours,Manual,Tags,Note\n" +
+			"Thesis & notes,2026-03-02,2026-03-02 09:30,1.50,yes,\"write, read\",\"'=HYPERLINK(\"\"x\"\")\"\n"},
+		{"de-AT", "\uFEFFProject;Date;Start;Hours;Manual;Tags;Note\n" +
+			"Thesis & notes;02.03.2026;02.03.2026 09:30;1,50;yes;write, read;\"'=HYPERLINK(\"\"x\"\")\"\n"},
+		{"en_US", "\uFEFFProject,Date,Start,Hours,Manual,Tags,Note\n" +
+			"Thesis & notes,03/02/2026,03/02/2026 9:30 AM,1.50,yes,\"write, read\",\"'=HYPERLINK(\"\"x\"\")\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := string(export(t, FormatCSV, tt.locale, keys, testRow())); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"", ""},
+		{"plain", "plain"},
+		{"=1+1", "'=1+1"},
+		{"+49 30", "'+49 30"},
+		{"-", "'-"},
+		{"@sum", "'@sum"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.text); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	keys := []string{"project", "date", "start", "end", "duration", "duration_seconds", "manual", "note"}
+	data := export(t, FormatXLSX, "de", keys, testRow(), testRow())
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(content)
+
+		// Every part must be well-formed XML
+		dec := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+		}
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/styles.xml"], `formatCode="dd.mm.yyyy"`) {
+		t.Errorf("styles lack the locale's date format:\n%s", parts["xl/styles.xml"])
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t>Project</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">Thesis &amp; notes</t></is></c>`,
+		`<c r="B2" s="4"><v>46083</v></c>`,
+		`<c r="C2" s="3"><v>46083.395833333336</v></c>`,
+		`<c r="E2" s="2"><v>1.5</v></c>`,
+		`<c r="F2"><v>5400</v></c>`,
+		`<c r="G2" t="b"><v>1</v></c>`,
+		`<row r="3">`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet lacks %s", want)
+		}
+	}
+	if strings.Contains(sheet, `<row r="4">`) {
+		t.Error("sheet has more rows than written")
+	}
+}
+
+func TestXLSXWriterWithoutRows(t *testing.T) {
+	data := export(t, FormatXLSX, "iso", DefaultColumns)
+	if _, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := columnName(tt.i); got != tt.want {
+			t.Errorf("columnName(%d) = %s, want %s", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestExcelSerial(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want float64
+	}{
+		{time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), 2},
+		{time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), 46083.5},
+		// The wall clock counts, not the instant
+		{time.Date(2026, 3, 2, 12, 0, 0, 0, berlin), 46083.5},
+	}
+	for _, tt := range tests {
+		if got := excelSerial(tt.t); got != tt.want {
+			t.Errorf("excelSerial(%s) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestSelectColumnsUnknown(t *testing.T) {
+	if _, err := SelectColumns([]string{"project", "salary"}); !errors.Is(err, ErrUnknownColumn) {
+		t.Fatalf("err = %v, want ErrUnknownColumn", err)
+	}
+}
+
+func TestLookupLocale(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{"", "iso", true},
+		{"de", "de", true},
+		{"de-AT", "de", true},
+		{"en_US", "en-US", true},
+		{"EN-gb", "en-GB", true},
+		{"en-AU", "iso", true},
+		{"xx", "", false},
+	}
+	for _, tt := range tests {
+		locale, ok := LookupLocale(tt.tag)
+		if ok != tt.ok || locale.Name != tt.want {
+			t.Errorf("LookupLocale(%q) = %s, %v; want %s, %v", tt.tag, locale.Name, ok, tt.want, tt.ok)
+		}
+	}
+}
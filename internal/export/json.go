@@ -0,0 +1,65 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonWriter streams a JSON array with one object per row, keeping the
+// keys in column order
+type jsonWriter struct {
+	w       io.Writer
+	columns []Column
+	rows    int
+}
+
+func newJSONWriter(w io.Writer, _ Locale) Writer {
+	return &jsonWriter{w: w}
+}
+
+func (w *jsonWriter) WriteHeader(columns []Column) error {
+	w.columns = columns
+	_, err := io.WriteString(w.w, "[")
+	return err
+}
+
+func (w *jsonWriter) WriteRow(values []interface{}) error {
+	var buf bytes.Buffer
+	if w.rows > 0 {
+		buf.WriteByte(',')
+	}
+	buf.WriteString("\n{")
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(w.columns[i].Key)
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		if t, ok := value.(time.Time); ok {
+			if w.columns[i].kind == kindDate {
+				value = t.Format("2006-01-02")
+			} else {
+				value = t.Format(time.RFC3339)
+			}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte('}')
+	w.rows++
+
+	_, err := w.w.Write(buf.Bytes())
+	return err
+}
+
+func (w *jsonWriter) Close() error {
+	_, err := io.WriteString(w.w, "\n]\n")
+	return err
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale decides how CSV cells and spreadsheet dates are formatted. JSON
+// always uses RFC 3339 times and plain numbers.
+type Locale struct {
+	Name           string
+	Decimal        string // decimal separator
+	Separator      rune   // CSV field separator, ; where the decimal separator is a comma
+	DateLayout     string // Go layouts
+	DateTimeLayout string
+	ExcelDate      string // spreadsheet number formats
+	ExcelDateTime  string
+}
+
+// DefaultLocale uses ISO 8601 dates and a decimal point
+var DefaultLocale = Locale{"iso", ".", ',', "2006-01-02", "2006-01-02 15:04", "yyyy-mm-dd", "yyyy-mm-dd hh:mm"}
+
+var locales = map[string]Locale{
+	"iso":   DefaultLocale,
+	"en":    DefaultLocale,
+	"en-us": {"en-US", ".", ',', "01/02/2006", "01/02/2006 3:04 PM", "mm/dd/yyyy", "mm/dd/yyyy h:mm AM/PM"},
+	"en-gb": {"en-GB", ".", ',', "02/01/2006", "02/01/2006 15:04", "dd/mm/yyyy", "dd/mm/yyyy hh:mm"},
+	"de":    {"de", ",", ';', "02.01.2006", "02.01.2006 15:04", "dd.mm.yyyy", "dd.mm.yyyy hh:mm"},
+	"fr":    {"fr", ",", ';', "02/01/2006", "02/01/2006 15:04", "dd/mm/yyyy", "dd/mm/yyyy hh:mm"},
+	"es":    {"es", ",", ';', "02/01/2006", "02/01/2006 15:04", "dd/mm/yyyy", "dd/mm/yyyy hh:mm"},
+	"it":    {"it", ",", ';', "02/01/2006", "02/01/2006 15:04", "dd/mm/yyyy", "dd/mm/yyyy hh:mm"},
+	"nl":    {"nl", ",", ';', "02-01-2006", "02-01-2006 15:04", "dd-mm-yyyy", "dd-mm-yyyy hh:mm"},
+	"zh":    {"zh", ".", ',', "2006/01/02", "2006/01/02 15:04", "yyyy/mm/dd", "yyyy/mm/dd hh:mm"},
+}
+
+// LookupLocale finds a locale by tag such as de-AT or en_US, falling back to
+// the language alone
+func LookupLocale(tag string) (Locale, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		return DefaultLocale, true
+	}
+	if locale, ok := locales[tag]; ok {
+		return locale, true
+	}
+	if language, _, found := strings.Cut(tag, "-"); found {
+		if locale, ok := locales[language]; ok {
+			return locale, true
+		}
+	}
+	return Locale{}, false
+}
+
+// formatText renders a cell as text in the locale
+func (l Locale) formatText(kind int, value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strings.Replace(strconv.FormatFloat(v, 'f', 2, 64), ".", l.Decimal, 1)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		if kind == kindDate {
+			return v.Format(l.DateLayout)
+		}
+		return v.Format(l.DateTimeLayout)
+	}
+	return ""
+}
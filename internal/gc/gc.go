@@ -0,0 +1,291 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+	"github.com/ZigaoWang/zebra-server/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Report lists everything a collection run found. In delete mode the same
+// items have been removed or repaired.
+type Report struct {
+	Deleted bool `json:"deleted"`
+
+	OrphanedSessions   []uuid.UUID `json:"orphaned_sessions"`   // project is gone
+	OrphanedRecords    []uuid.UUID `json:"orphaned_records"`    // session is gone
+	OrphanedFiles      []uuid.UUID `json:"orphaned_files"`      // record is gone
+	OrphanedThumbnails []uuid.UUID `json:"orphaned_thumbnails"` // file is gone
+
+	RefCountMismatches []domain.BlobRefCountMismatch `json:"ref_count_mismatches"`
+	UnreferencedBlobs  []string                      `json:"unreferenced_blobs"`
+	UntrackedObjects   []string                      `json:"untracked_objects"` // in the file store without a row
+
+	// Integrity errors that cannot be repaired automatically
+	MissingBlobRows    []string `json:"missing_blob_rows"`    // referenced by files, no blob row
+	MissingBlobContent []string `json:"missing_blob_content"` // blob row without stored content
+}
+
+// Empty reports whether the run found nothing to collect or repair
+func (r *Report) Empty() bool {
+	return len(r.OrphanedSessions) == 0 && len(r.OrphanedRecords) == 0 &&
+		len(r.OrphanedFiles) == 0 && len(r.OrphanedThumbnails) == 0 &&
+		len(r.RefCountMismatches) == 0 && len(r.UnreferencedBlobs) == 0 &&
+		len(r.UntrackedObjects) == 0 && len(r.MissingBlobRows) == 0 &&
+		len(r.MissingBlobContent) == 0
+}
+
+// Summary returns a one line description of the report
+func (r *Report) Summary() string {
+	verb := "found"
+	if r.Deleted {
+		verb = "collected"
+	}
+	return fmt.Sprintf("%s %d sessions, %d records, %d files, %d thumbnails, %d blobs, %d untracked objects; "+
+		"fixed %d ref counts; %d missing blob rows, %d blobs without content",
+		verb, len(r.OrphanedSessions), len(r.OrphanedRecords), len(r.OrphanedFiles),
+		len(r.OrphanedThumbnails), len(r.UnreferencedBlobs), len(r.UntrackedObjects),
+		len(r.RefCountMismatches), len(r.MissingBlobRows), len(r.MissingBlobContent))
+}
+
+// Collector finds data that is no longer reachable from a live project and
+// storage that no longer backs any row
+type Collector struct {
+	repo      repository.MaintenanceRepository
+	usageRepo repository.StorageUsageRepository
+	store     storage.FileStore
+	grace     time.Duration
+}
+
+// NewCollector creates a collector. Unreferenced blobs claimed by an upload
+// and stored objects within grace are kept, since uploads write content
+// before the rows referencing it are committed.
+func NewCollector(repo repository.MaintenanceRepository, usageRepo repository.StorageUsageRepository, store storage.FileStore, grace time.Duration) *Collector {
+	return &Collector{repo: repo, usageRepo: usageRepo, store: store, grace: grace}
+}
+
+// Run checks the database and file store. With remove set, orphans are
+// deleted, reference counts repaired and unreferenced content removed;
+// otherwise nothing is changed.
+func (c *Collector) Run(ctx context.Context, remove bool) (*Report, error) {
+	report := &Report{Deleted: remove}
+	var err error
+
+	// Parents are collected before children so one run also removes the
+	// records and files that become orphans through the deletion
+	if report.OrphanedSessions, err = c.repo.OrphanedSessionIDs(ctx); err != nil {
+		return nil, err
+	}
+	if remove {
+		if err := c.repo.DeleteSessions(ctx, report.OrphanedSessions); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.OrphanedRecords, err = c.repo.OrphanedRecordIDs(ctx); err != nil {
+		return nil, err
+	}
+	if remove {
+		if err := c.repo.DeleteRecords(ctx, report.OrphanedRecords); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.OrphanedFiles, err = c.repo.OrphanedFileIDs(ctx); err != nil {
+		return nil, err
+	}
+	if remove {
+		if err := c.repo.DeleteFiles(ctx, report.OrphanedFiles); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.OrphanedThumbnails, err = c.repo.OrphanedThumbnailIDs(ctx); err != nil {
+		return nil, err
+	}
+	if remove {
+		if err := c.repo.DeleteThumbnails(ctx, report.OrphanedThumbnails); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.RefCountMismatches, err = c.repo.BlobRefCountMismatches(ctx); err != nil {
+		return nil, err
+	}
+	if remove && len(report.RefCountMismatches) > 0 {
+		if err := c.repo.ReconcileBlobRefCounts(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.MissingBlobRows, err = c.repo.MissingBlobHashes(ctx); err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-c.grace)
+	if err := c.collectBlobs(ctx, report, cutoff, remove); err != nil {
+		return nil, err
+	}
+	if err := c.checkObjects(ctx, report, cutoff, remove); err != nil {
+		return nil, err
+	}
+
+	// Usage totals only count live data, so rebuild them after deleting
+	if remove && c.usageRepo != nil && !report.Empty() {
+		if _, err := c.usageRepo.Recompute(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// collectBlobs finds blobs no file references any more
+func (c *Collector) collectBlobs(ctx context.Context, report *Report, cutoff time.Time, remove bool) error {
+	blobs, err := c.repo.UnreferencedBlobs(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	for _, blob := range blobs {
+		if !remove {
+			report.UnreferencedBlobs = append(report.UnreferencedBlobs, blob.SHA256)
+			continue
+		}
+
+		// The content is deleted while the row's deletion holds its lock,
+		// so an upload claiming the blob meanwhile writes it again after
+		deleted, err := c.repo.DeleteUnreferencedBlob(ctx, blob.SHA256, cutoff, func() error {
+			return c.store.Delete(ctx, domain.BlobKey(blob.SHA256))
+		})
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			continue
+		}
+		report.UnreferencedBlobs = append(report.UnreferencedBlobs, blob.SHA256)
+	}
+	return nil
+}
+
+// checkObjects compares the file store with the blob and thumbnail rows,
+// finding stored objects nothing references and blobs whose content is gone
+func (c *Collector) checkObjects(ctx context.Context, report *Report, cutoff time.Time, remove bool) error {
+	hashes, err := c.repo.BlobHashes(ctx)
+	if err != nil {
+		return err
+	}
+	thumbnailKeys, err := c.repo.ThumbnailKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(hashes)+len(thumbnailKeys))
+	for _, hash := range hashes {
+		known[domain.BlobKey(hash)] = true
+	}
+	for _, key := range thumbnailKeys {
+		known[key] = true
+	}
+
+	stored := make(map[string]bool)
+	for _, prefix := range []string{"blobs/", "thumbnails/"} {
+		objects, err := c.store.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			stored[object.Key] = true
+			if known[object.Key] || object.CreatedAt.After(cutoff) {
+				continue
+			}
+			if remove {
+				if err := c.store.Delete(ctx, object.Key); err != nil {
+					return err
+				}
+			}
+			report.UntrackedObjects = append(report.UntrackedObjects, object.Key)
+		}
+	}
+
+	for _, hash := range hashes {
+		if !stored[domain.BlobKey(hash)] {
+			report.MissingBlobContent = append(report.MissingBlobContent, hash)
+		}
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes items that have been in the trash longer
+// than retention. Without remove it only counts them. Storage usage keeps
+// counting trashed data, so it is recomputed after purging.
+func (c *Collector) PurgeTrash(ctx context.Context, retention time.Duration, remove bool) (domain.TrashCounts, error) {
+	cutoff := time.Now().Add(-retention)
+	if !remove {
+		return c.repo.ExpiredTrash(ctx, cutoff)
+	}
+
+	counts, err := c.repo.PurgeTrash(ctx, cutoff)
+	if err != nil {
+		return counts, err
+	}
+	if counts.Total() > 0 && c.usageRepo != nil {
+		if _, err := c.usageRepo.Recompute(ctx); err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
+
+// PurgeTrashEvery purges expired trash on a fixed interval until ctx is
+// cancelled
+func (c *Collector) PurgeTrashEvery(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			counts, err := c.PurgeTrash(ctx, retention, true)
+			if err != nil {
+				log.Printf("Trash purge failed: %v", err)
+				continue
+			}
+			if counts.Total() > 0 {
+				log.Printf("Purged %d projects, %d sessions, %d records and %d files from the trash",
+					counts.Projects, counts.Sessions, counts.Records, counts.Files)
+			}
+		}
+	}
+}
+
+// RunEvery collects garbage in delete mode on a fixed interval until ctx
+// is cancelled
+func (c *Collector) RunEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := c.Run(ctx, true)
+			if err != nil {
+				log.Printf("Garbage collection failed: %v", err)
+				continue
+			}
+			if !report.Empty() {
+				log.Printf("Garbage collection %s", report.Summary())
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Package ical reads and writes the parts of iCalendar (RFC 5545) needed to
+// publish sessions as events and to import events as sessions.
+package ical
+
+import (
+	"time"
+)
+
+// ContentType of iCalendar files
+const ContentType = "text/calendar; charset=utf-8"
+
+// Event is a VEVENT
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Categories  []string
+	Start       time.Time
+	End         time.Time
+	Updated     time.Time // DTSTAMP when writing, LAST-MODIFIED or DTSTAMP when read
+
+	// Only set when parsing
+	AllDay    bool // DTSTART is a date without a time
+	Recurring bool // has RRULE or RDATE, or overrides one occurrence of such an event
+	Cancelled bool // STATUS:CANCELLED
+}
+
+// utcLayout is the form of UTC date-times
+const utcLayout = "20060102T150405Z"
@@ -0,0 +1,265 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned for input that is not an iCalendar file
+var ErrInvalid = errors.New("not an iCalendar file")
+
+// maxLineBytes bounds a single unfolded content line
+const maxLineBytes = 1 << 20
+
+// property is one content line split into its parts
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads the events of a calendar. Floating times, and times in zones
+// the system does not know, are read in loc. Components nested in events,
+// such as alarms, are ignored.
+func Parse(r io.Reader, loc *time.Location) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var event *Event
+	var end time.Time
+	var duration time.Duration
+	seenCalendar := false
+	nested := 0
+
+	for n, line := range lines {
+		if line == "" {
+			continue
+		}
+		prop, err := parseProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCALENDAR"):
+			seenCalendar = true
+			continue
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT") && event == nil:
+			event = &Event{}
+			end, duration = time.Time{}, 0
+			continue
+		case event == nil:
+			continue
+		case prop.name == "BEGIN":
+			nested++
+			continue
+		case prop.name == "END" && nested > 0:
+			nested--
+			continue
+		case nested > 0:
+			continue
+		case prop.name == "END" && strings.EqualFold(prop.value, "VEVENT"):
+			if event.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event %q has no DTSTART", n+1, event.UID)
+			}
+			switch {
+			case !end.IsZero():
+				event.End = end
+			case duration != 0:
+				event.End = event.Start.Add(duration)
+			case event.AllDay:
+				event.End = event.Start.AddDate(0, 0, 1)
+			default:
+				event.End = event.Start
+			}
+			events = append(events, *event)
+			event = nil
+			continue
+		}
+
+		switch prop.name {
+		case "UID":
+			event.UID = prop.value
+		case "SUMMARY":
+			event.Summary = unescapeText(prop.value)
+		case "DESCRIPTION":
+			event.Description = unescapeText(prop.value)
+		case "CATEGORIES":
+			for _, category := range splitList(prop.value) {
+				if category = strings.TrimSpace(unescapeText(category)); category != "" {
+					event.Categories = append(event.Categories, category)
+				}
+			}
+		case "DTSTART":
+			event.Start, event.AllDay, err = parseTime(prop, loc)
+		case "DTEND":
+			end, _, err = parseTime(prop, loc)
+		case "DURATION":
+			duration, err = parseDuration(prop.value)
+		case "LAST-MODIFIED":
+			event.Updated, _, err = parseTime(prop, loc)
+		case "DTSTAMP":
+			if event.Updated.IsZero() {
+				event.Updated, _, err = parseTime(prop, loc)
+			}
+		case "RRULE", "RDATE", "RECURRENCE-ID":
+			event.Recurring = true
+		case "STATUS":
+			event.Cancelled = strings.EqualFold(prop.value, "CANCELLED")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n+1, prop.name, err)
+		}
+	}
+
+	if !seenCalendar {
+		return nil, ErrInvalid
+	}
+	return events, nil
+}
+
+// unfold reads the content lines, joining folded continuations
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			if len(lines[len(lines)-1]) > maxLineBytes {
+				return nil, bufio.ErrTooLong
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseProperty splits NAME;PARAM=value;...:VALUE, allowing quoted
+// parameter values that contain colons or semicolons
+func parseProperty(line string) (property, error) {
+	prop := property{params: map[string]string{}}
+
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return prop, ErrInvalid
+	}
+	prop.value = line[colon+1:]
+
+	parts := splitUnquoted(line[:colon], ';')
+	prop.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return prop, nil
+}
+
+func splitUnquoted(s string, sep rune) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitList splits a TEXT list at commas that are not escaped
+func splitList(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
+
+// parseTime reads a DATE or DATE-TIME value, in UTC, in its TZID or
+// floating. It reports whether the value is a date only.
+func parseTime(prop property, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.value)
+	if prop.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcLayout, value)
+		return t, false, err
+	}
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = tz
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration reads a DURATION value such as PT1H30M or P1D
+func parseDuration(value string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,189 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// calendar wraps content lines in a VCALENDAR with CRLF line ends
+func calendar(lines ...string) string {
+	all := append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...)
+	all = append(all, "END:VCALENDAR")
+	return strings.Join(all, "\r\n") + "\r\n"
+}
+
+func TestParse(t *testing.T) {
+	floating := time.FixedZone("UTC+1", 3600)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []Event
+	}{
+		{
+			name: "utc with end",
+			input: calendar("BEGIN:VEVENT", "UID:a", "DTSTART:20260302T090000Z", "DTEND:20260302T103000Z",
+				"SUMMARY:Write\\, then read", "DESCRIPTION:line one\\nline two", "CATEGORIES:work,deep\\,focus", "END:VEVENT"),
+			want: []Event{{
+				UID: "a", Summary: "Write, then read", Description: "line one\nline two", Categories: []string{"work", "deep,focus"},
+				Start: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), End: time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name:  "tzid with duration",
+			input: calendar("BEGIN:VEVENT", "UID:b", "DTSTART;TZID=Europe/Berlin:20260302T090000", "DURATION:PT1H15M", "END:VEVENT"),
+			want: []Event{{
+				UID: "b", Start: time.Date(2026, 3, 2, 9, 0, 0, 0, berlin), End: time.Date(2026, 3, 2, 10, 15, 0, 0, berlin),
+			}},
+		},
+		{
+			name:  "quoted tzid and unknown zone read as floating",
+			input: calendar("BEGIN:VEVENT", `UID:c`, `DTSTART;TZID="Mars/Olympus;Mons":20260302T090000`, "END:VEVENT"),
+			want: []Event{{
+				UID: "c", Start: time.Date(2026, 3, 2, 9, 0, 0, 0, floating), End: time.Date(2026, 3, 2, 9, 0, 0, 0, floating),
+			}},
+		},
+		{
+			name:  "all day lasts a day",
+			input: calendar("BEGIN:VEVENT", "UID:d", "DTSTART;VALUE=DATE:20260302", "END:VEVENT"),
+			want: []Event{{
+				UID: "d", AllDay: true, Start: time.Date(2026, 3, 2, 0, 0, 0, 0, floating), End: time.Date(2026, 3, 3, 0, 0, 0, 0, floating),
+			}},
+		},
+		{
+			name: "recurring, cancelled and modified",
+			input: calendar("BEGIN:VEVENT", "UID:e", "DTSTAMP:20260101T000000Z", "LAST-MODIFIED:20260201T000000Z",
+				"DTSTART:20260302T090000Z", "RRULE:FREQ=WEEKLY", "STATUS:CANCELLED", "END:VEVENT"),
+			want: []Event{{
+				UID: "e", Recurring: true, Cancelled: true, Updated: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				Start: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), End: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name: "folded lines and nested alarm",
+			input: calendar("BEGIN:VEVENT", "UID:f", "DTSTART:20260302T090000Z", "SUMMARY:Long sum", " mary", "BEGIN:VALARM",
+				"SUMMARY:Alarm", "END:VALARM", "END:VEVENT"),
+			want: []Event{{
+				UID: "f", Summary: "Long summary",
+				Start: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), End: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name:  "byte order mark and no events",
+			input: "\uFEFF" + calendar("BEGIN:VTODO", "UID:g", "END:VTODO"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := Parse(strings.NewReader(tt.input), floating)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.want))
+			}
+			for i, event := range events {
+				want := tt.want[i]
+				if !event.Start.Equal(want.Start) || !event.End.Equal(want.End) || !event.Updated.Equal(want.Updated) {
+					t.Errorf("times %s-%s (updated %s), want %s-%s (updated %s)",
+						event.Start, event.End, event.Updated, want.Start, want.End, want.Updated)
+				}
+				event.Start, event.End, event.Updated = want.Start, want.End, want.Updated
+				if !reflect.DeepEqual(event, want) {
+					t.Errorf("got %+v, want %+v", event, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		invalid bool
+	}{
+		{"not a calendar", "hello\r\n", true},
+		{"no calendar component", "BEGIN:VEVENT\r\nDTSTART:20260302T090000Z\r\nEND:VEVENT\r\n", true},
+		{"missing start", calendar("BEGIN:VEVENT", "UID:x", "END:VEVENT"), false},
+		{"bad start", calendar("BEGIN:VEVENT", "DTSTART:tomorrow", "END:VEVENT"), false},
+		{"bad duration", calendar("BEGIN:VEVENT", "DTSTART:20260302T090000Z", "DURATION:PT", "END:VEVENT"), false},
+		{"line too long", calendar("BEGIN:VEVENT", "SUMMARY:"+strings.Repeat("x", maxLineBytes), "END:VEVENT"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input), time.UTC)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.invalid && !errors.Is(err, ErrInvalid) {
+				t.Fatalf("err = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{"PT1H30M", 90 * time.Minute, false},
+		{"P1D", 24 * time.Hour, false},
+		{"P1W", 7 * 24 * time.Hour, false},
+		{"P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second, false},
+		{"-PT15M", -15 * time.Minute, false},
+		{"P", 0, true},
+		{"PT", 0, true},
+		{"1H", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	event := Event{
+		UID:         "round@trip",
+		Summary:     "Notes; with, escapes \\ and " + strings.Repeat("ü", 60),
+		Description: "two\nlines",
+		Categories:  []string{"a,b", "c"},
+		Start:       time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+		End:         time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+		Updated:     time.Date(2026, 3, 2, 10, 5, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "Sessions", time.Hour)
+	if err := w.WriteEvent(&event); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+	}
+
+	events, err := Parse(&buf, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || !reflect.DeepEqual(events[0], event) {
+		t.Fatalf("got %+v, want %+v", events, event)
+	}
+}
@@ -0,0 +1,87 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest content line allowed before folding
+const maxLineOctets = 75
+
+// Writer streams a calendar one event at a time
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter starts a calendar with the given display name. Calendar apps
+// are asked to refresh it every refresh.
+func NewWriter(w io.Writer, name string, refresh time.Duration) *Writer {
+	cw := &Writer{w: bufio.NewWriter(w)}
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//Zebra//Sessions//EN")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + escapeText(name))
+	if refresh > 0 {
+		ttl := fmt.Sprintf("PT%dM", int(refresh.Minutes()))
+		cw.line("X-PUBLISHED-TTL:" + ttl)
+		cw.line("REFRESH-INTERVAL;VALUE=DURATION:" + ttl)
+	}
+	return cw
+}
+
+// WriteEvent writes one VEVENT with UTC times
+func (cw *Writer) WriteEvent(event *Event) error {
+	cw.line("BEGIN:VEVENT")
+	cw.line("UID:" + escapeText(event.UID))
+	cw.line("DTSTAMP:" + event.Updated.UTC().Format(utcLayout))
+	cw.line("DTSTART:" + event.Start.UTC().Format(utcLayout))
+	cw.line("DTEND:" + event.End.UTC().Format(utcLayout))
+	cw.line("SUMMARY:" + escapeText(event.Summary))
+	if event.Description != "" {
+		cw.line("DESCRIPTION:" + escapeText(event.Description))
+	}
+	if len(event.Categories) > 0 {
+		categories := make([]string, len(event.Categories))
+		for i, category := range event.Categories {
+			categories[i] = escapeText(category)
+		}
+		cw.line("CATEGORIES:" + strings.Join(categories, ","))
+	}
+	return cw.line("END:VEVENT")
+}
+
+// Close ends the calendar and flushes it
+func (cw *Writer) Close() error {
+	cw.line("END:VCALENDAR")
+	return cw.w.Flush()
+}
+
+// line writes a content line, folding it into lines of at most 75 octets
+// without splitting characters
+func (cw *Writer) line(s string) error {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		cw.w.WriteString(s[:cut])
+		cw.w.WriteString("\r\n ")
+		s = s[cut:]
+		limit = maxLineOctets - 1 // the leading space counts
+	}
+	_, err := cw.w.WriteString(s + "\r\n")
+	return err
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
@@ -0,0 +1,173 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Supported image formats
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+)
+
+// ErrUnsupportedFormat is returned for data that is not a supported image
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// ErrTooLarge is returned for images with more than MaxPixels pixels
+var ErrTooLarge = errors.New("image too large")
+
+// MaxPixels bounds the images that are decoded, since a small file may
+// declare a size that takes gigabytes of memory to decode
+const MaxPixels = 50_000_000
+
+// ThumbnailSizes maps size names to the maximum edge length in pixels
+var ThumbnailSizes = map[string]int{
+	"small":  128,
+	"medium": 320,
+	"large":  640,
+}
+
+// DefaultThumbnailSize is served when a client does not ask for a size
+const DefaultThumbnailSize = "medium"
+
+// DetectFormat sniffs the image format from the leading magic bytes
+func DetectFormat(data []byte) string {
+	switch {
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP
+	}
+	return ""
+}
+
+// Dimensions returns the pixel size of an image without decoding all of it.
+// The size is reported after applying the EXIF orientation.
+func Dimensions(data []byte) (int, int, error) {
+	var cfg image.Config
+	var err error
+
+	switch DetectFormat(data) {
+	case FormatJPEG:
+		cfg, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	case FormatPNG:
+		cfg, err = png.DecodeConfig(bytes.NewReader(data))
+	case FormatGIF:
+		cfg, err = gif.DecodeConfig(bytes.NewReader(data))
+	case FormatWebP:
+		cfg, err = webp.DecodeConfig(bytes.NewReader(data))
+	default:
+		return 0, 0, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if swapsAxes(Orientation(data)) {
+		return cfg.Height, cfg.Width, nil
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// Thumbnail is an encoded downscaled image
+type Thumbnail struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Decode decodes an image in any supported format, applying the EXIF
+// orientation so the result is upright. Images over MaxPixels are not
+// decoded.
+func Decode(data []byte) (image.Image, error) {
+	width, height, err := Dimensions(data)
+	if err != nil {
+		return nil, err
+	}
+	if int64(width)*int64(height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	var img image.Image
+	switch DetectFormat(data) {
+	case FormatJPEG:
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case FormatPNG:
+		img, err = png.Decode(bytes.NewReader(data))
+	case FormatGIF:
+		// Only the first frame of animated GIFs is used
+		img, err = gif.Decode(bytes.NewReader(data))
+	case FormatWebP:
+		img, err = webp.Decode(bytes.NewReader(data))
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	return applyOrientation(img, Orientation(data)), nil
+}
+
+// MakeThumbnail scales src so its longest edge is at most maxEdge pixels.
+// Images that may carry transparency are encoded as PNG, everything else
+// as JPEG. Re-encoding drops all metadata from the source.
+func MakeThumbnail(src image.Image, format string, maxEdge int) (*Thumbnail, error) {
+	bounds := src.Bounds()
+	width, height := fitWithin(bounds.Dx(), bounds.Dy(), maxEdge)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	thumb := &Thumbnail{Width: width, Height: height}
+	if format == FormatPNG || format == FormatGIF || format == FormatWebP {
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, err
+		}
+		thumb.ContentType = "image/png"
+	} else {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		thumb.ContentType = "image/jpeg"
+	}
+	thumb.Data = buf.Bytes()
+	return thumb, nil
+}
+
+// fitWithin scales width and height down proportionally so neither exceeds
+// maxEdge. Images that are already small enough keep their size.
+func fitWithin(width, height, maxEdge int) (int, int) {
+	if width <= maxEdge && height <= maxEdge {
+		return width, height
+	}
+	if width >= height {
+		h := height * maxEdge / width
+		if h < 1 {
+			h = 1
+		}
+		return maxEdge, h
+	}
+	w := width * maxEdge / height
+	if w < 1 {
+		w = 1
+	}
+	return w, maxEdge
+}
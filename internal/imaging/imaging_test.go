@@ -0,0 +1,60 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// pngDeclaring encodes a 1x1 PNG and rewrites its header to declare the
+// given size, as a crafted upload would
+func pngDeclaring(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// The IHDR chunk follows the 8 byte signature: length, type, data, CRC
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:4], width)
+	binary.BigEndian.PutUint32(ihdr[4:8], height)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	return data
+}
+
+func TestDecodePixelLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height uint32
+		wantErr       error
+	}{
+		{"huge", 60000, 60000, ErrTooLarge},
+		{"just over", MaxPixels/1000 + 1, 1000, ErrTooLarge},
+		{"small", 1, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := pngDeclaring(t, tt.width, tt.height)
+
+			width, height, err := Dimensions(data)
+			if err != nil || width != int(tt.width) || height != int(tt.height) {
+				t.Fatalf("Dimensions = %d, %d, %v; want %d, %d", width, height, err, tt.width, tt.height)
+			}
+
+			_, err = Decode(data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Decode error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Decode error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BlobRepository struct {
+	db *gorm.DB
+}
+
+func NewBlobRepository(db *gorm.DB) *BlobRepository {
+	return &BlobRepository{db: db}
+}
+
+// GetOwnedBySHA256 returns the blob with the given content hash if one of
+// the user's files references it, including files in the trash, or nil.
+// Other users' content is indistinguishable from content the server lacks.
+func (r *BlobRepository) GetOwnedBySHA256(ctx context.Context, userID uuid.UUID, sha256 string) (*domain.Blob, error) {
+	var blob domain.Blob
+	err := conn(ctx, r.db).
+		Where("sha256 = ?", sha256).
+		Where(`EXISTS (SELECT 1 FROM files
+			JOIN records ON records.id = files.record_id
+			JOIN sessions ON sessions.id = records.session_id
+			JOIN projects ON projects.id = sessions.project_id
+			WHERE files.sha256 = blobs.sha256 AND projects.user_id = ?)`, userID).
+		First(&blob).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// Claim marks a blob as in use by an upload, so the collector keeps it for
+// its grace period, and returns it. New content gets an unreferenced row.
+// The upsert waits for a collector deleting the row, so content written
+// after Claim returns is never deleted by that collection.
+func (r *BlobRepository) Claim(ctx context.Context, sha256 string, size int64) (*domain.Blob, error) {
+	now := time.Now()
+	blob := domain.Blob{SHA256: sha256, Size: size, CreatedAt: now, UsedAt: now}
+	err := conn(ctx, r.db).Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "sha256"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"used_at": now}),
+		},
+		clause.Returning{},
+	).Create(&blob).Error
+	if err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// Touch marks a referenced blob as in use by an upload and returns it, or
+// nil if it has no references left and may be collected at any time
+func (r *BlobRepository) Touch(ctx context.Context, sha256 string) (*domain.Blob, error) {
+	var blobs []domain.Blob
+	err := conn(ctx, r.db).Model(&blobs).
+		Clauses(clause.Returning{}).
+		Where("sha256 = ? AND ref_count > 0", sha256).
+		Update("used_at", time.Now()).Error
+	if err != nil || len(blobs) == 0 {
+		return nil, err
+	}
+	return &blobs[0], nil
+}
+
+// retainBlob adds a reference to a blob, creating its row on first use
+func retainBlob(tx *gorm.DB, sha256 string, size int64) error {
+	blob := domain.Blob{SHA256: sha256, Size: size, RefCount: 1, CreatedAt: time.Now()}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "sha256"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("blobs.ref_count + 1")}),
+	}).Create(&blob).Error
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ChallengeRepository struct {
+	db *gorm.DB
+}
+
+func NewChallengeRepository(db *gorm.DB) *ChallengeRepository {
+	return &ChallengeRepository{db: db}
+}
+
+func (r *ChallengeRepository) Create(ctx context.Context, challenge *domain.Challenge) error {
+	return conn(ctx, r.db).Create(challenge).Error
+}
+
+// GetByID returns nil when the challenge does not exist
+func (r *ChallengeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Challenge, error) {
+	var challenge domain.Challenge
+	if err := conn(ctx, r.db).First(&challenge, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// GetByClubID returns the club's challenges, latest first
+func (r *ChallengeRepository) GetByClubID(ctx context.Context, clubID uuid.UUID) ([]domain.Challenge, error) {
+	var challenges []domain.Challenge
+	err := conn(ctx, r.db).Where("club_id = ?", clubID).Order("start_time DESC").Find(&challenges).Error
+	return challenges, err
+}
+
+// Delete removes the challenge with its participants and results
+func (r *ChallengeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("challenge_id = ?", id).Delete(&domain.ChallengeParticipant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("challenge_id = ?", id).Delete(&domain.ChallengeResult{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Challenge{}, "id = ?", id).Error
+	})
+}
+
+// Join adds the participant unless they already take part
+func (r *ChallengeRepository) Join(ctx context.Context, participant *domain.ChallengeParticipant) error {
+	return conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(participant).Error
+}
+
+func (r *ChallengeRepository) Leave(ctx context.Context, challengeID, userID uuid.UUID) error {
+	return conn(ctx, r.db).
+		Where("challenge_id = ? AND user_id = ?", challengeID, userID).
+		Delete(&domain.ChallengeParticipant{}).Error
+}
+
+// GetParticipants returns the challenge's participants with their names
+func (r *ChallengeRepository) GetParticipants(ctx context.Context, challengeID uuid.UUID) ([]domain.ChallengeParticipant, error) {
+	var participants []domain.ChallengeParticipant
+	err := conn(ctx, r.db).
+		Select("challenge_participants.*, users.name AS user_name").
+		Joins("JOIN users ON users.id = challenge_participants.user_id").
+		Where("challenge_participants.challenge_id = ?", challengeID).
+		Order("challenge_participants.joined_at ASC").
+		Find(&participants).Error
+	return participants, err
+}
+
+// Finalize keeps the results of an ended challenge unless they were kept
+// already, which happens when two requests finalize it at once
+func (r *ChallengeRepository) Finalize(ctx context.Context, challenge *domain.Challenge, results []domain.ChallengeResult, now time.Time) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Challenge{}).
+			Where("id = ? AND finalized_at IS NULL", challenge.ID).
+			Update("finalized_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		challenge.FinalizedAt = &now
+		if len(results) == 0 {
+			return nil
+		}
+		return tx.Create(&results).Error
+	})
+}
+
+// GetResults returns the kept results of a challenge by rank, with the
+// names of users who still exist
+func (r *ChallengeRepository) GetResults(ctx context.Context, challengeID uuid.UUID) ([]domain.ChallengeResult, error) {
+	var results []domain.ChallengeResult
+	err := conn(ctx, r.db).
+		Select("challenge_results.*, COALESCE(users.name, '') AS user_name").
+		Joins("LEFT JOIN users ON users.id = challenge_results.user_id").
+		Where("challenge_results.challenge_id = ?", challengeID).
+		Order("challenge_results.rank ASC, user_name ASC").
+		Find(&results).Error
+	return results, err
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+)
+
+func TestMemberActivityAsOf(t *testing.T) {
+	end := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	from := end.AddDate(0, 0, -7)
+
+	tests := []struct {
+		name    string
+		asOf    *time.Time
+		want    []string
+		wantNot []string
+	}{
+		{
+			name:    "live",
+			want:    []string{`projects.deleted_at IS NULL`, `"sessions"."deleted_at" IS NULL`},
+			wantNot: []string{"created_at"},
+		},
+		{
+			name: "as of the end",
+			asOf: &end,
+			want: []string{
+				`(projects.deleted_at IS NULL OR projects.deleted_at > $`,
+				`(sessions.deleted_at IS NULL OR sessions.deleted_at > $`,
+				`sessions.created_at <= $`,
+				`sessions.updated_at <= $`,
+			},
+			wantNot: []string{`"sessions"."deleted_at" IS NULL`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			_, err := NewReportRepository(db).MemberActivity(context.Background(), []uuid.UUID{uuid.New()}, from, end, "UTC", tt.asOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			statements := fake.Statements()
+			if len(statements) != 2 {
+				t.Fatalf("got %d queries, want 2:\n%s", len(statements), strings.Join(statements, "\n"))
+			}
+			for _, stmt := range statements {
+				for _, want := range tt.want {
+					if !strings.Contains(stmt, want) {
+						t.Errorf("query lacks %s:\n%s", want, stmt)
+					}
+				}
+				for _, unwanted := range tt.wantNot {
+					if strings.Contains(stmt, unwanted) {
+						t.Errorf("query has %s:\n%s", unwanted, stmt)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChallengeFinalize(t *testing.T) {
+	db, fake := newFakeDB(t)
+	challenge := &domain.Challenge{ID: uuid.New()}
+	results := []domain.ChallengeResult{
+		{ChallengeID: challenge.ID, UserID: uuid.New(), Rank: 1, Value: 7200},
+		{ChallengeID: challenge.ID, UserID: uuid.New(), Rank: 2, Value: 0},
+	}
+	now := time.Now()
+
+	if err := NewChallengeRepository(db).Finalize(context.Background(), challenge, results, now); err != nil {
+		t.Fatal(err)
+	}
+	if challenge.FinalizedAt == nil || !challenge.FinalizedAt.Equal(now) {
+		t.Errorf("finalized at %v, want %v", challenge.FinalizedAt, now)
+	}
+	claim := fake.index(`UPDATE "challenges" SET "finalized_at"=$1`, `finalized_at IS NULL`)
+	insert := fake.index(`INSERT INTO "challenge_results"`)
+	if claim < 0 || insert < claim {
+		t.Fatalf("results must be kept after claiming the challenge:\n%s", strings.Join(fake.Statements(), "\n"))
+	}
+	if args := fake.Args(insert); len(args) != 10 {
+		t.Errorf("inserted %d values, want both results", len(args))
+	}
+}
+
+func TestChallengeFinalizeOnce(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.affected = func(query string) int64 {
+		if strings.Contains(query, "finalized_at IS NULL") {
+			return 0 // finalized by another request
+		}
+		return 1
+	}
+	challenge := &domain.Challenge{ID: uuid.New()}
+	results := []domain.ChallengeResult{{ChallengeID: challenge.ID, UserID: uuid.New(), Rank: 1}}
+
+	if err := NewChallengeRepository(db).Finalize(context.Background(), challenge, results, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if challenge.FinalizedAt != nil || fake.index("challenge_results") >= 0 {
+		t.Fatalf("results kept twice:\n%s", strings.Join(fake.Statements(), "\n"))
+	}
+}
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ClubRepository struct {
+	db *gorm.DB
+}
+
+func NewClubRepository(db *gorm.DB) *ClubRepository {
+	return &ClubRepository{db: db}
+}
+
+// Create adds the club with its owner as the first member
+func (r *ClubRepository) Create(ctx context.Context, club *domain.Club) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(club).Error; err != nil {
+			return err
+		}
+		owner := domain.ClubMembership{ClubID: club.ID, UserID: club.OwnerID, Role: domain.ClubOwner, JoinedAt: club.CreatedAt}
+		if err := tx.Create(&owner).Error; err != nil {
+			return err
+		}
+		club.Members = []domain.ClubMembership{owner}
+		return nil
+	})
+}
+
+// GetByID returns nil when the club does not exist
+func (r *ClubRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Club, error) {
+	var club domain.Club
+	if err := conn(ctx, r.db).First(&club, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &club, nil
+}
+
+// GetByInviteCode returns nil when no club has the code
+func (r *ClubRepository) GetByInviteCode(ctx context.Context, code string) (*domain.Club, error) {
+	var club domain.Club
+	if err := conn(ctx, r.db).First(&club, "invite_code = ?", code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &club, nil
+}
+
+// GetByUserID returns the clubs the user is a member of
+func (r *ClubRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Club, error) {
+	var clubs []domain.Club
+	err := conn(ctx, r.db).
+		Joins("JOIN club_memberships ON club_memberships.club_id = clubs.id").
+		Where("club_memberships.user_id = ?", userID).
+		Order("clubs.name ASC").
+		Find(&clubs).Error
+	return clubs, err
+}
+
+func (r *ClubRepository) Update(ctx context.Context, club *domain.Club) error {
+	return conn(ctx, r.db).Save(club).Error
+}
+
+// Delete removes the club with its memberships, challenges and their
+// participants and results
+func (r *ClubRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		challenges := tx.Model(&domain.Challenge{}).Select("id").Where("club_id = ?", id)
+		if err := tx.Where("challenge_id IN (?)", challenges).Delete(&domain.ChallengeParticipant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("challenge_id IN (?)", challenges).Delete(&domain.ChallengeResult{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("club_id = ?", id).Delete(&domain.Challenge{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("club_id = ?", id).Delete(&domain.ClubMembership{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Club{}, "id = ?", id).Error
+	})
+}
+
+// GetMembership returns nil when the user is not a member of the club
+func (r *ClubRepository) GetMembership(ctx context.Context, clubID, userID uuid.UUID) (*domain.ClubMembership, error) {
+	var membership domain.ClubMembership
+	err := conn(ctx, r.db).First(&membership, "club_id = ? AND user_id = ?", clubID, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// GetMembers returns the club's members with their names, in the order
+// they joined
+func (r *ClubRepository) GetMembers(ctx context.Context, clubID uuid.UUID) ([]domain.ClubMembership, error) {
+	var members []domain.ClubMembership
+	err := conn(ctx, r.db).
+		Select("club_memberships.*, users.name AS user_name").
+		Joins("JOIN users ON users.id = club_memberships.user_id").
+		Where("club_memberships.club_id = ?", clubID).
+		Order("club_memberships.joined_at ASC").
+		Find(&members).Error
+	return members, err
+}
+
+// AddMember adds the membership unless the user already is a member
+func (r *ClubRepository) AddMember(ctx context.Context, membership *domain.ClubMembership) error {
+	return conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(membership).Error
+}
+
+func (r *ClubRepository) UpdateMember(ctx context.Context, membership *domain.ClubMembership) error {
+	return conn(ctx, r.db).Save(membership).Error
+}
+
+// RemoveMember ends the user's membership and takes them out of the club's
+// challenges that have not ended at now. Final standings keep them.
+func (r *ClubRepository) RemoveMember(ctx context.Context, clubID, userID uuid.UUID, now time.Time) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		running := tx.Model(&domain.Challenge{}).Select("id").Where("club_id = ? AND end_time > ?", clubID, now)
+		err := tx.Where("user_id = ? AND challenge_id IN (?)", userID, running).
+			Delete(&domain.ChallengeParticipant{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("club_id = ? AND user_id = ?", clubID, userID).Delete(&domain.ClubMembership{}).Error
+	})
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DocumentRepository struct {
+	db *gorm.DB
+}
+
+func NewDocumentRepository(db *gorm.DB) *DocumentRepository {
+	return &DocumentRepository{db: db}
+}
+
+// Create numbers an invoice with the next number of its issuer and saves the
+// document, whose file is stored afterwards. The counter row is locked only
+// while the document is inserted, so numbers have no duplicates even when
+// invoices are created concurrently.
+func (r *DocumentRepository) Create(ctx context.Context, document *domain.Document) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if document.Kind == domain.DocumentInvoice {
+			var last int
+			err := tx.Raw(`
+				INSERT INTO invoice_counters (issuer_id, last) VALUES (?, 1)
+				ON CONFLICT (issuer_id) DO UPDATE SET last = invoice_counters.last + 1
+				RETURNING last`, document.Issuer()).Scan(&last).Error
+			if err != nil {
+				return err
+			}
+			document.Sequence = &last
+			document.Number = domain.InvoiceNumber(last)
+		}
+
+		return tx.Create(document).Error
+	})
+}
+
+// SetFile records where the PDF of a document was stored
+func (r *DocumentRepository) SetFile(ctx context.Context, id uuid.UUID, storageKey string, size int64) error {
+	return conn(ctx, r.db).Model(&domain.Document{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"storage_key": storageKey,
+		"size":        size,
+	}).Error
+}
+
+// Discard deletes a document whose file could not be stored. An invoice's
+// number is given back unless a later invoice was numbered meanwhile, which
+// leaves a gap instead.
+func (r *DocumentRepository) Discard(ctx context.Context, document *domain.Document) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&domain.Document{}, "id = ?", document.ID).Error; err != nil {
+			return err
+		}
+		if document.Sequence == nil {
+			return nil
+		}
+		return tx.Model(&domain.InvoiceCounter{}).
+			Where("issuer_id = ? AND last = ?", document.Issuer(), *document.Sequence).
+			Update("last", gorm.Expr("last - 1")).Error
+	})
+}
+
+// GetByID returns nil when the document does not exist
+func (r *DocumentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
+	var document domain.Document
+	if err := conn(ctx, r.db).First(&document, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &document, nil
+}
+
+// GetByUserID returns one page of a user's documents, newest first, and the
+// total count
+func (r *DocumentRepository) GetByUserID(ctx context.Context, userID uuid.UUID, kind string, limit, offset int) ([]domain.Document, int64, error) {
+	query := conn(ctx, r.db).Model(&domain.Document{}).Where("user_id = ?", userID)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var documents []domain.Document
+	if err := query.Find(&documents).Error; err != nil {
+		return nil, 0, err
+	}
+	return documents, total, nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+)
+
+func TestDocumentCreateNumbersInvoicesPerIssuer(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.respond = func(query string) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "invoice_counters") {
+			return []string{"last"}, [][]driver.Value{{int64(7)}}
+		}
+		return nil, nil
+	}
+	documents := NewDocumentRepository(db)
+
+	user, club := uuid.New(), uuid.New()
+	tests := []struct {
+		name   string
+		clubID *uuid.UUID
+		issuer uuid.UUID
+	}{
+		{"personal", nil, user},
+		{"club", &club, club},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := &domain.Document{ID: uuid.New(), UserID: user, ClubID: tt.clubID, Kind: domain.DocumentInvoice}
+			if err := documents.Create(context.Background(), document); err != nil {
+				t.Fatal(err)
+			}
+			if document.Number != "INV-0007" || document.Sequence == nil || *document.Sequence != 7 {
+				t.Fatalf("numbered %q, want INV-0007", document.Number)
+			}
+
+			statements := fake.Statements()
+			i := len(statements) - 1
+			for i >= 0 && !strings.Contains(statements[i], "invoice_counters") {
+				i--
+			}
+			if i < 0 {
+				t.Fatal("no counter was incremented")
+			}
+			if args := fake.Args(i); len(args) != 1 || args[0] != tt.issuer {
+				t.Errorf("counted for %v, want %s", args, tt.issuer)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/ZigaoWang/zebra-server/internal/domain"
 	"github.com/google/uuid"
@@ -12,7 +13,7 @@ import (
 // GetFileByID retrieves a file by its ID
 func (r *SessionRepository) GetFileByID(ctx context.Context, id uuid.UUID) (*domain.File, error) {
 	var file domain.File
-	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&file).Error; err != nil {
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&file).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -24,7 +25,7 @@ func (r *SessionRepository) GetFileByID(ctx context.Context, id uuid.UUID) (*dom
 // GetRecordByID retrieves a record by its ID
 func (r *SessionRepository) GetRecordByID(ctx context.Context, id uuid.UUID) (*domain.Record, error) {
 	var record domain.Record
-	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&record).Error; err != nil {
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&record).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -32,3 +33,69 @@ func (r *SessionRepository) GetRecordByID(ctx context.Context, id uuid.UUID) (*d
 	}
 	return &record, nil
 }
+
+// UpdateFileDimensions records the pixel size of an image file
+func (r *SessionRepository) UpdateFileDimensions(ctx context.Context, id uuid.UUID, width, height int) error {
+	return conn(ctx, r.db).Model(&domain.File{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"width":      width,
+		"height":     height,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// UpdateRecordAudio saves the audio metadata and waveform of a record
+func (r *SessionRepository) UpdateRecordAudio(ctx context.Context, record *domain.Record) error {
+	return conn(ctx, r.db).Model(&domain.Record{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"audio_format":      record.AudioFormat,
+		"audio_codec":       record.AudioCodec,
+		"audio_duration":    record.AudioDuration,
+		"audio_sample_rate": record.AudioSampleRate,
+		"audio_channels":    record.AudioChannels,
+		"audio_peaks":       record.AudioPeaks,
+		"updated_at":        time.Now(),
+	}).Error
+}
+
+// UpdateRecordTranscript saves the transcription state of a record
+func (r *SessionRepository) UpdateRecordTranscript(ctx context.Context, record *domain.Record) error {
+	return conn(ctx, r.db).Model(&domain.Record{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"transcript_status": record.TranscriptStatus,
+		"transcript":        record.Transcript,
+		"transcript_words":  record.TranscriptWords,
+		"transcript_error":  record.TranscriptError,
+		"updated_at":        time.Now(),
+	}).Error
+}
+
+// GetPendingTranscripts returns the records waiting for a transcript,
+// oldest first. Records left processing since before staleBefore, by a
+// worker that stopped, are made pending again.
+func (r *SessionRepository) GetPendingTranscripts(ctx context.Context, staleBefore time.Time) ([]uuid.UUID, error) {
+	db := conn(ctx, r.db)
+	if err := db.Model(&domain.Record{}).
+		Where("transcript_status = ? AND updated_at < ?", domain.TranscriptProcessing, staleBefore).
+		Updates(map[string]interface{}{
+			"transcript_status": domain.TranscriptPending,
+			"updated_at":        time.Now(),
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	err := db.Model(&domain.Record{}).
+		Where("transcript_status = ?", domain.TranscriptPending).
+		Order("created_at").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// UpdateRecord saves the text, link and timestamp of a record
+func (r *SessionRepository) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	record.UpdatedAt = time.Now()
+	return conn(ctx, r.db).Model(&domain.Record{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"text":       record.Text,
+		"git_link":   record.GitLink,
+		"timestamp":  record.Timestamp,
+		"updated_at": record.UpdatedAt,
+	}).Error
+}
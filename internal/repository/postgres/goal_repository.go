@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GoalRepository struct {
+	db *gorm.DB
+}
+
+func NewGoalRepository(db *gorm.DB) *GoalRepository {
+	return &GoalRepository{db: db}
+}
+
+func (r *GoalRepository) Create(ctx context.Context, goal *domain.Goal) error {
+	return conn(ctx, r.db).Create(goal).Error
+}
+
+// GetByID returns the goal with its project name, or nil when it does not
+// exist
+func (r *GoalRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Goal, error) {
+	var goal domain.Goal
+	err := conn(ctx, r.db).
+		Select("goals.*, projects.name AS project_name").
+		Joins("JOIN projects ON projects.id = goals.project_id").
+		First(&goal, "goals.id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// GetByUserID returns the goals of the user's projects that are not in the
+// trash with their project names, optionally only those of the given
+// projects
+func (r *GoalRepository) GetByUserID(ctx context.Context, userID uuid.UUID, projectIDs []uuid.UUID) ([]domain.Goal, error) {
+	query := conn(ctx, r.db).
+		Select("goals.*, projects.name AS project_name").
+		Joins("JOIN projects ON projects.id = goals.project_id AND projects.deleted_at IS NULL").
+		Where("goals.user_id = ?", userID)
+	if len(projectIDs) > 0 {
+		query = query.Where("goals.project_id IN ?", projectIDs)
+	}
+
+	var goals []domain.Goal
+	err := query.Order("goals.created_at ASC").Find(&goals).Error
+	return goals, err
+}
+
+func (r *GoalRepository) Update(ctx context.Context, goal *domain.Goal) error {
+	return conn(ctx, r.db).Save(goal).Error
+}
+
+func (r *GoalRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.db).Delete(&domain.Goal{}, "id = ?", id).Error
+}
+
+// Tracked sums the rounded durations of the project's sessions starting in
+// from-to, where nil bounds are open
+func (r *GoalRepository) Tracked(ctx context.Context, projectID uuid.UUID, from, to *time.Time) (int64, error) {
+	query := conn(ctx, r.db).Model(&domain.Session{}).
+		Joins("JOIN projects ON projects.id = sessions.project_id").
+		Where("sessions.project_id = ?", projectID)
+	if from != nil {
+		query = query.Where("sessions.start_time >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("sessions.start_time < ?", *to)
+	}
+
+	var tracked int64
+	err := query.Select("COALESCE(SUM(" + roundedDurationSQL + "), 0)::bigint").Scan(&tracked).Error
+	return tracked, err
+}
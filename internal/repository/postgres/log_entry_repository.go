@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LogEntryRepository struct {
+	db *gorm.DB
+}
+
+func NewLogEntryRepository(db *gorm.DB) *LogEntryRepository {
+	return &LogEntryRepository{db: db}
+}
+
+func (r *LogEntryRepository) Create(ctx context.Context, entry *domain.LogEntry) error {
+	return conn(ctx, r.db).Create(entry).Error
+}
+
+// GetByID returns nil when the entry does not exist
+func (r *LogEntryRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.LogEntry, error) {
+	var entry domain.LogEntry
+	if err := conn(ctx, r.db).First(&entry, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetByWorkLogID returns one page of a work log's entries in the order they
+// were created, optionally only those of entryType, and the total count
+func (r *LogEntryRepository) GetByWorkLogID(ctx context.Context, workLogID uuid.UUID, entryType string, limit, offset int) ([]domain.LogEntry, int64, error) {
+	query := conn(ctx, r.db).Model(&domain.LogEntry{}).Where("work_log_id = ?", workLogID)
+	if entryType != "" {
+		query = query.Where("type = ?", entryType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("created_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var entries []domain.LogEntry
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func (r *LogEntryRepository) Update(ctx context.Context, entry *domain.LogEntry) error {
+	return conn(ctx, r.db).Save(entry).Error
+}
+
+func (r *LogEntryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.db).Delete(&domain.LogEntry{}, "id = ?", id).Error
+}
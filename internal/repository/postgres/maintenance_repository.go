@@ -0,0 +1,285 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaintenanceRepository runs the consistency queries used by garbage
+// collection across sessions, records, files and blobs
+type MaintenanceRepository struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceRepository(db *gorm.DB) *MaintenanceRepository {
+	return &MaintenanceRepository{db: db}
+}
+
+// OrphanedSessionIDs returns sessions whose project no longer exists
+func (r *MaintenanceRepository) OrphanedSessionIDs(ctx context.Context) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := conn(ctx, r.db).Unscoped().Model(&domain.Session{}).
+		Joins("LEFT JOIN projects ON projects.id = sessions.project_id").
+		Where("projects.id IS NULL").
+		Pluck("sessions.id", &ids).Error
+	return ids, err
+}
+
+// OrphanedRecordIDs returns records whose session no longer exists
+func (r *MaintenanceRepository) OrphanedRecordIDs(ctx context.Context) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := conn(ctx, r.db).Unscoped().Model(&domain.Record{}).
+		Joins("LEFT JOIN sessions ON sessions.id = records.session_id").
+		Where("sessions.id IS NULL").
+		Pluck("records.id", &ids).Error
+	return ids, err
+}
+
+// OrphanedFileIDs returns files whose record no longer exists
+func (r *MaintenanceRepository) OrphanedFileIDs(ctx context.Context) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := conn(ctx, r.db).Unscoped().Model(&domain.File{}).
+		Joins("LEFT JOIN records ON records.id = files.record_id").
+		Where("records.id IS NULL").
+		Pluck("files.id", &ids).Error
+	return ids, err
+}
+
+// OrphanedThumbnailIDs returns thumbnails whose file no longer exists
+func (r *MaintenanceRepository) OrphanedThumbnailIDs(ctx context.Context) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := conn(ctx, r.db).Unscoped().Model(&domain.Thumbnail{}).
+		Joins("LEFT JOIN files ON files.id = thumbnails.file_id").
+		Where("files.id IS NULL").
+		Pluck("thumbnails.id", &ids).Error
+	return ids, err
+}
+
+// DeleteSessions deletes sessions together with their records and files,
+// including any in the trash
+func (r *MaintenanceRepository) DeleteSessions(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		records := tx.Model(&domain.Record{}).Select("id").Where("session_id IN ?", ids)
+		if err := deleteFilesWhere(tx, "record_id IN (?)", records); err != nil {
+			return err
+		}
+		if err := tx.Where("session_id IN ?", ids).Delete(&domain.Record{}).Error; err != nil {
+			return err
+		}
+		if err := unlinkWorkLogs(tx, ids); err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&domain.Session{}).Error
+	})
+}
+
+// DeleteRecords deletes records together with their files
+func (r *MaintenanceRepository) DeleteRecords(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		if err := deleteFilesWhere(tx, "record_id IN ?", ids); err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&domain.Record{}).Error
+	})
+}
+
+// DeleteFiles deletes files together with their thumbnails
+func (r *MaintenanceRepository) DeleteFiles(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return deleteFilesWhere(tx, "id IN ?", ids)
+	})
+}
+
+func (r *MaintenanceRepository) DeleteThumbnails(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).Where("id IN ?", ids).Delete(&domain.Thumbnail{}).Error
+}
+
+// deleteFilesWhere permanently deletes the matching files and their
+// thumbnail rows. Blob reference counts are left to ReconcileBlobRefCounts.
+func deleteFilesWhere(tx *gorm.DB, query string, args ...interface{}) error {
+	tx = tx.Unscoped().Session(&gorm.Session{})
+	files := tx.Model(&domain.File{}).Select("id").Where(query, args...)
+	if err := tx.Where("file_id IN (?)", files).Delete(&domain.Thumbnail{}).Error; err != nil {
+		return err
+	}
+	return tx.Where(query, args...).Delete(&domain.File{}).Error
+}
+
+// BlobRefCountMismatches compares every blob's reference count with the
+// files that actually reference it
+func (r *MaintenanceRepository) BlobRefCountMismatches(ctx context.Context) ([]domain.BlobRefCountMismatch, error) {
+	var mismatches []domain.BlobRefCountMismatch
+	err := conn(ctx, r.db).Raw(`
+		SELECT blobs.sha256, blobs.ref_count, COUNT(files.id) AS actual
+		FROM blobs
+		LEFT JOIN files ON files.sha256 = blobs.sha256
+		GROUP BY blobs.sha256, blobs.ref_count
+		HAVING blobs.ref_count <> COUNT(files.id)`).Scan(&mismatches).Error
+	return mismatches, err
+}
+
+// ReconcileBlobRefCounts resets every reference count to the number of
+// files referencing the blob
+func (r *MaintenanceRepository) ReconcileBlobRefCounts(ctx context.Context) error {
+	return conn(ctx, r.db).Exec(`
+		UPDATE blobs SET ref_count = (
+			SELECT COUNT(*) FROM files WHERE files.sha256 = blobs.sha256
+		)`).Error
+}
+
+// UnreferencedBlobs returns blobs created before cutoff that no file uses
+func (r *MaintenanceRepository) UnreferencedBlobs(ctx context.Context, cutoff time.Time) ([]domain.Blob, error) {
+	var blobs []domain.Blob
+	err := conn(ctx, r.db).
+		Where("ref_count = 0 AND used_at < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM files WHERE files.sha256 = blobs.sha256)").
+		Find(&blobs).Error
+	return blobs, err
+}
+
+// DeleteUnreferencedBlob removes a blob row if it is still unused and was
+// not claimed since cutoff, calling deleteContent before committing. The
+// deleted row stays locked meanwhile, so uploads claiming the blob wait
+// and write its content again afterwards. Reports whether it deleted.
+func (r *MaintenanceRepository) DeleteUnreferencedBlob(ctx context.Context, sha256 string, cutoff time.Time, deleteContent func() error) (bool, error) {
+	deleted := false
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("sha256 = ? AND ref_count = 0 AND used_at < ?", sha256, cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM files WHERE files.sha256 = blobs.sha256)").
+			Delete(&domain.Blob{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := deleteContent(); err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+// BlobHashes returns the hashes of all known blobs
+func (r *MaintenanceRepository) BlobHashes(ctx context.Context) ([]string, error) {
+	var hashes []string
+	err := conn(ctx, r.db).Model(&domain.Blob{}).Pluck("sha256", &hashes).Error
+	return hashes, err
+}
+
+// MissingBlobHashes returns hashes referenced by files without a blob row
+func (r *MaintenanceRepository) MissingBlobHashes(ctx context.Context) ([]string, error) {
+	var hashes []string
+	err := conn(ctx, r.db).Unscoped().Model(&domain.File{}).
+		Joins("LEFT JOIN blobs ON blobs.sha256 = files.sha256").
+		Where("files.sha256 <> '' AND blobs.sha256 IS NULL").
+		Distinct().
+		Pluck("files.sha256", &hashes).Error
+	return hashes, err
+}
+
+// ThumbnailKeys returns the storage keys of all thumbnails
+func (r *MaintenanceRepository) ThumbnailKeys(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := conn(ctx, r.db).Model(&domain.Thumbnail{}).Pluck("storage_key", &keys).Error
+	return keys, err
+}
+
+// ExpiredTrash counts the rows that have been in the trash since before
+// cutoff
+func (r *MaintenanceRepository) ExpiredTrash(ctx context.Context, cutoff time.Time) (domain.TrashCounts, error) {
+	return countExpiredTrash(conn(ctx, r.db), cutoff)
+}
+
+func countExpiredTrash(db *gorm.DB, cutoff time.Time) (domain.TrashCounts, error) {
+	var counts domain.TrashCounts
+	for _, c := range []struct {
+		model interface{}
+		count *int64
+	}{
+		{&domain.Project{}, &counts.Projects},
+		{&domain.Session{}, &counts.Sessions},
+		{&domain.Record{}, &counts.Records},
+		{&domain.File{}, &counts.Files},
+	} {
+		if err := db.Unscoped().Model(c.model).Where("deleted_at < ?", cutoff).Count(c.count).Error; err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
+
+// unlinkWorkLogs clears the session of work logs converted into sessions
+// about to be deleted for good, which may be converted again afterwards
+func unlinkWorkLogs(tx *gorm.DB, sessionIDs interface{}) error {
+	return tx.Model(&domain.WorkLog{}).Where("session_id IN (?)", sessionIDs).Update("session_id", nil).Error
+}
+
+// PurgeTrash permanently deletes rows that have been in the trash since
+// before cutoff and releases the blobs their files referenced. Children are
+// trashed no later than their parents, so they expire first or together.
+func (r *MaintenanceRepository) PurgeTrash(ctx context.Context, cutoff time.Time) (domain.TrashCounts, error) {
+	var counts domain.TrashCounts
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if counts, err = countExpiredTrash(tx, cutoff); err != nil {
+			return err
+		}
+		if counts.Total() == 0 {
+			return nil
+		}
+
+		if err := tx.Exec(`
+			UPDATE blobs SET ref_count = GREATEST(blobs.ref_count - expired.refs, 0)
+			FROM (
+				SELECT sha256, COUNT(*) AS refs FROM files
+				WHERE deleted_at < ? AND sha256 <> ''
+				GROUP BY sha256
+			) expired
+			WHERE blobs.sha256 = expired.sha256`, cutoff).Error; err != nil {
+			return err
+		}
+
+		if err := deleteFilesWhere(tx, "deleted_at < ?", cutoff); err != nil {
+			return err
+		}
+		tx = tx.Unscoped().Session(&gorm.Session{})
+		if err := tx.Where("deleted_at < ?", cutoff).Delete(&domain.Record{}).Error; err != nil {
+			return err
+		}
+		expiredSessions := tx.Model(&domain.Session{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := unlinkWorkLogs(tx, expiredSessions); err != nil {
+			return err
+		}
+		if err := tx.Where("deleted_at < ?", cutoff).Delete(&domain.Session{}).Error; err != nil {
+			return err
+		}
+		expired := tx.Model(&domain.Project{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := tx.Where("project_id IN (?)", expired).Delete(&domain.Goal{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id IN (?)", expired).Delete(&domain.PlannedBlock{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("deleted_at < ?", cutoff).Delete(&domain.Project{}).Error
+	})
+	return counts, err
+}
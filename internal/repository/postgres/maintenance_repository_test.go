@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+)
+
+func TestPurgeTrashUnlinksConvertedWorkLogs(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.respond = func(query string) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "count(*)") {
+			return []string{"count"}, [][]driver.Value{{int64(1)}}
+		}
+		return nil, nil
+	}
+
+	if _, err := NewMaintenanceRepository(db).PurgeTrash(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	unlink := fake.index(`UPDATE "work_logs" SET "session_id"=$1`, `WHERE session_id IN (SELECT "id" FROM "sessions" WHERE deleted_at <`)
+	purge := fake.index(`DELETE FROM "sessions" WHERE deleted_at <`)
+	if unlink < 0 || purge < 0 || unlink > purge {
+		t.Fatalf("work logs must be unlinked before sessions are purged:\n%s", strings.Join(fake.Statements(), "\n"))
+	}
+	if args := fake.Args(unlink); args[0] != nil {
+		t.Fatalf("session_id set to %v, want NULL", args[0])
+	}
+}
+
+func TestDeleteSessionsUnlinksConvertedWorkLogs(t *testing.T) {
+	db, fake := newFakeDB(t)
+
+	if err := NewMaintenanceRepository(db).DeleteSessions(context.Background(), []uuid.UUID{uuid.New()}); err != nil {
+		t.Fatal(err)
+	}
+	unlink := fake.index(`UPDATE "work_logs" SET "session_id"=$1`)
+	purge := fake.index(`DELETE FROM "sessions"`)
+	if unlink < 0 || purge < 0 || unlink > purge {
+		t.Fatalf("work logs must be unlinked before sessions are deleted:\n%s", strings.Join(fake.Statements(), "\n"))
+	}
+	if args := fake.Args(unlink); args[0] != nil {
+		t.Fatalf("session_id set to %v, want NULL", args[0])
+	}
+}
+
+func TestRestoreKeepsConvertedWorkLogLinked(t *testing.T) {
+	db, fake := newFakeDB(t)
+
+	item := &domain.TrashItem{Kind: domain.TrashSession, ID: uuid.New(), DeletedAt: time.Now()}
+	if err := NewTrashRepository(db).Restore(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+	if i := fake.index("work_logs"); i >= 0 {
+		t.Fatalf("restore touched work logs: %s", fake.Statements()[i])
+	}
+	if fake.index(`UPDATE "sessions" SET "deleted_at"=$1`) < 0 {
+		t.Fatalf("session not restored:\n%s", strings.Join(fake.Statements(), "\n"))
+	}
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// CreateOnce saves the notification unless the user already has one with
+// the same key, and reports whether it was saved
+func (r *NotificationRepository) CreateOnce(ctx context.Context, notification *domain.Notification) (bool, error) {
+	result := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "key"}},
+		DoNothing: true,
+	}).Create(notification)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetByUserID returns one page of the user's notifications, newest first,
+// and the total count
+func (r *NotificationRepository) GetByUserID(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]domain.Notification, int64, error) {
+	query := conn(ctx, r.db).Model(&domain.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var notifications []domain.Notification
+	if err := query.Find(&notifications).Error; err != nil {
+		return nil, 0, err
+	}
+	return notifications, total, nil
+}
+
+// MarkRead marks the user's notification with the given ID as read, or all
+// of them when id is nil, and returns how many changed
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID uuid.UUID, id *uuid.UUID) (int64, error) {
+	query := conn(ctx, r.db).Model(&domain.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID)
+	if id != nil {
+		query = query.Where("id = ?", *id)
+	}
+	result := query.Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PlanRepository struct {
+	db *gorm.DB
+}
+
+func NewPlanRepository(db *gorm.DB) *PlanRepository {
+	return &PlanRepository{db: db}
+}
+
+func (r *PlanRepository) Create(ctx context.Context, block *domain.PlannedBlock) error {
+	return conn(ctx, r.db).Create(block).Error
+}
+
+// GetByID returns the block with its project name, or nil when it does not
+// exist
+func (r *PlanRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.PlannedBlock, error) {
+	var block domain.PlannedBlock
+	err := conn(ctx, r.db).
+		Select("planned_blocks.*, projects.name AS project_name").
+		Joins("JOIN projects ON projects.id = planned_blocks.project_id").
+		First(&block, "planned_blocks.id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &block, nil
+}
+
+// GetByUserID returns the user's blocks overlapping from-to on projects not
+// in the trash, in order, optionally only those of the given projects
+func (r *PlanRepository) GetByUserID(ctx context.Context, userID uuid.UUID, from, to time.Time, projectIDs []uuid.UUID) ([]domain.PlannedBlock, error) {
+	query := conn(ctx, r.db).
+		Select("planned_blocks.*, projects.name AS project_name").
+		Joins("JOIN projects ON projects.id = planned_blocks.project_id AND projects.deleted_at IS NULL").
+		Where("planned_blocks.user_id = ? AND planned_blocks.start_time < ? AND planned_blocks.end_time > ?", userID, to, from)
+	if len(projectIDs) > 0 {
+		query = query.Where("planned_blocks.project_id IN ?", projectIDs)
+	}
+
+	var blocks []domain.PlannedBlock
+	err := query.Order("planned_blocks.start_time ASC").Find(&blocks).Error
+	return blocks, err
+}
+
+func (r *PlanRepository) Update(ctx context.Context, block *domain.PlannedBlock) error {
+	return conn(ctx, r.db).Save(block).Error
+}
+
+func (r *PlanRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.db).Delete(&domain.PlannedBlock{}, "id = ?", id).Error
+}
@@ -17,12 +17,12 @@ func NewProjectRepository(db *gorm.DB) *ProjectRepository {
 }
 
 func (r *ProjectRepository) Create(ctx context.Context, project *domain.Project) error {
-	return r.db.WithContext(ctx).Create(project).Error
+	return conn(ctx, r.db).Create(project).Error
 }
 
 func (r *ProjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Project, error) {
 	var project domain.Project
-	if err := r.db.WithContext(ctx).Preload("Sessions").Preload("Sessions.Records").Preload("Sessions.Records.Files").First(&project, "id = ?", id).Error; err != nil {
+	if err := conn(ctx, r.db).Preload("Sessions").Preload("Sessions.Records").Preload("Sessions.Records.Files").First(&project, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &project, nil
@@ -30,16 +30,23 @@ func (r *ProjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.
 
 func (r *ProjectRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Project, error) {
 	var projects []domain.Project
-	if err := r.db.WithContext(ctx).Preload("Sessions").Preload("Sessions.Records").Preload("Sessions.Records.Files").Where("user_id = ?", userID).Find(&projects).Error; err != nil {
+	if err := conn(ctx, r.db).Preload("Sessions").Preload("Sessions.Records").Preload("Sessions.Records.Files").Where("user_id = ?", userID).Find(&projects).Error; err != nil {
 		return nil, err
 	}
 	return projects, nil
 }
 
 func (r *ProjectRepository) Update(ctx context.Context, project *domain.Project) error {
-	return r.db.WithContext(ctx).Save(project).Error
+	return conn(ctx, r.db).Save(project).Error
 }
 
+// Delete moves a project with its sessions, records and files to the trash
 func (r *ProjectRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&domain.Project{}, "id = ?", id).Error
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		at := trashTime()
+		if err := trashSessionsWhere(tx, at, "project_id = ?", id); err != nil {
+			return err
+		}
+		return tx.Model(&domain.Project{}).Where("id = ?", id).Update("deleted_at", at).Error
+	})
 }
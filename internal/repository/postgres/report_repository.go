@@ -0,0 +1,257 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// roundedDurationSQL applies the project's rounding rule to a session's
+// duration the same way domain.RoundDuration does
+const roundedDurationSQL = `CASE WHEN projects.rounding_minutes > 0 AND sessions.duration > 0 THEN
+	(CASE projects.rounding_mode
+		WHEN 'up' THEN CEIL(sessions.duration / (projects.rounding_minutes * 60.0))
+		WHEN 'down' THEN FLOOR(sessions.duration / (projects.rounding_minutes * 60.0))
+		ELSE ROUND(sessions.duration / (projects.rounding_minutes * 60.0))
+	END) * projects.rounding_minutes * 60
+ELSE sessions.duration END`
+
+// reportTotalsSQL aggregates the selected sessions
+const reportTotalsSQL = "COALESCE(SUM(sessions.duration), 0)::bigint AS duration, " +
+	"COALESCE(SUM(" + roundedDurationSQL + "), 0)::bigint AS rounded_duration, " +
+	"COUNT(DISTINCT sessions.id) AS sessions"
+
+// localDateFormats are the to_char patterns of the date groupings
+var localDateFormats = map[string]string{
+	domain.GroupByDay:   "YYYY-MM-DD",
+	domain.GroupByWeek:  `IYYY-"W"IW`,
+	domain.GroupByMonth: "YYYY-MM",
+}
+
+type ReportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// reportSessions selects the sessions matching filter joined with their
+// projects. Trashed sessions and projects are left out.
+func reportSessions(db *gorm.DB, filter repository.ReportFilter) *gorm.DB {
+	query := db.Model(&domain.Session{}).
+		Joins("JOIN projects ON projects.id = sessions.project_id AND projects.deleted_at IS NULL").
+		Where("projects.user_id = ?", filter.UserID).
+		Where("sessions.start_time >= ? AND sessions.start_time < ?", filter.From, filter.To)
+	if len(filter.ProjectIDs) > 0 {
+		query = query.Where("sessions.project_id IN ?", filter.ProjectIDs)
+	}
+	if len(filter.Tags) > 0 {
+		query = query.Where("sessions.tags @> ?::text[]", domain.StringArray(filter.Tags))
+	}
+	return query
+}
+
+// Summary totals the matching sessions and breaks them down by groupBy,
+// aggregating in the database
+func (r *ReportRepository) Summary(ctx context.Context, filter repository.ReportFilter, groupBy string) (*domain.ReportSummary, error) {
+	db := conn(ctx, r.db)
+	summary := &domain.ReportSummary{
+		GroupBy:  groupBy,
+		From:     filter.From,
+		To:       filter.To,
+		Timezone: filter.Timezone,
+		Groups:   []domain.ReportGroup{},
+	}
+
+	var totals struct {
+		Duration        int64
+		RoundedDuration int64
+		Sessions        int64
+	}
+	if err := reportSessions(db, filter).Select(reportTotalsSQL).Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	summary.Duration = totals.Duration
+	summary.RoundedDuration = totals.RoundedDuration
+	summary.Sessions = totals.Sessions
+
+	query := reportSessions(db, filter)
+	switch groupBy {
+	case domain.GroupByProject:
+		query = query.Select("projects.id::text AS key, projects.name AS label, " + reportTotalsSQL)
+	case domain.GroupByTag:
+		query = query.Joins("LEFT JOIN LATERAL unnest(sessions.tags) AS tag ON TRUE").
+			Select("COALESCE(tag, '') AS key, COALESCE(tag, '') AS label, " + reportTotalsSQL)
+	default:
+		format, ok := localDateFormats[groupBy]
+		if !ok {
+			return nil, fmt.Errorf("unknown report grouping %q", groupBy)
+		}
+		local := "to_char(sessions.start_time AT TIME ZONE ?, ?)"
+		query = query.Select(local+" AS key, "+local+" AS label, "+reportTotalsSQL,
+			filter.Timezone, format, filter.Timezone, format)
+	}
+
+	// Output column names keep GROUP BY in step with the select list
+	err := query.Group("key, label").Order("key").Scan(&summary.Groups).Error
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// exportColumnsSQL selects one export row per session, with its record
+// count and the text of its earliest record that has any
+const exportColumnsSQL = "sessions.id AS session_id, sessions.project_id, projects.name AS project_name, " +
+	"sessions.start_time, sessions.end_time, sessions.duration, " +
+	"(" + roundedDurationSQL + ")::bigint AS rounded_duration, sessions.manual, sessions.tags, " +
+	"(SELECT COUNT(*) FROM records WHERE records.session_id = sessions.id AND records.deleted_at IS NULL) AS records, " +
+	"COALESCE((SELECT records.text FROM records WHERE records.session_id = sessions.id " +
+	"AND records.deleted_at IS NULL AND records.text <> '' ORDER BY records.timestamp LIMIT 1), '') AS first_note, " +
+	"projects.hourly_rate AS project_rate"
+
+// ExportSessions calls fn for each matching session in start order. Rows
+// are read from a cursor so large ranges are not loaded into memory.
+func (r *ReportRepository) ExportSessions(ctx context.Context, filter repository.ReportFilter, fn func(row *domain.ExportRow) error) error {
+	db := conn(ctx, r.db)
+	rows, err := reportSessions(db, filter).Select(exportColumnsSQL).Order("sessions.start_time ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row domain.ExportRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// localDateSQL is the local date a session starts on
+const localDateSQL = "to_char(sessions.start_time AT TIME ZONE ?, 'YYYY-MM-DD')"
+
+// TopNotes returns the longest record texts of the matching sessions,
+// which tend to be the most telling
+func (r *ReportRepository) TopNotes(ctx context.Context, filter repository.ReportFilter, limit int) ([]domain.DigestNote, error) {
+	var notes []domain.DigestNote
+	err := reportSessions(conn(ctx, r.db), filter).
+		Joins("JOIN records ON records.session_id = sessions.id AND records.deleted_at IS NULL").
+		Where("TRIM(records.text) <> ''").
+		Select("projects.name AS project_name, records.text, records.timestamp").
+		Order("LENGTH(records.text) DESC, records.timestamp DESC").
+		Limit(limit).
+		Scan(&notes).Error
+	return notes, err
+}
+
+// Activity collects the per-day totals and the hours of the week of the
+// matching sessions, and the dates of all of the user's time. Sessions are
+// spread over the hours they cover by wall clock, each hour's share scaled
+// by duration over interval so paused time is left out as in the days.
+func (r *ReportRepository) Activity(ctx context.Context, filter repository.ReportFilter) (*domain.ActivityData, error) {
+	db := conn(ctx, r.db)
+	data := &domain.ActivityData{}
+	tz := filter.Timezone
+
+	err := reportSessions(db, filter).
+		Select(localDateSQL+" AS date, COALESCE(SUM(sessions.duration), 0)::bigint AS duration, COUNT(*) AS sessions", tz).
+		Group("date").Order("date").
+		Scan(&data.Days).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Model(&domain.Session{}).
+		Joins("JOIN projects ON projects.id = sessions.project_id AND projects.deleted_at IS NULL").
+		Where("projects.user_id = ? AND sessions.duration > 0", filter.UserID).
+		Select(localDateSQL+" AS date", tz).
+		Group("date").Order("date").
+		Scan(&data.ActiveDates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = reportSessions(db, filter).
+		Joins("CROSS JOIN LATERAL generate_series(date_trunc('hour', sessions.start_time AT TIME ZONE ?), "+
+			"sessions.end_time AT TIME ZONE ?, interval '1 hour') AS hour_start", tz, tz).
+		Where("sessions.end_time > sessions.start_time").
+		Select("EXTRACT(ISODOW FROM hour_start)::int AS weekday, EXTRACT(HOUR FROM hour_start)::int AS hour, "+
+			"SUM(EXTRACT(EPOCH FROM LEAST(hour_start + interval '1 hour', sessions.end_time AT TIME ZONE ?) "+
+			"- GREATEST(hour_start, sessions.start_time AT TIME ZONE ?)) "+
+			"* sessions.duration / EXTRACT(EPOCH FROM sessions.end_time - sessions.start_time))::bigint AS duration", tz, tz).
+		Group("weekday, hour").Order("weekday, hour").
+		Scan(&data.Hours).Error
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// MemberActivity totals the sessions of each of the users starting in
+// from-to and lists the local dates they tracked time on. Users without
+// sessions are left out. With asOf, sessions are counted as they stood
+// then: those created or changed later are left out, those trashed later
+// are kept.
+func (r *ReportRepository) MemberActivity(ctx context.Context, userIDs []uuid.UUID, from, to time.Time, timezone string, asOf *time.Time) ([]domain.MemberActivity, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	db := conn(ctx, r.db)
+	members := func() *gorm.DB {
+		if asOf == nil {
+			return db.Model(&domain.Session{}).
+				Joins("JOIN projects ON projects.id = sessions.project_id AND projects.deleted_at IS NULL").
+				Where("projects.user_id IN ?", userIDs).
+				Where("sessions.start_time >= ? AND sessions.start_time < ?", from, to)
+		}
+		return db.Unscoped().Model(&domain.Session{}).
+			Joins("JOIN projects ON projects.id = sessions.project_id AND (projects.deleted_at IS NULL OR projects.deleted_at > ?)", *asOf).
+			Where("projects.user_id IN ?", userIDs).
+			Where("sessions.start_time >= ? AND sessions.start_time < ?", from, to).
+			Where("sessions.deleted_at IS NULL OR sessions.deleted_at > ?", *asOf).
+			Where("sessions.created_at <= ? AND sessions.updated_at <= ?", *asOf, *asOf)
+	}
+
+	var activity []domain.MemberActivity
+	err := members().
+		Select("projects.user_id, COALESCE(SUM(sessions.duration), 0)::bigint AS duration, COUNT(*) AS sessions").
+		Group("projects.user_id").Order("projects.user_id").
+		Scan(&activity).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var dates []struct {
+		UserID uuid.UUID
+		Date   string
+	}
+	err = members().
+		Where("sessions.duration > 0").
+		Select("projects.user_id, "+localDateSQL+" AS date", timezone).
+		Group("projects.user_id, date").Order("date").
+		Scan(&dates).Error
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[uuid.UUID]*domain.MemberActivity, len(activity))
+	for i := range activity {
+		byUser[activity[i].UserID] = &activity[i]
+	}
+	for _, d := range dates {
+		if a, ok := byUser[d.UserID]; ok {
+			a.ActiveDates = append(a.ActiveDates, d.Date)
+		}
+	}
+	return activity, nil
+}
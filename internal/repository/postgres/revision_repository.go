@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RevisionRepository stores revisions. They are never updated or deleted.
+type RevisionRepository struct {
+	db *gorm.DB
+}
+
+func NewRevisionRepository(db *gorm.DB) *RevisionRepository {
+	return &RevisionRepository{db: db}
+}
+
+func (r *RevisionRepository) Create(ctx context.Context, revision *domain.Revision) error {
+	return conn(ctx, r.db).Create(revision).Error
+}
+
+// GetByID returns nil when the revision does not exist
+func (r *RevisionRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Revision, error) {
+	var revision domain.Revision
+	if err := conn(ctx, r.db).First(&revision, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// GetByEntity returns one page of an entity's revisions, newest first, and
+// the total count
+func (r *RevisionRepository) GetByEntity(ctx context.Context, entityType string, entityID uuid.UUID, limit, offset int) ([]domain.Revision, int64, error) {
+	query := conn(ctx, r.db).Model(&domain.Revision{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	var revisions []domain.Revision
+	if err := query.Find(&revisions).Error; err != nil {
+		return nil, 0, err
+	}
+	return revisions, total, nil
+}
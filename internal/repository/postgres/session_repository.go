@@ -2,12 +2,14 @@ package postgres
 
 import (
 	"context"
-	"fmt"
+	"slices"
 	"time"
 
 	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SessionRepository struct {
@@ -19,17 +21,11 @@ func NewSessionRepository(db *gorm.DB) *SessionRepository {
 }
 
 func (r *SessionRepository) Create(ctx context.Context, projectID uuid.UUID, session *domain.Session) error {
-	// Start a transaction
-	tx := r.db.WithContext(ctx).Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
-
 	// Set session ID if not set
 	if session.ID == uuid.Nil {
 		session.ID = uuid.New()
 	}
-	
+
 	// Set project ID
 	session.ProjectID = projectID
 
@@ -50,111 +46,121 @@ func (r *SessionRepository) Create(ctx context.Context, projectID uuid.UUID, ses
 		// Use UTC times to avoid timezone issues
 		startTimeUTC := session.StartTime.UTC()
 		endTimeUTC := session.EndTime.UTC()
-		
-		// Print times for debugging
-		fmt.Printf("StartTime: %v, EndTime: %v\n", startTimeUTC, endTimeUTC)
-		
+
 		// Calculate duration in seconds
 		duration := endTimeUTC.Sub(startTimeUTC).Seconds()
 		session.Duration = int64(duration)
-		
-		fmt.Printf("Calculated duration: %d seconds\n", session.Duration)
-	}
-
-	// Create the session first without the records
-	sessionCopy := domain.Session{
-		ID:        session.ID,
-		ProjectID: session.ProjectID,
-		StartTime: session.StartTime,
-		EndTime:   session.EndTime,
-		Duration:  session.Duration,
-		CreatedAt: session.CreatedAt,
-		UpdatedAt: session.UpdatedAt,
-	}
-	
-	if err := tx.Create(&sessionCopy).Error; err != nil {
-		tx.Rollback()
-		return err
 	}
 
-	// Create records one by one
-	for i := range session.Records {
-		record := &session.Records[i]
-
-		// Always generate a new ID for records
-		record.ID = uuid.New()
-		record.SessionID = session.ID
-		
-		// Set timestamps
-		if record.CreatedAt.IsZero() {
-			record.CreatedAt = now
-		}
-		record.UpdatedAt = now
-
-		// Set timestamp if not set
-		if record.Timestamp.IsZero() {
-			record.Timestamp = now
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// Create the session first without the records
+		sessionCopy := domain.Session{
+			ID:        session.ID,
+			ProjectID: session.ProjectID,
+			StartTime: session.StartTime,
+			EndTime:   session.EndTime,
+			Duration:  session.Duration,
+			Manual:    session.Manual,
+			Tags:      session.Tags,
+			CreatedAt: session.CreatedAt,
+			UpdatedAt: session.UpdatedAt,
 		}
 
-		// Create a copy of the record without the files
-		recordCopy := domain.Record{
-			ID:        record.ID,
-			SessionID: record.SessionID,
-			Text:      record.Text,
-			GitLink:   record.GitLink,
-			AudioURL:  record.AudioURL,
-			AudioData: record.AudioData,
-			Timestamp: record.Timestamp,
-			CreatedAt: record.CreatedAt,
-			UpdatedAt: record.UpdatedAt,
-		}
-
-		if err := tx.Create(&recordCopy).Error; err != nil {
-			tx.Rollback()
+		if err := tx.Create(&sessionCopy).Error; err != nil {
 			return err
 		}
 
-		// Create files for this record
-		for j := range record.Files {
-			file := &record.Files[j]
+		// Create records one by one
+		for i := range session.Records {
+			record := &session.Records[i]
+
+			// Always generate a new ID for records
+			record.ID = uuid.New()
+			record.SessionID = session.ID
 
-			// Always generate a new ID for files
-			file.ID = uuid.New()
-			file.RecordID = record.ID
-			
 			// Set timestamps
-			if file.CreatedAt.IsZero() {
-				file.CreatedAt = now
-			}
-			file.UpdatedAt = now
-
-			// Create a copy of the file
-			fileCopy := domain.File{
-				ID:        file.ID,
-				RecordID:  file.RecordID,
-				Name:      file.Name,
-				URL:       file.URL,
-				Type:      file.Type,
-				Size:      file.Size,
-				Data:      file.Data,
-				CreatedAt: file.CreatedAt,
-				UpdatedAt: file.UpdatedAt,
-			}
-
-			if err := tx.Create(&fileCopy).Error; err != nil {
-				tx.Rollback()
+			if record.CreatedAt.IsZero() {
+				record.CreatedAt = now
+			}
+			record.UpdatedAt = now
+
+			// Set timestamp if not set
+			if record.Timestamp.IsZero() {
+				record.Timestamp = now
+			}
+
+			// Create a copy of the record without the files
+			recordCopy := domain.Record{
+				ID:               record.ID,
+				SessionID:        record.SessionID,
+				Text:             record.Text,
+				GitLink:          record.GitLink,
+				AudioURL:         record.AudioURL,
+				AudioData:        record.AudioData,
+				AudioFormat:      record.AudioFormat,
+				AudioCodec:       record.AudioCodec,
+				AudioDuration:    record.AudioDuration,
+				AudioSampleRate:  record.AudioSampleRate,
+				AudioChannels:    record.AudioChannels,
+				TranscriptStatus: record.TranscriptStatus,
+				Timestamp:        record.Timestamp,
+				CreatedAt:        record.CreatedAt,
+				UpdatedAt:        record.UpdatedAt,
+			}
+
+			if err := tx.Create(&recordCopy).Error; err != nil {
 				return err
 			}
-		}
-	}
 
-	// Commit the transaction
-	return tx.Commit().Error
+			// Create files for this record
+			for j := range record.Files {
+				file := &record.Files[j]
+
+				// Always generate a new ID for files
+				file.ID = uuid.New()
+				file.RecordID = record.ID
+
+				// Set timestamps
+				if file.CreatedAt.IsZero() {
+					file.CreatedAt = now
+				}
+				file.UpdatedAt = now
+
+				// Create a copy of the file
+				fileCopy := domain.File{
+					ID:        file.ID,
+					RecordID:  file.RecordID,
+					Name:      file.Name,
+					URL:       file.URL,
+					Type:      file.Type,
+					Size:      file.Size,
+					Data:      file.Data,
+					SHA256:    file.SHA256,
+					Width:     file.Width,
+					Height:    file.Height,
+					CreatedAt: file.CreatedAt,
+					UpdatedAt: file.UpdatedAt,
+				}
+
+				if err := tx.Create(&fileCopy).Error; err != nil {
+					return err
+				}
+
+				// Count the reference to the shared blob
+				if file.SHA256 != "" {
+					if err := retainBlob(tx, file.SHA256, file.Size); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
 }
 
 func (r *SessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Session, error) {
 	var session domain.Session
-	if err := r.db.WithContext(ctx).Preload("Records.Files").First(&session, "id = ?", id).Error; err != nil {
+	if err := conn(ctx, r.db).Preload("Records.Files").First(&session, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &session, nil
@@ -162,89 +168,368 @@ func (r *SessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.
 
 func (r *SessionRepository) GetByProjectID(ctx context.Context, projectID uuid.UUID) ([]domain.Session, error) {
 	var sessions []domain.Session
-	if err := r.db.WithContext(ctx).Preload("Records.Files").Where("project_id = ?", projectID).Order("start_time desc").Find(&sessions).Error; err != nil {
+	if err := conn(ctx, r.db).Preload("Records.Files").Where("project_id = ?", projectID).Order("start_time desc").Find(&sessions).Error; err != nil {
 		return nil, err
 	}
 	return sessions, nil
 }
 
-func (r *SessionRepository) Update(ctx context.Context, session *domain.Session) error {
-	// Start a transaction
-	tx := r.db.WithContext(ctx).Begin()
-	if tx.Error != nil {
-		return tx.Error
+// GetByUserID returns the sessions in all of a user's projects that overlap
+// from-to, without their records
+func (r *SessionRepository) GetByUserID(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := conn(ctx, r.db).
+		Joins("JOIN projects ON projects.id = sessions.project_id").
+		Where("projects.user_id = ? AND sessions.start_time < ? AND sessions.end_time >= ?", userID, to, from).
+		Order("sessions.start_time ASC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// FindOverlapping returns the user's sessions that overlap start-end.
+// Sessions that only touch it at a boundary are not included.
+func (r *SessionRepository) FindOverlapping(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := conn(ctx, r.db).
+		Joins("JOIN projects ON projects.id = sessions.project_id").
+		Where("projects.user_id = ? AND sessions.start_time < ? AND sessions.end_time > ?", userID, end, start).
+		Order("sessions.start_time ASC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// FindImported returns the user's sessions that were imported from events
+// with any of the UIDs, or that have any of the IDs. Sessions in the trash
+// count, so importing again does not duplicate what can still be restored.
+func (r *SessionRepository) FindImported(ctx context.Context, userID uuid.UUID, uids []string, ids []uuid.UUID) ([]domain.Session, error) {
+	var sessions []domain.Session
+	if len(uids) == 0 && len(ids) == 0 {
+		return sessions, nil
+	}
+	query := conn(ctx, r.db).Unscoped().
+		Joins("JOIN projects ON projects.id = sessions.project_id").
+		Where("projects.user_id = ?", userID)
+	switch {
+	case len(ids) == 0:
+		query = query.Where("sessions.ical_uid IN ?", uids)
+	case len(uids) == 0:
+		query = query.Where("sessions.id IN ?", ids)
+	default:
+		query = query.Where("sessions.ical_uid IN ? OR sessions.id IN ?", uids, ids)
 	}
+	err := query.Find(&sessions).Error
+	return sessions, err
+}
 
-	// Update the session
-	if err := tx.Save(session).Error; err != nil {
-		tx.Rollback()
+// GetCalendarEvents calls fn for each of the user's sessions starting from
+// from on, in start order. Rows are read from a cursor so long feeds are not
+// loaded into memory.
+func (r *SessionRepository) GetCalendarEvents(ctx context.Context, userID uuid.UUID, from time.Time, fn func(event *domain.CalendarEvent) error) error {
+	db := conn(ctx, r.db)
+	rows, err := db.Model(&domain.Session{}).
+		Select("sessions.id AS session_id, sessions.ical_uid, projects.name AS project_name, "+
+			"sessions.start_time, sessions.end_time, sessions.tags, sessions.updated_at, "+
+			"COALESCE((SELECT string_agg(records.text, E'\\n\\n' ORDER BY records.timestamp) FROM records "+
+			"WHERE records.session_id = sessions.id AND records.deleted_at IS NULL AND records.text <> ''), '') AS notes").
+		Joins("JOIN projects ON projects.id = sessions.project_id AND projects.deleted_at IS NULL").
+		Where("projects.user_id = ? AND sessions.start_time >= ?", userID, from).
+		Order("sessions.start_time ASC").
+		Rows()
+	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	// Update or create associated records
-	for i := range session.Records {
-		record := &session.Records[i]
-		if record.ID == uuid.Nil {
-			record.ID = uuid.New()
+	for rows.Next() {
+		var event domain.CalendarEvent
+		if err := db.ScanRows(rows, &event); err != nil {
+			return err
 		}
-		record.SessionID = session.ID
-		if err := tx.Save(record).Error; err != nil {
-			tx.Rollback()
+		if err := fn(&event); err != nil {
 			return err
 		}
+	}
+	return rows.Err()
+}
 
-		// Update or create associated files
-		for j := range record.Files {
-			file := &record.Files[j]
-			if file.ID == uuid.Nil {
-				file.ID = uuid.New()
+// ApplyOverlapAdjustments changes session times as planned by
+// domain.PlanOverlapResolution. Split sessions get a new session after the
+// target, which takes over the records from its start on. Records left
+// outside a session's new times were made during the target, so they move
+// to it and are listed in the adjustment's MovedRecordIDs.
+func (r *SessionRepository) ApplyOverlapAdjustments(ctx context.Context, targetID uuid.UUID, adjustments []domain.OverlapAdjustment) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for i := range adjustments {
+			adj := &adjustments[i]
+			if adj.Action == domain.AdjustUnresolved {
+				continue
+			}
+
+			if adj.Action == domain.AdjustSplit {
+				var original domain.Session
+				if err := tx.First(&original, "id = ?", adj.SessionID).Error; err != nil {
+					return err
+				}
+				part := domain.Session{
+					ID:        *adj.NewSessionID,
+					ProjectID: original.ProjectID,
+					StartTime: *adj.NewStartTime,
+					EndTime:   *adj.NewEndTime,
+					Duration:  *adj.NewDuration,
+					Manual:    original.Manual,
+					Tags:      original.Tags,
+					ICalUID:   domain.SplitPartUID(original.ICalUID, *adj.NewSessionID),
+					CreatedAt: now,
+					UpdatedAt: now,
+				}
+				if err := tx.Create(&part).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&domain.Record{}).
+					Where("session_id = ? AND timestamp >= ?", adj.SessionID, part.StartTime).
+					Update("session_id", part.ID).Error; err != nil {
+					return err
+				}
 			}
-			file.RecordID = record.ID
-			if err := tx.Save(file).Error; err != nil {
-				tx.Rollback()
+
+			if err := tx.Model(&domain.Session{}).Where("id = ?", adj.SessionID).Updates(map[string]interface{}{
+				"start_time": adj.StartTime,
+				"end_time":   adj.EndTime,
+				"duration":   adj.Duration,
+				"updated_at": now,
+			}).Error; err != nil {
 				return err
 			}
+
+			outside := tx.Model(&domain.Record{}).
+				Where("session_id = ? AND (timestamp < ? OR timestamp > ?)", adj.SessionID, adj.StartTime, adj.EndTime)
+			if err := outside.Pluck("id", &adj.MovedRecordIDs).Error; err != nil {
+				return err
+			}
+			if len(adj.MovedRecordIDs) > 0 {
+				if err := tx.Model(&domain.Record{}).Where("id IN ?", adj.MovedRecordIDs).
+					Update("session_id", targetID).Error; err != nil {
+					return err
+				}
+			}
 		}
-	}
+		return nil
+	})
+}
 
-	// Commit the transaction
-	return tx.Commit().Error
+// CreateMany creates sessions with plain text records, such as manual
+// timesheet entries, in one transaction. Sessions imported from a calendar
+// event the project already has, say by a concurrent import, are skipped
+// and their indexes returned.
+func (r *SessionRepository) CreateMany(ctx context.Context, sessions []domain.Session) ([]int, error) {
+	var skipped []int
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		for i := range sessions {
+			result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "project_id"}, {Name: "ical_uid"}},
+				DoNothing: true,
+			}).Create(&sessions[i])
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				skipped = append(skipped, i)
+				continue
+			}
+			for j := range sessions[i].Records {
+				if err := tx.Omit(clause.Associations).Create(&sessions[i].Records[j]).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return skipped, err
 }
 
-func (r *SessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	// Start a transaction
-	tx := r.db.WithContext(ctx).Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
+// Split ends a session at the given time and creates a session for the rest
+// of it, which takes over the records from at on. The duration is divided
+// in proportion to the two intervals. The new session is returned.
+func (r *SessionRepository) Split(ctx context.Context, id uuid.UUID, at time.Time) (*domain.Session, error) {
+	var part domain.Session
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var session domain.Session
+		if err := tx.First(&session, "id = ?", id).Error; err != nil {
+			return err
+		}
 
-	// Get the session with its relationships
-	var session domain.Session
-	if err := tx.Preload("Records.Files").First(&session, "id = ?", id).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
+		interval := session.EndTime.Sub(session.StartTime)
+		before := session.Duration
+		if interval > 0 {
+			before = int64(float64(session.Duration) * float64(at.Sub(session.StartTime)) / float64(interval))
+		}
+
+		now := time.Now()
+		part = domain.Session{
+			ID:        uuid.New(),
+			ProjectID: session.ProjectID,
+			StartTime: at,
+			EndTime:   session.EndTime,
+			Duration:  session.Duration - before,
+			Manual:    session.Manual,
+			Tags:      session.Tags,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		part.ICalUID = domain.SplitPartUID(session.ICalUID, part.ID)
+		if err := tx.Create(&part).Error; err != nil {
+			return err
+		}
 
-	// Delete files first
-	for _, record := range session.Records {
-		if err := tx.Where("record_id = ?", record.ID).Delete(&domain.File{}).Error; err != nil {
-			tx.Rollback()
+		if err := tx.Model(&domain.Record{}).
+			Where("session_id = ? AND timestamp >= ?", id, at).
+			Update("session_id", part.ID).Error; err != nil {
 			return err
 		}
-	}
 
-	// Delete records
-	if err := tx.Where("session_id = ?", id).Delete(&domain.Record{}).Error; err != nil {
-		tx.Rollback()
-		return err
+		return tx.Model(&domain.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"end_time":   at,
+			"duration":   before,
+			"updated_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &part, nil
+}
 
-	// Delete session
-	if err := tx.Delete(&session).Error; err != nil {
-		tx.Rollback()
-		return err
+// Merge combines sessions of one project into the earliest of them, which
+// spans all of them and gets the sum of their durations. No other session
+// of the project may lie between them. The merged session is returned.
+func (r *SessionRepository) Merge(ctx context.Context, ids []uuid.UUID) (*domain.Session, error) {
+	var merged domain.Session
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var sessions []domain.Session
+		if err := tx.Where("id IN ?", ids).Order("start_time ASC").Find(&sessions).Error; err != nil {
+			return err
+		}
+		if len(sessions) != len(ids) {
+			return gorm.ErrRecordNotFound
+		}
+
+		merged = sessions[0]
+		others := make([]uuid.UUID, 0, len(sessions)-1)
+		for _, session := range sessions[1:] {
+			if session.ProjectID != merged.ProjectID {
+				return repository.ErrSessionsNotAdjacent
+			}
+			if session.EndTime.After(merged.EndTime) {
+				merged.EndTime = session.EndTime
+			}
+			merged.Duration += session.Duration
+			for _, tag := range session.Tags {
+				if !slices.Contains(merged.Tags, tag) {
+					merged.Tags = append(merged.Tags, tag)
+				}
+			}
+			others = append(others, session.ID)
+		}
+
+		var between int64
+		if err := tx.Model(&domain.Session{}).
+			Where("project_id = ? AND id NOT IN ?", merged.ProjectID, ids).
+			Where("start_time < ? AND end_time > ?", merged.EndTime, merged.StartTime).
+			Count(&between).Error; err != nil {
+			return err
+		}
+		if between > 0 {
+			return repository.ErrSessionsNotAdjacent
+		}
+
+		if err := tx.Model(&domain.Record{}).Where("session_id IN ?", others).
+			Update("session_id", merged.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.WorkLog{}).Where("session_id IN ?", others).
+			Update("session_id", merged.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("id IN ?", others).Delete(&domain.Session{}).Error; err != nil {
+			return err
+		}
+
+		merged.UpdatedAt = time.Now()
+		return tx.Model(&domain.Session{}).Where("id = ?", merged.ID).Updates(map[string]interface{}{
+			"end_time":   merged.EndTime,
+			"duration":   merged.Duration,
+			"tags":       merged.Tags,
+			"updated_at": merged.UpdatedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &merged, nil
+}
+
+// Move assigns a session, and with it its records and files, to another
+// project
+func (r *SessionRepository) Move(ctx context.Context, id, projectID uuid.UUID) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"project_id": projectID,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		// Work logs converted into the session follow it
+		return tx.Model(&domain.WorkLog{}).Where("session_id = ?", id).
+			Update("project_id", projectID).Error
+	})
+}
+
+func (r *SessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// Update the session
+		if err := tx.Save(session).Error; err != nil {
+			return err
+		}
+
+		// Update or create associated records
+		for i := range session.Records {
+			record := &session.Records[i]
+			if record.ID == uuid.Nil {
+				record.ID = uuid.New()
+			}
+			record.SessionID = session.ID
+			if err := tx.Save(record).Error; err != nil {
+				return err
+			}
+
+			// Update or create associated files
+			for j := range record.Files {
+				file := &record.Files[j]
+				if file.ID == uuid.Nil {
+					file.ID = uuid.New()
+				}
+				file.RecordID = record.ID
+				if err := tx.Save(file).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Delete moves a session with its records and files to the trash. Blob
+// references are released when the trash is purged.
+func (r *SessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return trashSessionsWhere(tx, trashTime(), "id = ?", id)
+	})
+}
 
-	// Commit the transaction
-	return tx.Commit().Error
+// DeleteRecord moves a record with its files to the trash
+func (r *SessionRepository) DeleteRecord(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		return trashRecordsWhere(tx, trashTime(), "id = ?", id)
+	})
 }
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+)
+
+func TestSessionWritesJoinTransaction(t *testing.T) {
+	db, fake := newFakeDB(t)
+	sessions := NewSessionRepository(db)
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	session := &domain.Session{
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		Records: []domain.Record{{
+			Text:  "notes",
+			Files: []domain.File{{Name: "a.png", SHA256: "abc", Size: 3}},
+		}},
+	}
+	err := NewTransactor(db).WithinTransaction(context.Background(), func(ctx context.Context) error {
+		if err := sessions.Create(ctx, uuid.New(), session); err != nil {
+			return err
+		}
+		session.Duration = 1800
+		return sessions.Update(ctx, session)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements := fake.Statements()
+	var begins, savepoints, commits int
+	for _, stmt := range statements {
+		switch {
+		case stmt == "BEGIN":
+			begins++
+		case strings.HasPrefix(stmt, "SAVEPOINT"):
+			savepoints++
+		case stmt == "COMMIT":
+			commits++
+		}
+	}
+	if begins != 1 || commits != 1 || savepoints != 2 {
+		t.Fatalf("got %d transactions, %d commits and %d savepoints, want 1, 1 and 2:\n%s",
+			begins, commits, savepoints, strings.Join(statements, "\n"))
+	}
+	if session.Duration != 1800 || fake.index(`INSERT INTO "sessions"`) < 0 || fake.index(`UPDATE "sessions"`) < 0 {
+		t.Fatalf("session not written:\n%s", strings.Join(statements, "\n"))
+	}
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StorageUsageRepository struct {
+	db *gorm.DB
+}
+
+func NewStorageUsageRepository(db *gorm.DB) *StorageUsageRepository {
+	return &StorageUsageRepository{db: db}
+}
+
+// GetByUserID returns the usage of a user, which is zero for users that
+// never uploaded anything
+func (r *StorageUsageRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*domain.StorageUsage, error) {
+	usage := domain.StorageUsage{UserID: userID}
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Limit(1).Find(&usage).Error; err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// Add adjusts the usage of a user by the given deltas, which may be negative
+func (r *StorageUsageRepository) Add(ctx context.Context, userID uuid.UUID, fileBytes, audioBytes int64) error {
+	usage := domain.StorageUsage{
+		UserID:     userID,
+		FileBytes:  max(fileBytes, 0),
+		AudioBytes: max(audioBytes, 0),
+		UpdatedAt:  time.Now(),
+	}
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"file_bytes":  gorm.Expr("GREATEST(storage_usages.file_bytes + ?, 0)", fileBytes),
+			"audio_bytes": gorm.Expr("GREATEST(storage_usages.audio_bytes + ?, 0)", audioBytes),
+			"updated_at":  usage.UpdatedAt,
+		}),
+	}).Create(&usage).Error
+}
+
+// Reserve adds the bytes to the user's usage unless the total would exceed
+// quota and reports whether it did. The check and the update are one
+// statement, so concurrent uploads cannot both fit into the last space.
+func (r *StorageUsageRepository) Reserve(ctx context.Context, userID uuid.UUID, fileBytes, audioBytes, quota int64) (bool, error) {
+	if fileBytes+audioBytes > quota {
+		return false, nil
+	}
+	usage := domain.StorageUsage{
+		UserID:     userID,
+		FileBytes:  fileBytes,
+		AudioBytes: audioBytes,
+		UpdatedAt:  time.Now(),
+	}
+	result := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"file_bytes":  gorm.Expr("storage_usages.file_bytes + ?", fileBytes),
+			"audio_bytes": gorm.Expr("storage_usages.audio_bytes + ?", audioBytes),
+			"updated_at":  usage.UpdatedAt,
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			gorm.Expr("storage_usages.file_bytes + storage_usages.audio_bytes + ? <= ?", fileBytes+audioBytes, quota),
+		}},
+	}).Create(&usage)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Recompute rebuilds the usage of every user from the stored files and
+// audio, correcting any drift in the running totals
+func (r *StorageUsageRepository) Recompute(ctx context.Context) ([]domain.StorageUsage, error) {
+	var usages []domain.StorageUsage
+
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(`
+			SELECT projects.user_id AS user_id,
+				COALESCE(SUM(file_totals.bytes), 0) AS file_bytes,
+				COALESCE(SUM(octet_length(records.audio_data)), 0) AS audio_bytes,
+				NOW() AS updated_at
+			FROM projects
+			JOIN sessions ON sessions.project_id = projects.id
+			JOIN records ON records.session_id = sessions.id
+			LEFT JOIN (
+				SELECT record_id,
+					SUM(CASE WHEN data IS NOT NULL THEN octet_length(data) ELSE size END) AS bytes
+				FROM files
+				GROUP BY record_id
+			) AS file_totals ON file_totals.record_id = records.id
+			GROUP BY projects.user_id`).Scan(&usages).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("1 = 1").Delete(&domain.StorageUsage{}).Error; err != nil {
+			return err
+		}
+		if len(usages) == 0 {
+			return nil
+		}
+		return tx.Create(&usages).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
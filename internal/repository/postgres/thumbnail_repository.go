@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ThumbnailRepository struct {
+	db *gorm.DB
+}
+
+func NewThumbnailRepository(db *gorm.DB) *ThumbnailRepository {
+	return &ThumbnailRepository{db: db}
+}
+
+// Save inserts a thumbnail or replaces the existing one of the same size
+func (r *ThumbnailRepository) Save(ctx context.Context, thumbnail *domain.Thumbnail) error {
+	if thumbnail.ID == uuid.Nil {
+		thumbnail.ID = uuid.New()
+	}
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}, {Name: "size"}},
+		DoUpdates: clause.AssignmentColumns([]string{"width", "height", "content_type", "storage_key", "created_at"}),
+	}).Create(thumbnail).Error
+}
+
+func (r *ThumbnailRepository) GetByFileID(ctx context.Context, fileID uuid.UUID) ([]domain.Thumbnail, error) {
+	var thumbnails []domain.Thumbnail
+	if err := conn(ctx, r.db).Where("file_id = ?", fileID).Find(&thumbnails).Error; err != nil {
+		return nil, err
+	}
+	return thumbnails, nil
+}
+
+func (r *ThumbnailRepository) GetByFileIDAndSize(ctx context.Context, fileID uuid.UUID, size string) (*domain.Thumbnail, error) {
+	var thumbnail domain.Thumbnail
+	if err := conn(ctx, r.db).First(&thumbnail, "file_id = ? AND size = ?", fileID, size).Error; err != nil {
+		return nil, err
+	}
+	return &thumbnail, nil
+}
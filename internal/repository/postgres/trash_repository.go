@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// trashTime returns the deletion time stamped on an item and the children
+// deleted with it. Postgres keeps microseconds, so truncating lets restore
+// match children to their parent exactly.
+func trashTime() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// trashFilesWhere moves the matching files to the trash
+func trashFilesWhere(tx *gorm.DB, at time.Time, query string, args ...interface{}) error {
+	return tx.Model(&domain.File{}).Where(query, args...).Update("deleted_at", at).Error
+}
+
+// trashRecordsWhere moves the matching records and their files to the trash
+func trashRecordsWhere(tx *gorm.DB, at time.Time, query string, args ...interface{}) error {
+	records := tx.Model(&domain.Record{}).Select("id").Where(query, args...)
+	if err := trashFilesWhere(tx, at, "record_id IN (?)", records); err != nil {
+		return err
+	}
+	return tx.Model(&domain.Record{}).Where(query, args...).Update("deleted_at", at).Error
+}
+
+// trashSessionsWhere moves the matching sessions, their records and files
+// to the trash
+func trashSessionsWhere(tx *gorm.DB, at time.Time, query string, args ...interface{}) error {
+	sessions := tx.Model(&domain.Session{}).Select("id").Where(query, args...)
+	if err := trashRecordsWhere(tx, at, "session_id IN (?)", sessions); err != nil {
+		return err
+	}
+	return tx.Model(&domain.Session{}).Where(query, args...).Update("deleted_at", at).Error
+}
+
+// TrashRepository lists and restores trashed projects, sessions and records
+type TrashRepository struct {
+	db *gorm.DB
+}
+
+func NewTrashRepository(db *gorm.DB) *TrashRepository {
+	return &TrashRepository{db: db}
+}
+
+// trashRow is one row of the trash queries below
+type trashRow struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	ParentID      *uuid.UUID
+	Label         string
+	StartTime     *time.Time
+	DeletedAt     time.Time
+	ParentDeleted bool
+}
+
+// trashQueries select the items of each kind deleted on their own, that is
+// not at the same moment as their parent
+var trashQueries = map[string]string{
+	domain.TrashProject: `
+		SELECT projects.id, projects.user_id, NULL AS parent_id, projects.name AS label,
+			NULL AS start_time, projects.deleted_at, FALSE AS parent_deleted
+		FROM projects
+		WHERE projects.deleted_at IS NOT NULL`,
+	domain.TrashSession: `
+		SELECT sessions.id, projects.user_id, sessions.project_id AS parent_id, '' AS label,
+			sessions.start_time, sessions.deleted_at, projects.deleted_at IS NOT NULL AS parent_deleted
+		FROM sessions
+		JOIN projects ON projects.id = sessions.project_id
+		WHERE sessions.deleted_at IS NOT NULL
+		AND (projects.deleted_at IS NULL OR projects.deleted_at <> sessions.deleted_at)`,
+	domain.TrashRecord: `
+		SELECT records.id, projects.user_id, records.session_id AS parent_id, records.text AS label,
+			NULL AS start_time, records.deleted_at, sessions.deleted_at IS NOT NULL AS parent_deleted
+		FROM records
+		JOIN sessions ON sessions.id = records.session_id
+		JOIN projects ON projects.id = sessions.project_id
+		WHERE records.deleted_at IS NOT NULL
+		AND (sessions.deleted_at IS NULL OR sessions.deleted_at <> records.deleted_at)`,
+}
+
+func (r *TrashRepository) query(ctx context.Context, kind, condition string, args ...interface{}) ([]domain.TrashItem, error) {
+	base, ok := trashQueries[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown trash kind: %s", kind)
+	}
+
+	var rows []trashRow
+	if err := conn(ctx, r.db).Raw(base+" AND "+condition+" ORDER BY deleted_at DESC", args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]domain.TrashItem, len(rows))
+	for i, row := range rows {
+		label := row.Label
+		if row.StartTime != nil {
+			label = row.StartTime.UTC().Format(time.RFC3339)
+		}
+		if runes := []rune(label); len(runes) > 100 {
+			label = string(runes[:100]) + "…"
+		}
+		items[i] = domain.TrashItem{
+			Kind:          kind,
+			ID:            row.ID,
+			UserID:        row.UserID,
+			ParentID:      row.ParentID,
+			Label:         label,
+			DeletedAt:     row.DeletedAt,
+			ParentDeleted: row.ParentDeleted,
+		}
+	}
+	return items, nil
+}
+
+// List returns everything the user deleted, newest first within each kind
+func (r *TrashRepository) List(ctx context.Context, userID uuid.UUID) ([]domain.TrashItem, error) {
+	var items []domain.TrashItem
+	for _, kind := range []string{domain.TrashProject, domain.TrashSession, domain.TrashRecord} {
+		kindItems, err := r.query(ctx, kind, "projects.user_id = ?", userID)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, kindItems...)
+	}
+	return items, nil
+}
+
+// Get returns a trashed item, or nil if it is not in the trash on its own
+func (r *TrashRepository) Get(ctx context.Context, kind string, id uuid.UUID) (*domain.TrashItem, error) {
+	items, err := r.query(ctx, kind, kind+".id = ?", id)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// Restore takes an item and the children deleted together with it out of
+// the trash. Children deleted earlier on their own stay in the trash.
+func (r *TrashRepository) Restore(ctx context.Context, item *domain.TrashItem) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		at := item.DeletedAt
+		restore := func(model interface{}, query string, args ...interface{}) error {
+			return tx.Unscoped().Model(model).Where(query, args...).
+				Where("deleted_at = ?", at).Update("deleted_at", nil).Error
+		}
+
+		var sessions, records *gorm.DB
+		switch item.Kind {
+		case domain.TrashProject:
+			if err := restore(&domain.Project{}, "id = ?", item.ID); err != nil {
+				return err
+			}
+			sessions = tx.Unscoped().Model(&domain.Session{}).Select("id").Where("project_id = ?", item.ID)
+			if err := restore(&domain.Session{}, "project_id = ?", item.ID); err != nil {
+				return err
+			}
+			records = tx.Unscoped().Model(&domain.Record{}).Select("id").Where("session_id IN (?)", sessions)
+			if err := restore(&domain.Record{}, "session_id IN (?)", sessions); err != nil {
+				return err
+			}
+		case domain.TrashSession:
+			if err := restore(&domain.Session{}, "id = ?", item.ID); err != nil {
+				return err
+			}
+			records = tx.Unscoped().Model(&domain.Record{}).Select("id").Where("session_id = ?", item.ID)
+			if err := restore(&domain.Record{}, "session_id = ?", item.ID); err != nil {
+				return err
+			}
+		case domain.TrashRecord:
+			if err := restore(&domain.Record{}, "id = ?", item.ID); err != nil {
+				return err
+			}
+			return restore(&domain.File{}, "record_id = ?", item.ID)
+		default:
+			return fmt.Errorf("unknown trash kind: %s", item.Kind)
+		}
+		return restore(&domain.File{}, "record_id IN (?)", records)
+	})
+}
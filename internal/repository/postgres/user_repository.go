@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 
 	"github.com/ZigaoWang/zebra-server/internal/domain"
 	"github.com/google/uuid"
@@ -17,12 +18,12 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 }
 
 func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
+	return conn(ctx, r.db).Create(user).Error
 }
 
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
 	var user domain.User
-	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+	if err := conn(ctx, r.db).First(&user, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -30,16 +31,64 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Use
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user domain.User
-	if err := r.db.WithContext(ctx).Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
+	if err := conn(ctx, r.db).Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// GetByCalendarToken returns nil when no user has the token
+func (r *UserRepository) GetByCalendarToken(ctx context.Context, token string) (*domain.User, error) {
+	var user domain.User
+	if err := conn(ctx, r.db).Where("calendar_token = ?", token).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByUnsubscribeToken returns nil when no user has the token
+func (r *UserRepository) GetByUnsubscribeToken(ctx context.Context, token string) (*domain.User, error) {
+	var user domain.User
+	if err := conn(ctx, r.db).Where("unsubscribe_token = ?", token).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetDigestSubscribers returns the users who want the weekly digest
+func (r *UserRepository) GetDigestSubscribers(ctx context.Context) ([]domain.User, error) {
+	var users []domain.User
+	err := conn(ctx, r.db).Where("digest_enabled").Order("id").Find(&users).Error
+	return users, err
+}
+
+// ClaimDigestWeek changes the week of the user's last digest from previous
+// to week and reports whether it did, so that concurrent schedulers send
+// each digest once
+func (r *UserRepository) ClaimDigestWeek(ctx context.Context, userID uuid.UUID, previous, week string) (bool, error) {
+	result := conn(ctx, r.db).Model(&domain.User{}).
+		Where("id = ? AND digest_sent_week = ?", userID, previous).
+		Update("digest_sent_week", week)
+	return result.RowsAffected > 0, result.Error
+}
+
+// SetUnsubscribeToken stores the user's unsubscribe token without touching
+// the other columns
+func (r *UserRepository) SetUnsubscribeToken(ctx context.Context, userID uuid.UUID, token string) error {
+	return conn(ctx, r.db).Model(&domain.User{}).Where("id = ?", userID).
+		UpdateColumn("unsubscribe_token", token).Error
+}
+
 func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
-	return r.db.WithContext(ctx).Save(user).Error
+	return conn(ctx, r.db).Save(user).Error
 }
 
 func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&domain.User{}, "id = ?", id).Error
+	return conn(ctx, r.db).Delete(&domain.User{}, "id = ?", id).Error
 }
@@ -2,10 +2,14 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WorkLogRepository struct {
@@ -17,38 +21,141 @@ func NewWorkLogRepository(db *gorm.DB) *WorkLogRepository {
 }
 
 func (r *WorkLogRepository) Create(ctx context.Context, log *domain.WorkLog) error {
-	return r.db.WithContext(ctx).Create(log).Error
+	return conn(ctx, r.db).Create(log).Error
 }
 
+// GetByID returns nil when the work log does not exist
 func (r *WorkLogRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.WorkLog, error) {
 	var workLog domain.WorkLog
-	if err := r.db.WithContext(ctx).First(&workLog, "id = ?", id).Error; err != nil {
+	if err := conn(ctx, r.db).Preload("Pauses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("start_time ASC")
+	}).First(&workLog, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return &workLog, nil
 }
 
-func (r *WorkLogRepository) GetByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.WorkLog, error) {
-	var workLogs []domain.WorkLog
-	query := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC")
-	
-	if limit > 0 {
-		query = query.Limit(limit)
+// GetByUserID returns one page of a user's work logs, newest first, and the
+// total number of logs matching the filter
+func (r *WorkLogRepository) GetByUserID(ctx context.Context, userID uuid.UUID, filter repository.WorkLogFilter) ([]domain.WorkLog, int64, error) {
+	query := conn(ctx, r.db).Model(&domain.WorkLog{}).Where("user_id = ?", userID)
+	if len(filter.Tags) > 0 {
+		tags, err := domain.StringArray(filter.Tags).Value()
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where("tags @> ?::text[]", tags)
+	}
+
+	if !filter.From.IsZero() {
+		query = query.Where("(status <> ? OR end_time >= ?)", domain.WorkLogCompleted, filter.From)
 	}
-	if offset > 0 {
-		query = query.Offset(offset)
+	if !filter.To.IsZero() {
+		query = query.Where("start_time < ?", filter.To)
+	}
+	if filter.Unconverted {
+		query = query.Where("session_id IS NULL")
 	}
 
-	if err := query.Find(&workLogs).Error; err != nil {
-		return nil, err
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
 	}
-	return workLogs, nil
+
+	query = query.Order("created_at DESC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var workLogs []domain.WorkLog
+	if err := query.Preload("Pauses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("start_time ASC")
+	}).Find(&workLogs).Error; err != nil {
+		return nil, 0, err
+	}
+	return workLogs, total, nil
 }
 
+// Update saves the work log's own fields; pauses only change through
+// Transition
 func (r *WorkLogRepository) Update(ctx context.Context, log *domain.WorkLog) error {
-	return r.db.WithContext(ctx).Save(log).Error
+	return conn(ctx, r.db).Omit(clause.Associations).Save(log).Error
+}
+
+// Transition saves a status change made with domain.WorkLog.Transition,
+// its pauses and the status entry recording it in one transaction
+func (r *WorkLogRepository) Transition(ctx context.Context, log *domain.WorkLog, entry *domain.LogEntry) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(log).Error; err != nil {
+			return err
+		}
+		for i := range log.Pauses {
+			if err := tx.Save(&log.Pauses[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(entry).Error
+	})
+}
+
+// GetConvertible returns every completed work log that belongs to a project
+// and has not been converted into a session yet
+func (r *WorkLogRepository) GetConvertible(ctx context.Context) ([]domain.WorkLog, error) {
+	var workLogs []domain.WorkLog
+	err := conn(ctx, r.db).Preload("Pauses").
+		Where("status = ? AND project_id IS NOT NULL AND session_id IS NULL", domain.WorkLogCompleted).
+		Order("start_time ASC").
+		Find(&workLogs).Error
+	return workLogs, err
+}
+
+// ConvertToSession creates the session and records built by
+// domain.WorkLog.ToSession and links the work log to it
+func (r *WorkLogRepository) ConvertToSession(ctx context.Context, log *domain.WorkLog, session *domain.Session) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(session).Error; err != nil {
+			return err
+		}
+		for i := range session.Records {
+			if err := tx.Omit(clause.Associations).Create(&session.Records[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		// Guard against converting the same log twice concurrently
+		result := tx.Model(&domain.WorkLog{}).
+			Where("id = ? AND session_id IS NULL", log.ID).
+			Updates(map[string]interface{}{
+				"project_id": session.ProjectID,
+				"session_id": session.ID,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return repository.ErrAlreadyConverted
+		}
+		log.SessionID = &session.ID
+		return nil
+	})
 }
 
+// Delete removes a work log together with its pauses and entries
 func (r *WorkLogRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&domain.WorkLog{}, "id = ?", id).Error
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&domain.LogEntry{}, "work_log_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&domain.WorkLogPause{}, "work_log_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.WorkLog{}, "id = ?", id).Error
+	})
 }
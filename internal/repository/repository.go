@@ -2,31 +2,78 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/ZigaoWang/zebra-server/internal/domain"
 	"github.com/google/uuid"
 )
 
+// Transactor runs functions in one transaction, which repositories called
+// with the function's context take part in
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetByCalendarToken(ctx context.Context, token string) (*domain.User, error)
+	GetByUnsubscribeToken(ctx context.Context, token string) (*domain.User, error)
+	GetDigestSubscribers(ctx context.Context) ([]domain.User, error)
+	ClaimDigestWeek(ctx context.Context, userID uuid.UUID, previous, week string) (bool, error)
+	SetUnsubscribeToken(ctx context.Context, userID uuid.UUID, token string) error
 	Update(ctx context.Context, user *domain.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+// ErrAlreadyConverted is returned when a work log already has a session
+var ErrAlreadyConverted = errors.New("work log already converted")
+
+// ErrSessionsNotAdjacent is returned when merging sessions of different
+// projects or with another session of the project between them
+var ErrSessionsNotAdjacent = errors.New("sessions are not adjacent within one project")
+
+// WorkLogFilter narrows a work log listing. Logs must carry every tag in
+// Tags and overlap From-To when those are set; a zero Limit returns all
+// matches.
+type WorkLogFilter struct {
+	Tags        []string
+	From        time.Time
+	To          time.Time
+	Unconverted bool // only logs not yet converted into sessions
+	Limit       int
+	Offset      int
+}
+
+// ReportFilter selects the sessions of a user covered by a report: those
+// starting in From-To, in any of ProjectIDs and carrying every tag in Tags
+// when those are set
+type ReportFilter struct {
+	UserID     uuid.UUID
+	From       time.Time
+	To         time.Time
+	Timezone   string // IANA name used to split days, weeks and months
+	ProjectIDs []uuid.UUID
+	Tags       []string
+}
+
 type WorkLogRepository interface {
 	Create(ctx context.Context, log *domain.WorkLog) error
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.WorkLog, error)
-	GetByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.WorkLog, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, filter WorkLogFilter) ([]domain.WorkLog, int64, error)
 	Update(ctx context.Context, log *domain.WorkLog) error
+	Transition(ctx context.Context, log *domain.WorkLog, entry *domain.LogEntry) error
+	GetConvertible(ctx context.Context) ([]domain.WorkLog, error)
+	ConvertToSession(ctx context.Context, log *domain.WorkLog, session *domain.Session) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 type LogEntryRepository interface {
 	Create(ctx context.Context, entry *domain.LogEntry) error
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.LogEntry, error)
-	GetByWorkLogID(ctx context.Context, workLogID uuid.UUID) ([]domain.LogEntry, error)
+	GetByWorkLogID(ctx context.Context, workLogID uuid.UUID, entryType string, limit, offset int) ([]domain.LogEntry, int64, error)
 	Update(ctx context.Context, entry *domain.LogEntry) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -41,10 +88,141 @@ type ProjectRepository interface {
 
 type SessionRepository interface {
 	Create(ctx context.Context, projectID uuid.UUID, session *domain.Session) error
+	CreateMany(ctx context.Context, sessions []domain.Session) ([]int, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.Session, error)
 	GetByProjectID(ctx context.Context, projectID uuid.UUID) ([]domain.Session, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]domain.Session, error)
+	FindOverlapping(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]domain.Session, error)
+	FindImported(ctx context.Context, userID uuid.UUID, uids []string, ids []uuid.UUID) ([]domain.Session, error)
+	GetCalendarEvents(ctx context.Context, userID uuid.UUID, from time.Time, fn func(event *domain.CalendarEvent) error) error
+	ApplyOverlapAdjustments(ctx context.Context, targetID uuid.UUID, adjustments []domain.OverlapAdjustment) error
+	Split(ctx context.Context, id uuid.UUID, at time.Time) (*domain.Session, error)
+	Merge(ctx context.Context, ids []uuid.UUID) (*domain.Session, error)
+	Move(ctx context.Context, id, projectID uuid.UUID) error
 	Update(ctx context.Context, session *domain.Session) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetFileByID(ctx context.Context, id uuid.UUID) (*domain.File, error)
 	GetRecordByID(ctx context.Context, id uuid.UUID) (*domain.Record, error)
+	UpdateRecord(ctx context.Context, record *domain.Record) error
+	DeleteRecord(ctx context.Context, id uuid.UUID) error
+	UpdateFileDimensions(ctx context.Context, id uuid.UUID, width, height int) error
+	UpdateRecordAudio(ctx context.Context, record *domain.Record) error
+	UpdateRecordTranscript(ctx context.Context, record *domain.Record) error
+	GetPendingTranscripts(ctx context.Context, staleBefore time.Time) ([]uuid.UUID, error)
+}
+
+type BlobRepository interface {
+	GetOwnedBySHA256(ctx context.Context, userID uuid.UUID, sha256 string) (*domain.Blob, error)
+	Claim(ctx context.Context, sha256 string, size int64) (*domain.Blob, error)
+	Touch(ctx context.Context, sha256 string) (*domain.Blob, error)
+}
+
+type StorageUsageRepository interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*domain.StorageUsage, error)
+	Add(ctx context.Context, userID uuid.UUID, fileBytes, audioBytes int64) error
+	Reserve(ctx context.Context, userID uuid.UUID, fileBytes, audioBytes, quota int64) (bool, error)
+	Recompute(ctx context.Context) ([]domain.StorageUsage, error)
+}
+
+type ThumbnailRepository interface {
+	Save(ctx context.Context, thumbnail *domain.Thumbnail) error
+	GetByFileID(ctx context.Context, fileID uuid.UUID) ([]domain.Thumbnail, error)
+	GetByFileIDAndSize(ctx context.Context, fileID uuid.UUID, size string) (*domain.Thumbnail, error)
+}
+
+type MaintenanceRepository interface {
+	OrphanedSessionIDs(ctx context.Context) ([]uuid.UUID, error)
+	OrphanedRecordIDs(ctx context.Context) ([]uuid.UUID, error)
+	OrphanedFileIDs(ctx context.Context) ([]uuid.UUID, error)
+	OrphanedThumbnailIDs(ctx context.Context) ([]uuid.UUID, error)
+	DeleteSessions(ctx context.Context, ids []uuid.UUID) error
+	DeleteRecords(ctx context.Context, ids []uuid.UUID) error
+	DeleteFiles(ctx context.Context, ids []uuid.UUID) error
+	DeleteThumbnails(ctx context.Context, ids []uuid.UUID) error
+	BlobRefCountMismatches(ctx context.Context) ([]domain.BlobRefCountMismatch, error)
+	ReconcileBlobRefCounts(ctx context.Context) error
+	UnreferencedBlobs(ctx context.Context, cutoff time.Time) ([]domain.Blob, error)
+	DeleteUnreferencedBlob(ctx context.Context, sha256 string, cutoff time.Time, deleteContent func() error) (bool, error)
+	BlobHashes(ctx context.Context) ([]string, error)
+	MissingBlobHashes(ctx context.Context) ([]string, error)
+	ThumbnailKeys(ctx context.Context) ([]string, error)
+	ExpiredTrash(ctx context.Context, cutoff time.Time) (domain.TrashCounts, error)
+	PurgeTrash(ctx context.Context, cutoff time.Time) (domain.TrashCounts, error)
+}
+
+type TrashRepository interface {
+	List(ctx context.Context, userID uuid.UUID) ([]domain.TrashItem, error)
+	Get(ctx context.Context, kind string, id uuid.UUID) (*domain.TrashItem, error)
+	Restore(ctx context.Context, item *domain.TrashItem) error
+}
+
+type RevisionRepository interface {
+	Create(ctx context.Context, revision *domain.Revision) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Revision, error)
+	GetByEntity(ctx context.Context, entityType string, entityID uuid.UUID, limit, offset int) ([]domain.Revision, int64, error)
+}
+
+type ReportRepository interface {
+	Summary(ctx context.Context, filter ReportFilter, groupBy string) (*domain.ReportSummary, error)
+	ExportSessions(ctx context.Context, filter ReportFilter, fn func(row *domain.ExportRow) error) error
+	Activity(ctx context.Context, filter ReportFilter) (*domain.ActivityData, error)
+	TopNotes(ctx context.Context, filter ReportFilter, limit int) ([]domain.DigestNote, error)
+	MemberActivity(ctx context.Context, userIDs []uuid.UUID, from, to time.Time, timezone string, asOf *time.Time) ([]domain.MemberActivity, error)
+}
+
+type GoalRepository interface {
+	Create(ctx context.Context, goal *domain.Goal) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Goal, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, projectIDs []uuid.UUID) ([]domain.Goal, error)
+	Update(ctx context.Context, goal *domain.Goal) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	Tracked(ctx context.Context, projectID uuid.UUID, from, to *time.Time) (int64, error)
+}
+
+type NotificationRepository interface {
+	CreateOnce(ctx context.Context, notification *domain.Notification) (bool, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]domain.Notification, int64, error)
+	MarkRead(ctx context.Context, userID uuid.UUID, id *uuid.UUID) (int64, error)
+}
+
+type PlanRepository interface {
+	Create(ctx context.Context, block *domain.PlannedBlock) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.PlannedBlock, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, from, to time.Time, projectIDs []uuid.UUID) ([]domain.PlannedBlock, error)
+	Update(ctx context.Context, block *domain.PlannedBlock) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type ClubRepository interface {
+	Create(ctx context.Context, club *domain.Club) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Club, error)
+	GetByInviteCode(ctx context.Context, code string) (*domain.Club, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Club, error)
+	Update(ctx context.Context, club *domain.Club) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetMembership(ctx context.Context, clubID, userID uuid.UUID) (*domain.ClubMembership, error)
+	GetMembers(ctx context.Context, clubID uuid.UUID) ([]domain.ClubMembership, error)
+	AddMember(ctx context.Context, membership *domain.ClubMembership) error
+	UpdateMember(ctx context.Context, membership *domain.ClubMembership) error
+	RemoveMember(ctx context.Context, clubID, userID uuid.UUID, now time.Time) error
+}
+
+type ChallengeRepository interface {
+	Create(ctx context.Context, challenge *domain.Challenge) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Challenge, error)
+	GetByClubID(ctx context.Context, clubID uuid.UUID) ([]domain.Challenge, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Join(ctx context.Context, participant *domain.ChallengeParticipant) error
+	Leave(ctx context.Context, challengeID, userID uuid.UUID) error
+	GetParticipants(ctx context.Context, challengeID uuid.UUID) ([]domain.ChallengeParticipant, error)
+	Finalize(ctx context.Context, challenge *domain.Challenge, results []domain.ChallengeResult, now time.Time) error
+	GetResults(ctx context.Context, challengeID uuid.UUID) ([]domain.ChallengeResult, error)
+}
+
+type DocumentRepository interface {
+	Create(ctx context.Context, document *domain.Document) error
+	SetFile(ctx context.Context, id uuid.UUID, storageKey string, size int64) error
+	Discard(ctx context.Context, document *domain.Document) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Document, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, kind string, limit, offset int) ([]domain.Document, int64, error)
 }
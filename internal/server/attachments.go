@@ -0,0 +1,277 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/audio"
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AttachmentIndex is written as index.json at the root of an attachments zip
+type AttachmentIndex struct {
+	ProjectID   uuid.UUID                `json:"project_id"`
+	ProjectName string                   `json:"project_name"`
+	ExportedAt  time.Time                `json:"exported_at"`
+	Sessions    []AttachmentIndexSession `json:"sessions"`
+}
+
+type AttachmentIndexSession struct {
+	ID        uuid.UUID               `json:"id"`
+	Folder    string                  `json:"folder"`
+	StartTime time.Time               `json:"start_time"`
+	EndTime   time.Time               `json:"end_time"`
+	Duration  int64                   `json:"duration"`
+	Records   []AttachmentIndexRecord `json:"records"`
+}
+
+type AttachmentIndexRecord struct {
+	ID        uuid.UUID             `json:"id"`
+	Folder    string                `json:"folder"`
+	Timestamp time.Time             `json:"timestamp"`
+	Text      string                `json:"text"`
+	GitLink   string                `json:"git_link,omitempty"`
+	Audio     string                `json:"audio,omitempty"`
+	Files     []AttachmentIndexFile `json:"files"`
+}
+
+type AttachmentIndexFile struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Path string    `json:"path"`
+	Type string    `json:"type"`
+	Size int64     `json:"size"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^\w.\- ]+`)
+
+// safeFileName strips path separators and unusual characters from a name
+// used inside a zip archive
+func safeFileName(name, fallback string) string {
+	name = strings.TrimSpace(unsafeFileChars.ReplaceAllString(path.Base(name), "_"))
+	if name == "" || name == "." || name == ".." {
+		return fallback
+	}
+	return name
+}
+
+// uniqueName returns name, or name with a counter before the extension if
+// it is already taken
+func uniqueName(taken map[string]bool, name string) string {
+	candidate := name
+	ext := path.Ext(name)
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	taken[candidate] = true
+	return candidate
+}
+
+// zipMethod stores media that is already compressed and deflates the rest
+func zipMethod(contentType string) uint16 {
+	for _, prefix := range []string{"image/", "audio/", "video/", "application/zip"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return zip.Store
+		}
+	}
+	return zip.Deflate
+}
+
+// writeAttachmentsZip streams the files and audio of the given sessions as a
+// zip with one folder per session and record, plus an index.json
+func (s *Server) writeAttachmentsZip(ctx context.Context, zw *zip.Writer, project *domain.Project, sessions []domain.Session) error {
+	index := AttachmentIndex{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		ExportedAt:  time.Now().UTC(),
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.Before(sessions[j].StartTime)
+	})
+
+	sessionFolders := map[string]bool{}
+	for _, session := range sessions {
+		entry := AttachmentIndexSession{
+			ID:        session.ID,
+			Folder:    uniqueName(sessionFolders, session.StartTime.UTC().Format("2006-01-02_15-04-05")),
+			StartTime: session.StartTime,
+			EndTime:   session.EndTime,
+			Duration:  session.Duration,
+		}
+
+		records := session.Records
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].Timestamp.Before(records[j].Timestamp)
+		})
+
+		for i, record := range records {
+			folder := path.Join(entry.Folder, fmt.Sprintf("%02d_%s", i+1, record.ID.String()[:8]))
+			recordEntry := AttachmentIndexRecord{
+				ID:        record.ID,
+				Folder:    folder,
+				Timestamp: record.Timestamp,
+				Text:      record.Text,
+				GitLink:   record.GitLink,
+				Files:     []AttachmentIndexFile{},
+			}
+			names := map[string]bool{}
+
+			if len(record.AudioData) > 0 {
+				name := uniqueName(names, "audio."+audio.Extension(record.AudioFormat))
+				recordEntry.Audio = path.Join(folder, name)
+				if err := writeZipEntry(zw, recordEntry.Audio, record.Timestamp, zip.Store, record.AudioData); err != nil {
+					return err
+				}
+			}
+
+			for _, file := range record.Files {
+				data, err := s.fileData(ctx, &file)
+				if err != nil {
+					log.Printf("Skipping file %s in attachments zip: %v", file.ID, err)
+					continue
+				}
+				if len(data) == 0 {
+					continue
+				}
+
+				name := uniqueName(names, safeFileName(file.Name, "file_"+file.ID.String()[:8]))
+				filePath := path.Join(folder, name)
+				if err := writeZipEntry(zw, filePath, file.CreatedAt, zipMethod(file.Type), data); err != nil {
+					return err
+				}
+				recordEntry.Files = append(recordEntry.Files, AttachmentIndexFile{
+					ID:   file.ID,
+					Name: file.Name,
+					Path: filePath,
+					Type: file.Type,
+					Size: int64(len(data)),
+				})
+			}
+
+			entry.Records = append(entry.Records, recordEntry)
+		}
+
+		index.Sessions = append(index.Sessions, entry)
+	}
+
+	indexData, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeZipEntry(zw, "index.json", index.ExportedAt, zip.Deflate, indexData)
+}
+
+func writeZipEntry(zw *zip.Writer, name string, modified time.Time, method uint16, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// streamAttachmentsZip writes the zip response. Headers are sent before the
+// archive is built, so failures part way through can only be logged.
+func (s *Server) streamAttachmentsZip(c *gin.Context, filename string, project *domain.Project, sessions []domain.Session) {
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	if err := s.writeAttachmentsZip(c, zw, project, sessions); err != nil {
+		log.Printf("Failed to write attachments zip: %v", err)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Failed to finish attachments zip: %v", err)
+	}
+}
+
+// handleGetProjectAttachments downloads every attachment of a project
+func (s *Server) handleGetProjectAttachments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		project, err := s.projectRepo.GetByID(c, projectID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+
+		if project.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+
+		filename := safeFileName(project.Name, "project") + "-attachments.zip"
+		s.streamAttachmentsZip(c, filename, project, project.Sessions)
+	}
+}
+
+// handleGetSessionAttachments downloads the attachments of a single session
+func (s *Server) handleGetSessionAttachments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		sessionID, err := uuid.Parse(c.Param("sessionId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		// Verify project ownership
+		project, err := s.projectRepo.GetByID(c, projectID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+
+		if project.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+
+		session, err := s.sessionRepo.GetByID(c, sessionID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+
+		if session.ProjectID != projectID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+
+		filename := fmt.Sprintf("%s-%s-attachments.zip",
+			safeFileName(project.Name, "project"), session.StartTime.UTC().Format("2006-01-02_15-04-05"))
+		s.streamAttachmentsZip(c, filename, project, []domain.Session{*session})
+	}
+}
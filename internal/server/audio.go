@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ZigaoWang/zebra-server/internal/audio"
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// analyzeAudio fills in the audio metadata of records that carry audio data.
+// Only headers are parsed, so this is cheap enough to run before saving.
+func (s *Server) analyzeAudio(session *domain.Session) {
+	for i := range session.Records {
+		record := &session.Records[i]
+		if len(record.AudioData) == 0 {
+			continue
+		}
+
+		info, err := audio.Analyze(record.AudioData)
+		if err != nil {
+			log.Printf("Failed to analyze audio: %v", err)
+			record.AudioFormat = audio.Detect(record.AudioData)
+			continue
+		}
+
+		record.AudioFormat = info.Format
+		record.AudioCodec = info.Codec
+		record.AudioDuration = info.DurationMs
+		record.AudioSampleRate = info.SampleRate
+		record.AudioChannels = info.Channels
+	}
+}
+
+// processAudio computes waveform peaks for the records of a newly created
+// session in the background, since decoding may take a while
+func (s *Server) processAudio(session *domain.Session) {
+	var recordIDs []uuid.UUID
+	for _, record := range session.Records {
+		if len(record.AudioData) > 0 {
+			recordIDs = append(recordIDs, record.ID)
+		}
+	}
+	if len(recordIDs) == 0 {
+		return
+	}
+
+	go func() {
+		for _, id := range recordIDs {
+			if err := s.computeWaveform(context.Background(), id); err != nil {
+				log.Printf("Failed to compute waveform for record %s: %v", id, err)
+			}
+		}
+	}()
+}
+
+func (s *Server) computeWaveform(ctx context.Context, recordID uuid.UUID) error {
+	record, err := s.sessionRepo.GetRecordByID(ctx, recordID)
+	if err != nil || record == nil {
+		return err
+	}
+
+	info, err := audio.Analyze(record.AudioData)
+	if err != nil {
+		return err
+	}
+
+	peaks, err := audio.Peaks(ctx, record.AudioData, info, s.cfg.Audio.FFmpegPath)
+	if err != nil {
+		return err
+	}
+	if peaks == nil {
+		return nil
+	}
+
+	record.AudioPeaks = peaks
+	return s.sessionRepo.UpdateRecordAudio(ctx, record)
+}
+
+// handleGetWaveform returns the waveform peaks of a record's audio. The
+// optional peaks query parameter reduces the number of values returned.
+func (s *Server) handleGetWaveform() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recordID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID format"})
+			return
+		}
+
+		count := 0
+		if v := c.Query("peaks"); v != "" {
+			count, err = strconv.Atoi(v)
+			if err != nil || count <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid peaks parameter"})
+				return
+			}
+		}
+
+		record, err := s.sessionRepo.GetRecordByID(c, recordID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch record"})
+			return
+		}
+		if record == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+			return
+		}
+		if len(record.AudioPeaks) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Waveform not available"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"record_id":   record.ID,
+			"duration":    record.AudioDuration,
+			"sample_rate": record.AudioSampleRate,
+			"channels":    record.AudioChannels,
+			"peaks":       audio.Downsample(record.AudioPeaks, count),
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// errUnknownBlob is returned when a file references content by hash that
+// the user has not uploaded
+var errUnknownBlob = errors.New("unknown blob")
+
+// resolveFileBlobs hashes the content of uploaded files and fills in their
+// stored sizes. Files may also omit their data and reference content by its
+// SHA-256, as long as one of the user's own files already holds it. Knowing
+// a hash is not proof of having the content. Nothing is written, so the
+// sizes can be checked against the quota first.
+func (s *Server) resolveFileBlobs(ctx context.Context, userID uuid.UUID, session *domain.Session) error {
+	for i := range session.Records {
+		for j := range session.Records[i].Files {
+			file := &session.Records[i].Files[j]
+
+			if len(file.Data) > 0 {
+				sum := sha256.Sum256(file.Data)
+				file.SHA256 = hex.EncodeToString(sum[:])
+				file.Size = int64(len(file.Data))
+				continue
+			}
+			if file.SHA256 == "" {
+				continue
+			}
+
+			file.SHA256 = strings.ToLower(file.SHA256)
+			blob, err := s.blobRepo.GetOwnedBySHA256(ctx, userID, file.SHA256)
+			if err != nil {
+				return err
+			}
+			if blob != nil {
+				// Keeps the content from collection until the file is saved,
+				// in case the user's last reference to it goes meanwhile
+				if blob, err = s.blobRepo.Touch(ctx, file.SHA256); err != nil {
+					return err
+				}
+			}
+			if blob == nil {
+				return fmt.Errorf("%w: %s", errUnknownBlob, file.SHA256)
+			}
+			file.Size = blob.Size
+		}
+	}
+	return nil
+}
+
+// storeFileBlobs moves the content of resolved files into content-addressed
+// blobs so identical uploads share storage
+func (s *Server) storeFileBlobs(ctx context.Context, session *domain.Session) error {
+	for i := range session.Records {
+		for j := range session.Records[i].Files {
+			file := &session.Records[i].Files[j]
+			if len(file.Data) == 0 {
+				continue
+			}
+
+			// Claiming the blob first keeps the collector from deleting the
+			// content written below before the file is saved
+			blob, err := s.blobRepo.Claim(ctx, file.SHA256, file.Size)
+			if err != nil {
+				return err
+			}
+			if blob.RefCount == 0 {
+				// Unreferenced content may have been collected, so it is
+				// written again. Objects are addressed by content, so writing
+				// twice is harmless.
+				if err := s.fileStore.Put(ctx, domain.BlobKey(file.SHA256), file.Data); err != nil {
+					return err
+				}
+			}
+			file.Data = nil
+		}
+	}
+	return nil
+}
+
+// fileData returns the content of a file, reading it from the blob store
+// unless it is a legacy row with inline data
+func (s *Server) fileData(ctx context.Context, file *domain.File) ([]byte, error) {
+	if len(file.Data) > 0 || file.SHA256 == "" {
+		return file.Data, nil
+	}
+	return s.fileStore.Get(ctx, domain.BlobKey(file.SHA256))
+}
+
+// handleHeadBlob lets clients check whether they already uploaded some
+// content, so they can reference it by hash instead of uploading it again.
+// Content only other users uploaded is reported as missing.
+func (s *Server) handleHeadBlob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := strings.ToLower(c.Param("sha256"))
+		if !sha256Pattern.MatchString(hash) {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		blob, err := s.blobRepo.GetOwnedBySHA256(c, userID, hash)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if blob == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		c.Header("Content-Length", strconv.FormatInt(blob.Size, 10))
+		c.Status(http.StatusOK)
+	}
+}
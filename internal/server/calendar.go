@@ -0,0 +1,396 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/ical"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Limits of one calendar import
+const (
+	maxCalendarImportBytes  = 5 << 20
+	maxCalendarImportEvents = 2000
+)
+
+// Calendar import result statuses
+const (
+	ImportValid     = "valid" // would be created, for dry runs
+	ImportCreated   = "created"
+	ImportDuplicate = "duplicate" // already imported, or a session published by this server
+	ImportSkipped   = "skipped"
+	ImportInvalid   = "invalid"
+)
+
+// CalendarImportResult reports what happened to one event of an import
+type CalendarImportResult struct {
+	UID       string                   `json:"uid"`
+	Summary   string                   `json:"summary"`
+	Status    string                   `json:"status"`
+	Reason    string                   `json:"reason,omitempty"`
+	Errors    []domain.FieldError      `json:"errors,omitempty"`
+	Conflicts []domain.SessionConflict `json:"conflicts,omitempty"`
+	Session   *domain.Session          `json:"session,omitempty"`
+}
+
+// calendarFeedURL is the subscription URL of a calendar token, under the
+// configured public URL or else the host the request came to
+func (s *Server) calendarFeedURL(c *gin.Context, token string) string {
+	base := strings.TrimSuffix(s.cfg.Server.PublicURL, "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/calendar/" + token + ".ics"
+}
+
+func (s *Server) calendarResponse(c *gin.Context, user *domain.User) gin.H {
+	if user.CalendarToken == nil {
+		return gin.H{"enabled": false}
+	}
+	return gin.H{"enabled": true, "url": s.calendarFeedURL(c, *user.CalendarToken)}
+}
+
+// handleGetCalendar tells whether the user's calendar feed is enabled and
+// under which URL
+func (s *Server) handleGetCalendar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		user, err := s.userRepo.GetByID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+			return
+		}
+		c.JSON(http.StatusOK, s.calendarResponse(c, user))
+	}
+}
+
+// handleRotateCalendarToken enables the calendar feed under a new secret
+// URL. Subscriptions to a previous URL stop working.
+func (s *Server) handleRotateCalendarToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		user, err := s.userRepo.GetByID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+			return
+		}
+
+		token, err := domain.NewCalendarToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar token"})
+			return
+		}
+		user.CalendarToken = &token
+		user.UpdatedAt = time.Now()
+		if err := s.userRepo.Update(c, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user profile"})
+			return
+		}
+
+		c.JSON(http.StatusOK, s.calendarResponse(c, user))
+	}
+}
+
+// handleDisableCalendar turns the calendar feed off
+func (s *Server) handleDisableCalendar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		user, err := s.userRepo.GetByID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+			return
+		}
+
+		user.CalendarToken = nil
+		user.UpdatedAt = time.Now()
+		if err := s.userRepo.Update(c, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user profile"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleCalendarFeed serves the sessions of the user owning the token in
+// the URL as iCalendar events. It needs no other authentication because
+// calendar apps cannot send any.
+func (s *Server) handleCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+		user, err := s.userRepo.GetByCalendarToken(c, token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calendar"})
+			return
+		}
+		if user == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+			return
+		}
+
+		// Headers are sent before the sessions are read, so failures part
+		// way through can only be logged
+		c.Header("Content-Type", ical.ContentType)
+		c.Header("Content-Disposition", `inline; filename="zebra.ics"`)
+		c.Header("Cache-Control", "private, max-age=300")
+		c.Status(http.StatusOK)
+
+		refresh := time.Duration(s.cfg.Calendar.RefreshMinutes) * time.Minute
+		w := ical.NewWriter(c.Writer, "Zebra sessions", refresh)
+		from := time.Now().AddDate(0, 0, -s.cfg.Calendar.FeedDays)
+		err = s.sessionRepo.GetCalendarEvents(c, user.ID, from, func(event *domain.CalendarEvent) error {
+			return w.WriteEvent(&ical.Event{
+				UID:         event.UID(),
+				Summary:     event.ProjectName,
+				Description: event.Notes,
+				Categories:  event.Tags,
+				Start:       event.StartTime,
+				End:         event.EndTime,
+				Updated:     event.UpdatedAt,
+			})
+		})
+		if err != nil {
+			log.Printf("Failed to write calendar feed of user %s: %v", user.ID, err)
+			return
+		}
+		if err := w.Close(); err != nil {
+			log.Printf("Failed to finish calendar feed of user %s: %v", user.ID, err)
+		}
+	}
+}
+
+// handleImportCalendar turns the events of an uploaded .ics file, sent as
+// the multipart field "file" or as the request body, into manual sessions
+// of the project. Events are identified by UID, so importing a file again
+// only adds events that are new. Floating times are read in ?tz=, which
+// defaults to the user's time zone. With ?dry_run=true nothing is created.
+func (s *Server) handleImportCalendar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		project, ok := s.authorizeProject(c, projectID)
+		if !ok {
+			return
+		}
+		userID := project.UserID
+
+		user, err := s.userRepo.GetByID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+		loc, ok := loadTimezone(c.DefaultQuery("tz", user.Timezone))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tz must be an IANA time zone such as Europe/Berlin"})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarImportBytes)
+		var body io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			header, err := c.FormFile("file")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		events, err := ical.Parse(body, loc)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Calendar files must be at most %d MB", maxCalendarImportBytes>>20)})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar: " + err.Error()})
+			return
+		}
+		if len(events) > maxCalendarImportEvents {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Calendars may contain at most %d events", maxCalendarImportEvents)})
+			return
+		}
+
+		existing, err := s.importedEvents(c, userID, events)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for imported events"})
+			return
+		}
+
+		dryRun := c.Query("dry_run") == "true"
+		now := time.Now()
+		results := make([]CalendarImportResult, len(events))
+		sessions := make([]domain.Session, 0, len(events))
+		created := make([]int, 0, len(events)) // result index of each session
+
+		for i, event := range events {
+			result := &results[i]
+			result.UID = event.UID
+			result.Summary = event.Summary
+			result.Status = ImportSkipped
+
+			switch {
+			case event.UID == "":
+				result.Reason = "event has no UID"
+				continue
+			case existing[event.UID]:
+				result.Status = ImportDuplicate
+				result.Reason = "already imported"
+				continue
+			case event.Cancelled:
+				result.Reason = "event is cancelled"
+				continue
+			case event.Recurring:
+				result.Reason = "recurring events are not imported"
+				continue
+			case event.AllDay:
+				result.Reason = "all-day events are not imported"
+				continue
+			}
+			// Later events with the same UID are duplicates of this one
+			existing[event.UID] = true
+
+			session := calendarSession(event, projectID, now)
+			if err := session.Validate(now); err != nil {
+				var verr *domain.ValidationError
+				if !errors.As(err, &verr) {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				result.Status = ImportInvalid
+				result.Errors = verr.Fields
+				continue
+			}
+
+			if user.OverlapPolicy != domain.OverlapAllow {
+				others, err := s.sessionRepo.FindOverlapping(c, userID, session.StartTime, session.EndTime)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for overlapping sessions"})
+					return
+				}
+				result.Conflicts = session.FindConflicts(append(others, sessions...))
+				if len(result.Conflicts) > 0 && user.OverlapPolicy == domain.OverlapReject {
+					result.Reason = "overlaps existing sessions"
+					continue
+				}
+			}
+
+			session.RoundedDuration = project.RoundDuration(session.Duration)
+			session.Conflicts = result.Conflicts
+			sessions = append(sessions, session)
+			created = append(created, i)
+			result.Status = ImportValid
+		}
+
+		var skipped []int
+		if !dryRun && len(sessions) > 0 {
+			if skipped, err = s.createSessions(c, sessions); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sessions"})
+				return
+			}
+			s.checkBudgets(c, projectID)
+		}
+		next := 0
+		for j, i := range created {
+			// Skipped sessions were imported by another request meanwhile
+			if next < len(skipped) && skipped[next] == j {
+				next++
+				results[i].Status = ImportDuplicate
+				results[i].Reason = "already imported"
+				continue
+			}
+			if !dryRun {
+				results[i].Status = ImportCreated
+			}
+			results[i].Session = &sessions[j]
+		}
+
+		status := http.StatusCreated
+		if dryRun {
+			status = http.StatusOK
+		}
+		c.JSON(status, gin.H{"created": len(sessions) - len(skipped), "events": len(events), "results": results})
+	}
+}
+
+// importedEvents returns the UIDs among the events that the user already
+// has sessions for, either imported or published by this server
+func (s *Server) importedEvents(c *gin.Context, userID uuid.UUID, events []ical.Event) (map[string]bool, error) {
+	var uids []string
+	var ids []uuid.UUID
+	for _, event := range events {
+		if event.UID == "" {
+			continue
+		}
+		uids = append(uids, event.UID)
+		if id, ok := domain.SessionIDFromEventUID(event.UID); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	sessions, err := s.sessionRepo.FindImported(c, userID, uids, ids)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		if session.ICalUID != nil {
+			existing[*session.ICalUID] = true
+		}
+		existing[domain.SessionEventUID(session.ID)] = true
+	}
+	return existing, nil
+}
+
+// calendarSession builds the manual session for an event. Its summary and
+// description become the session's only record.
+func calendarSession(event ical.Event, projectID uuid.UUID, now time.Time) domain.Session {
+	uid := event.UID
+	session := domain.Session{
+		ID:        uuid.New(),
+		ProjectID: projectID,
+		StartTime: event.Start,
+		EndTime:   event.End,
+		Duration:  int64(event.End.Sub(event.Start) / time.Second),
+		Manual:    true,
+		Tags:      normalizeTags(event.Categories),
+		ICalUID:   &uid,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	text := strings.TrimSpace(strings.TrimSpace(event.Summary) + "\n\n" + strings.TrimSpace(event.Description))
+	if text != "" {
+		session.Records = []domain.Record{{
+			ID:        uuid.New(),
+			SessionID: session.ID,
+			Text:      text,
+			Timestamp: event.Start,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}}
+	}
+	return session
+}
@@ -0,0 +1,673 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateClubRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Timezone string `json:"timezone"` // the creator's by default
+}
+
+type UpdateClubRequest struct {
+	Name            *string `json:"name"`
+	Timezone        *string `json:"timezone"`
+	RenewInviteCode bool    `json:"renew_invite_code"` // so the old code stops working
+}
+
+type JoinClubRequest struct {
+	InviteCode string `json:"invite_code" binding:"required"`
+}
+
+type UpdateMembershipRequest struct {
+	ShareStats *bool `json:"share_stats" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+	HourlyRate      *int64 `json:"hourly_rate"`       // minor units per hour
+	ClearHourlyRate bool   `json:"clear_hourly_rate"` // fall back to the member's own rate
+}
+
+type CreateChallengeRequest struct {
+	Name      string    `json:"name" binding:"required"`
+	Metric    string    `json:"metric" binding:"required"` // time, sessions or streak
+	Goal      int64     `json:"goal"`
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+}
+
+// ChallengeResponse is a challenge with its standings so far, which are
+// final once it has ended
+type ChallengeResponse struct {
+	domain.Challenge
+	Joined    bool                `json:"joined"`
+	Standings *domain.Leaderboard `json:"standings"`
+}
+
+// handleCreateClub creates a club with the user as its owner
+func (s *Server) handleCreateClub() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateClubRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		if req.Timezone == "" {
+			user, err := s.userRepo.GetByID(c, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+				return
+			}
+			req.Timezone = user.Timezone
+		}
+		code, err := domain.NewInviteCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite code"})
+			return
+		}
+
+		now := time.Now()
+		club := &domain.Club{
+			ID:         uuid.New(),
+			Name:       req.Name,
+			OwnerID:    userID,
+			Timezone:   req.Timezone,
+			InviteCode: code,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := club.Validate(); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+
+		if err := s.clubRepo.Create(c, club); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create club"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, club)
+	}
+}
+
+// handleGetClubs lists the clubs the user is a member of
+func (s *Server) handleGetClubs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		clubs, err := s.clubRepo.GetByUserID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clubs"})
+			return
+		}
+		c.JSON(http.StatusOK, clubs)
+	}
+}
+
+// handleGetClub returns a club with its members
+func (s *Server) handleGetClub() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		club, membership, ok := s.clubMembership(c)
+		if !ok {
+			return
+		}
+
+		members, err := s.clubRepo.GetMembers(c, club.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club members"})
+			return
+		}
+		// Rates are only shown to the owner who sets them and to each member
+		for i := range members {
+			if membership.Role != domain.ClubOwner && members[i].UserID != membership.UserID {
+				members[i].HourlyRate = nil
+			}
+		}
+		club.Members = members
+		c.JSON(http.StatusOK, club)
+	}
+}
+
+// handleUpdateClub renames a club, changes its time zone or renews its
+// invite code. Only the owner may.
+func (s *Server) handleUpdateClub() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		club, ok := s.ownedClub(c)
+		if !ok {
+			return
+		}
+
+		var req UpdateClubRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.Name != nil {
+			club.Name = *req.Name
+		}
+		if req.Timezone != nil {
+			club.Timezone = *req.Timezone
+		}
+		if err := club.Validate(); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+		if req.RenewInviteCode {
+			code, err := domain.NewInviteCode()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite code"})
+				return
+			}
+			club.InviteCode = code
+		}
+		club.UpdatedAt = time.Now()
+
+		if err := s.clubRepo.Update(c, club); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update club"})
+			return
+		}
+
+		c.JSON(http.StatusOK, club)
+	}
+}
+
+// handleDeleteClub deletes a club with its challenges. Only the owner may.
+func (s *Server) handleDeleteClub() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		club, ok := s.ownedClub(c)
+		if !ok {
+			return
+		}
+
+		if err := s.clubRepo.Delete(c, club.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete club"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleJoinClub makes the user a member of the club with the invite code.
+// New members do not share their stats until they opt in.
+func (s *Server) handleJoinClub() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req JoinClubRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		club, err := s.clubRepo.GetByInviteCode(c, req.InviteCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club"})
+			return
+		}
+		if club == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid invite code"})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		membership := &domain.ClubMembership{ClubID: club.ID, UserID: userID, Role: domain.ClubMember, JoinedAt: time.Now()}
+		if err := s.clubRepo.AddMember(c, membership); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join club"})
+			return
+		}
+
+		c.JSON(http.StatusOK, club)
+	}
+}
+
+// handleLeaveClub ends the user's membership and takes them out of the
+// club's running challenges. The owner deletes the club instead.
+func (s *Server) handleLeaveClub() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		club, membership, ok := s.clubMembership(c)
+		if !ok {
+			return
+		}
+		if membership.Role == domain.ClubOwner {
+			c.JSON(http.StatusConflict, gin.H{"error": "The owner cannot leave the club; delete it instead"})
+			return
+		}
+
+		if err := s.clubRepo.RemoveMember(c, club.ID, membership.UserID, time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave club"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleUpdateMembership opts the user in to or out of the club's
+// leaderboards
+func (s *Server) handleUpdateMembership() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, membership, ok := s.clubMembership(c)
+		if !ok {
+			return
+		}
+
+		var req UpdateMembershipRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		membership.ShareStats = *req.ShareStats
+		if err := s.clubRepo.UpdateMember(c, membership); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update membership"})
+			return
+		}
+
+		c.JSON(http.StatusOK, membership)
+	}
+}
+
+// handleUpdateMember sets the rate the club bills a member's time at on
+// invoices issued for it. Only the owner may.
+func (s *Server) handleUpdateMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		club, ok := s.ownedClub(c)
+		if !ok {
+			return
+		}
+		userID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var req UpdateMemberRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.HourlyRate != nil && *req.HourlyRate < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalidRateMessage})
+			return
+		}
+
+		membership, err := s.clubRepo.GetMembership(c, club.ID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club membership"})
+			return
+		}
+		if membership == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+
+		if req.HourlyRate != nil {
+			membership.HourlyRate = req.HourlyRate
+		}
+		if req.ClearHourlyRate {
+			membership.HourlyRate = nil
+		}
+		if err := s.clubRepo.UpdateMember(c, membership); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update membership"})
+			return
+		}
+
+		c.JSON(http.StatusOK, membership)
+	}
+}
+
+// handleGetLeaderboard ranks the members sharing their stats by ?metric=
+// (time, sessions or streak) over ?window= (week, month or year, the
+// current one in the club's time zone) or ?from= and ?to=
+func (s *Server) handleGetLeaderboard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		club, _, ok := s.clubMembership(c)
+		if !ok {
+			return
+		}
+		loc, _ := loadTimezone(club.Timezone)
+
+		metric := c.DefaultQuery("metric", domain.MetricTime)
+		if !domain.ValidMetric(metric) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metric must be time, sessions or streak"})
+			return
+		}
+
+		from, to, ok := domain.WindowBounds(c.DefaultQuery("window", domain.WindowWeek), time.Now(), loc)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be week, month or year"})
+			return
+		}
+		if v := c.Query("from"); v != "" {
+			if from, ok = parseReportTime(v, loc, false); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+				return
+			}
+		}
+		if v := c.Query("to"); v != "" {
+			if to, ok = parseReportTime(v, loc, true); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+				return
+			}
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+			return
+		}
+
+		members, err := s.clubRepo.GetMembers(c, club.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club members"})
+			return
+		}
+		names := make(map[uuid.UUID]string)
+		var userIDs []uuid.UUID
+		for _, member := range members {
+			if member.ShareStats {
+				names[member.UserID] = member.UserName
+				userIDs = append(userIDs, member.UserID)
+			}
+		}
+
+		board, err := s.rankMembers(c, userIDs, names, metric, 0, from, to, loc, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute leaderboard"})
+			return
+		}
+		c.JSON(http.StatusOK, board)
+	}
+}
+
+// handleCreateChallenge adds a challenge to a club. Only the owner may.
+func (s *Server) handleCreateChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		club, ok := s.ownedClub(c)
+		if !ok {
+			return
+		}
+
+		var req CreateChallengeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		challenge := &domain.Challenge{
+			ID:        uuid.New(),
+			ClubID:    club.ID,
+			Name:      req.Name,
+			Metric:    req.Metric,
+			Goal:      req.Goal,
+			StartTime: req.StartTime,
+			EndTime:   req.EndTime,
+			CreatedBy: club.OwnerID,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := challenge.Validate(); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+
+		if err := s.challengeRepo.Create(c, challenge); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, challenge)
+	}
+}
+
+// handleGetChallenges lists a club's challenges, latest first
+func (s *Server) handleGetChallenges() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		club, _, ok := s.clubMembership(c)
+		if !ok {
+			return
+		}
+
+		challenges, err := s.challengeRepo.GetByClubID(c, club.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenges"})
+			return
+		}
+		c.JSON(http.StatusOK, challenges)
+	}
+}
+
+// handleGetChallenge returns a challenge with the standings of its
+// participants, computed from their sessions. The first request after the
+// challenge ends keeps the final standings, counting sessions as they
+// stood at the end, and later requests return those.
+func (s *Server) handleGetChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		challenge, club, membership, ok := s.clubChallenge(c)
+		if !ok {
+			return
+		}
+		loc, _ := loadTimezone(club.Timezone)
+
+		participants, err := s.challengeRepo.GetParticipants(c, challenge.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch participants"})
+			return
+		}
+		response := ChallengeResponse{Challenge: *challenge}
+		names := make(map[uuid.UUID]string, len(participants))
+		userIDs := make([]uuid.UUID, 0, len(participants))
+		for _, participant := range participants {
+			names[participant.UserID] = participant.UserName
+			userIDs = append(userIDs, participant.UserID)
+			if participant.UserID == membership.UserID {
+				response.Joined = true
+			}
+		}
+
+		now := time.Now()
+		if !challenge.Ended(now) {
+			response.Standings, err = s.rankMembers(c, userIDs, names, challenge.Metric, challenge.Goal, challenge.StartTime, challenge.EndTime, loc, nil)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute standings"})
+				return
+			}
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		if challenge.FinalizedAt == nil {
+			board, err := s.rankMembers(c, userIDs, names, challenge.Metric, challenge.Goal, challenge.StartTime, challenge.EndTime, loc, &challenge.EndTime)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute standings"})
+				return
+			}
+			if err := s.challengeRepo.Finalize(c, challenge, board.Results(challenge.ID), now); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save final standings"})
+				return
+			}
+		}
+		results, err := s.challengeRepo.GetResults(c, challenge.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch final standings"})
+			return
+		}
+		response.Challenge = *challenge
+		response.Standings = challenge.FinalStandings(results, loc)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// handleDeleteChallenge deletes a challenge. Only the club's owner may.
+func (s *Server) handleDeleteChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		challenge, _, membership, ok := s.clubChallenge(c)
+		if !ok {
+			return
+		}
+		if membership.Role != domain.ClubOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the club's owner can delete challenges"})
+			return
+		}
+
+		if err := s.challengeRepo.Delete(c, challenge.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete challenge"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleJoinChallenge enters the user into a challenge that has not ended
+func (s *Server) handleJoinChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		challenge, _, membership, ok := s.clubChallenge(c)
+		if !ok {
+			return
+		}
+		now := time.Now()
+		if challenge.Ended(now) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Challenge has ended"})
+			return
+		}
+
+		participant := &domain.ChallengeParticipant{ChallengeID: challenge.ID, UserID: membership.UserID, JoinedAt: now}
+		if err := s.challengeRepo.Join(c, participant); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join challenge"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleLeaveChallenge takes the user out of a challenge that has not
+// ended. Final standings cannot be left.
+func (s *Server) handleLeaveChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		challenge, _, membership, ok := s.clubChallenge(c)
+		if !ok {
+			return
+		}
+		if challenge.Ended(time.Now()) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Challenge has ended"})
+			return
+		}
+
+		if err := s.challengeRepo.Leave(c, challenge.ID, membership.UserID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave challenge"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// rankMembers ranks the users by their sessions starting in from-to, as
+// they stood at asOf when given. Users who tracked nothing are ranked with
+// a value of 0.
+func (s *Server) rankMembers(ctx context.Context, userIDs []uuid.UUID, names map[uuid.UUID]string, metric string, goal int64, from, to time.Time, loc *time.Location, asOf *time.Time) (*domain.Leaderboard, error) {
+	tracked, err := s.reportRepo.MemberActivity(ctx, userIDs, from, to, loc.String(), asOf)
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[uuid.UUID]domain.MemberActivity, len(tracked))
+	for _, a := range tracked {
+		byUser[a.UserID] = a
+	}
+
+	activity := make([]domain.MemberActivity, 0, len(userIDs))
+	for _, userID := range userIDs {
+		a := byUser[userID]
+		a.UserID = userID
+		a.Name = names[userID]
+		activity = append(activity, a)
+	}
+	return domain.RankMembers(metric, activity, goal, from, to, loc, time.Now()), nil
+}
+
+// clubMembership loads the club in the :id parameter and the user's
+// membership, responding with an error unless the user is a member
+func (s *Server) clubMembership(c *gin.Context) (*domain.Club, *domain.ClubMembership, bool) {
+	clubID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid club ID"})
+		return nil, nil, false
+	}
+
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	membership, err := s.clubRepo.GetMembership(c, clubID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club membership"})
+		return nil, nil, false
+	}
+	var club *domain.Club
+	if membership != nil {
+		if club, err = s.clubRepo.GetByID(c, clubID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club"})
+			return nil, nil, false
+		}
+	}
+	if club == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Club not found"})
+		return nil, nil, false
+	}
+	return club, membership, true
+}
+
+// ownedClub loads the club in the :id parameter, responding with an error
+// unless the user owns it
+func (s *Server) ownedClub(c *gin.Context) (*domain.Club, bool) {
+	club, membership, ok := s.clubMembership(c)
+	if !ok {
+		return nil, false
+	}
+	if membership.Role != domain.ClubOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the club's owner can do this"})
+		return nil, false
+	}
+	return club, true
+}
+
+// clubChallenge loads the challenge in the :id parameter with its club and
+// the user's membership, responding with an error unless the user is a
+// member of the club
+func (s *Server) clubChallenge(c *gin.Context) (*domain.Challenge, *domain.Club, *domain.ClubMembership, bool) {
+	challengeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid challenge ID"})
+		return nil, nil, nil, false
+	}
+
+	challenge, err := s.challengeRepo.GetByID(c, challengeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge"})
+		return nil, nil, nil, false
+	}
+	var membership *domain.ClubMembership
+	if challenge != nil {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		if membership, err = s.clubRepo.GetMembership(c, challenge.ClubID, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club membership"})
+			return nil, nil, nil, false
+		}
+	}
+	if membership == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return nil, nil, nil, false
+	}
+
+	club, err := s.clubRepo.GetByID(c, challenge.ClubID)
+	if err != nil || club == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club"})
+		return nil, nil, nil, false
+	}
+	return challenge, club, membership, true
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unsubscribePage asks to confirm, since mail scanners follow links in
+// emails; its form and one-click clients POST to the same URL
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Weekly digest</title></head>
+<body style="font-family: Helvetica, Arial, sans-serif; max-width: 480px; margin: 48px auto;">
+{{if .Done}}<p>You will no longer get the weekly digest. You can turn it back on in your profile settings.</p>
+{{else}}<p>Stop getting the weekly digest of your tracked time?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}</body>
+</html>
+`))
+
+// handleUnsubscribePage shows the confirmation for an unsubscribe link
+func (s *Server) handleUnsubscribePage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := s.userRepo.GetByUnsubscribeToken(c, c.Param("token"))
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Failed to fetch subscription")
+			return
+		}
+		if user == nil {
+			c.String(http.StatusNotFound, "Unknown unsubscribe link")
+			return
+		}
+
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		unsubscribePage.Execute(c.Writer, gin.H{"Done": !user.DigestEnabled})
+	}
+}
+
+// handleUnsubscribe turns off the weekly digest of the user the token in
+// the URL belongs to
+func (s *Server) handleUnsubscribe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := s.userRepo.GetByUnsubscribeToken(c, c.Param("token"))
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Failed to fetch subscription")
+			return
+		}
+		if user == nil {
+			c.String(http.StatusNotFound, "Unknown unsubscribe link")
+			return
+		}
+
+		if user.DigestEnabled {
+			user.DigestEnabled = false
+			user.UpdatedAt = time.Now()
+			if err := s.userRepo.Update(c, user); err != nil {
+				c.String(http.StatusInternalServerError, "Failed to unsubscribe")
+				return
+			}
+		}
+
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		unsubscribePage.Execute(c.Writer, gin.H{"Done": true})
+	}
+}
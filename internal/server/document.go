@@ -0,0 +1,272 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/export"
+	"github.com/ZigaoWang/zebra-server/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxDocumentSessions caps the sessions a single document may cover
+const maxDocumentSessions = 5000
+
+var errTooManySessions = errors.New("too many sessions")
+
+type CreateDocumentRequest struct {
+	Kind      string     `json:"kind" binding:"required"` // timesheet or invoice
+	LineItems string     `json:"line_items"`              // session (default) or day
+	TaxRate   float64    `json:"tax_rate"`                // percent, invoices only
+	BillTo    string     `json:"bill_to"`                 // recipient address, one line per row
+	Notes     string     `json:"notes"`
+	Locale    string     `json:"locale"`  // number and date format, as for exports
+	ClubID    *uuid.UUID `json:"club_id"` // club the user issues the document for
+}
+
+// handleCreateDocument renders a PDF timesheet or invoice of the sessions
+// selected by the report filters in the query. Invoices bill the rounded
+// time at each project's hourly rate, or else the user's. Invoices issued
+// for a club use the user's rate in the club and the club's numbering.
+func (s *Server) handleCreateDocument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateDocumentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Kind != domain.DocumentTimesheet && req.Kind != domain.DocumentInvoice {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be timesheet or invoice"})
+			return
+		}
+		if req.LineItems == "" {
+			req.LineItems = domain.LineItemsPerSession
+		}
+		if req.LineItems != domain.LineItemsPerSession && req.LineItems != domain.LineItemsPerDay {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "line_items must be session or day"})
+			return
+		}
+		if req.TaxRate < 0 || req.TaxRate > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tax_rate must be between 0 and 100"})
+			return
+		}
+		locale, ok := export.LookupLocale(req.Locale)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+			return
+		}
+
+		filter, ok := s.reportFilter(c)
+		if !ok {
+			return
+		}
+		loc, _ := loadTimezone(filter.Timezone)
+
+		user, err := s.userRepo.GetByID(c, filter.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+			return
+		}
+
+		rate := user.HourlyRate
+		var club *domain.Club
+		if req.ClubID != nil {
+			membership, err := s.clubRepo.GetMembership(c, *req.ClubID, user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club membership"})
+				return
+			}
+			if membership != nil {
+				if club, err = s.clubRepo.GetByID(c, *req.ClubID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch club"})
+					return
+				}
+			}
+			if club == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Club not found"})
+				return
+			}
+			rate = membership.Rate(rate)
+		}
+
+		var rows []domain.ExportRow
+		err = s.reportRepo.ExportSessions(c, filter, func(row *domain.ExportRow) error {
+			if len(rows) == maxDocumentSessions {
+				return errTooManySessions
+			}
+			rows = append(rows, *row)
+			return nil
+		})
+		if errors.Is(err, errTooManySessions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A document can cover at most %d sessions, narrow the filters", maxDocumentSessions)})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+			return
+		}
+
+		lines := domain.BuildDocumentLines(rows, req.LineItems, loc, rate)
+		document := &domain.Document{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			ClubID:    req.ClubID,
+			Kind:      req.Kind,
+			From:      filter.From,
+			To:        filter.To,
+			Timezone:  filter.Timezone,
+			TaxRate:   req.TaxRate,
+			CreatedAt: time.Now(),
+		}
+		if req.Kind == domain.DocumentInvoice {
+			document.Currency = user.Currency
+		}
+		document.ApplyLines(lines)
+
+		layout := documentLayout{
+			document: document,
+			user:     user,
+			club:     club,
+			lines:    lines,
+			billTo:   req.BillTo,
+			notes:    req.Notes,
+			locale:   locale,
+			loc:      loc,
+		}
+		// The document is saved first for its invoice number, which the PDF
+		// shows, and the PDF rendered once the numbering is committed
+		if err := s.documentRepo.Create(c, document); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create document"})
+			return
+		}
+		if err := s.storeDocument(c, &layout); err != nil {
+			log.Printf("Failed to store document %s: %v", document.ID, err)
+			if err := s.documentRepo.Discard(c, document); err != nil {
+				log.Printf("Failed to discard document %s: %v", document.ID, err)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create document"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, document)
+	}
+}
+
+// storeDocument renders the PDF of a saved document and stores it
+func (s *Server) storeDocument(c *gin.Context, layout *documentLayout) error {
+	document := layout.document
+	data, err := layout.render()
+	if err != nil {
+		return err
+	}
+
+	key := domain.DocumentKey(document.ID)
+	if err := s.fileStore.Put(c, key, data); err != nil {
+		return err
+	}
+	if err := s.documentRepo.SetFile(c, document.ID, key, int64(len(data))); err != nil {
+		if err := s.fileStore.Delete(c, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to delete orphaned document %s: %v", key, err)
+		}
+		return err
+	}
+	document.StorageKey = key
+	document.Size = int64(len(data))
+	return nil
+}
+
+// handleGetDocuments lists the user's documents, newest first, optionally
+// only those of one ?kind=
+func (s *Server) handleGetDocuments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset, ok := pagination(c)
+		if !ok {
+			return
+		}
+		kind := c.Query("kind")
+		if kind != "" && kind != domain.DocumentTimesheet && kind != domain.DocumentInvoice {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be timesheet or invoice"})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		documents, total, err := s.documentRepo.GetByUserID(c, userID, kind, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch documents"})
+			return
+		}
+
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+		c.JSON(http.StatusOK, documents)
+	}
+}
+
+// ownedDocument loads the document in the :id parameter, responding with an
+// error unless it belongs to the user
+func (s *Server) ownedDocument(c *gin.Context) (*domain.Document, bool) {
+	documentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return nil, false
+	}
+
+	document, err := s.documentRepo.GetByID(c, documentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch document"})
+		return nil, false
+	}
+	if document == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, false
+	}
+
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	if document.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return document, true
+}
+
+func (s *Server) handleGetDocument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		document, ok := s.ownedDocument(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, document)
+	}
+}
+
+// handleGetDocumentPDF downloads the PDF of a document as it was generated
+func (s *Server) handleGetDocumentPDF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		document, ok := s.ownedDocument(c)
+		if !ok {
+			return
+		}
+
+		data, err := s.fileStore.Get(c, document.StorageKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document file not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read document"})
+			return
+		}
+
+		filename := document.Kind + "-" + document.CreatedAt.Format("2006-01-02") + ".pdf"
+		if document.Number != "" {
+			filename = document.Kind + "-" + document.Number + ".pdf"
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "application/pdf", data)
+	}
+}
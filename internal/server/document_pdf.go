@@ -0,0 +1,203 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/export"
+	"github.com/ZigaoWang/zebra-server/internal/pdf"
+)
+
+// Page layout of documents in points
+const (
+	docMargin     = 50.0
+	docRight      = pdf.PageWidth - docMargin
+	docBottom     = pdf.PageHeight - 60
+	docRowHeight  = 15.0
+	docFontSize   = 9.0
+	docMaxAddress = 6 // lines of the bill to address
+)
+
+// documentLayout renders a timesheet or invoice as PDF
+type documentLayout struct {
+	document *domain.Document
+	user     *domain.User
+	club     *domain.Club // issuer of club documents
+	lines    []domain.DocumentLine
+	billTo   string
+	notes    string
+	locale   export.Locale
+	loc      *time.Location
+}
+
+// docColumn is a column of the line item table
+type docColumn struct {
+	header string
+	x      float64 // left edge, or right edge of right aligned columns
+	width  float64
+	right  bool
+}
+
+func (l *documentLayout) invoice() bool {
+	return l.document.Kind == domain.DocumentInvoice
+}
+
+func (l *documentLayout) columns() []docColumn {
+	if l.invoice() {
+		return []docColumn{
+			{"Date", docMargin, 65, false},
+			{"Project", docMargin + 70, 100, false},
+			{"Description", docMargin + 175, 140, false},
+			{"Hours", docMargin + 360, 40, true},
+			{"Rate", docMargin + 425, 60, true},
+			{"Amount", docRight, 70, true},
+		}
+	}
+	return []docColumn{
+		{"Date", docMargin, 65, false},
+		{"Project", docMargin + 70, 120, false},
+		{"Description", docMargin + 195, 240, false},
+		{"Hours", docRight, 50, true},
+	}
+}
+
+func (l *documentLayout) hours(seconds int64) string {
+	return strings.Replace(strconv.FormatFloat(float64(seconds)/3600, 'f', 2, 64), ".", l.locale.Decimal, 1)
+}
+
+// money formats minor units with two decimals, which fits all common
+// currencies, followed by the currency code
+func (l *documentLayout) money(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d%s%02d %s", sign, amount/100, l.locale.Decimal, amount%100, l.document.Currency)
+}
+
+func (l *documentLayout) date(t time.Time) string {
+	return t.In(l.loc).Format(l.locale.DateLayout)
+}
+
+func (l *documentLayout) render() ([]byte, error) {
+	doc := l.document
+	heading, title := "Timesheet", "Timesheet"
+	if l.invoice() {
+		heading, title = "Invoice", "Invoice "+doc.Number
+	}
+	d := pdf.New(title)
+	d.AddPage()
+
+	// Heading with the number and date on the right
+	d.Text(pdf.Bold, 20, docMargin, 70, heading)
+	if l.invoice() {
+		d.TextRight(pdf.Bold, 11, docRight, 62, doc.Number)
+	}
+	d.TextRight(pdf.Regular, docFontSize, docRight, 76, "Date: "+l.date(doc.CreatedAt))
+
+	// Issuer and recipient
+	y := 110.0
+	d.Text(pdf.Bold, docFontSize, docMargin, y, "From")
+	from := []string{l.user.Name, l.user.Email}
+	if l.club != nil {
+		from = append([]string{l.club.Name}, from...)
+	}
+	for i, line := range from {
+		d.Text(pdf.Regular, docFontSize, docMargin, y+float64(i+1)*12, line)
+	}
+	address := strings.Split(strings.TrimSpace(l.billTo), "\n")
+	if len(address) > docMaxAddress {
+		address = address[:docMaxAddress]
+	}
+	if l.billTo != "" {
+		d.Text(pdf.Bold, docFontSize, 300, y, "Bill to")
+		for i, line := range address {
+			d.Text(pdf.Regular, docFontSize, 300, y+float64(i+1)*12, pdf.Fit(pdf.Regular, docFontSize, docRight-300, line))
+		}
+	}
+	rows := max(len(from), len(address))
+	y += float64(rows+2) * 12
+
+	period := fmt.Sprintf("Period: %s - %s (%s)", l.date(doc.From), l.date(doc.To.Add(-time.Second)), doc.Timezone)
+	d.Text(pdf.Regular, docFontSize, docMargin, y, period)
+	y += 25
+
+	// Line items, repeating the header on every page
+	columns := l.columns()
+	header := func() {
+		for _, column := range columns {
+			if column.right {
+				d.TextRight(pdf.Bold, docFontSize, column.x, y, column.header)
+			} else {
+				d.Text(pdf.Bold, docFontSize, column.x, y, column.header)
+			}
+		}
+		d.Line(docMargin, y+4, docRight, y+4, 0.5)
+		y += docRowHeight + 2
+	}
+	header()
+
+	for _, line := range l.lines {
+		if y > docBottom {
+			d.AddPage()
+			y = 60
+			header()
+		}
+		cells := []string{l.date(line.Date), line.ProjectName, line.Description, l.hours(line.Duration)}
+		if l.invoice() {
+			cells = append(cells, l.money(line.Rate), l.money(line.Amount))
+		}
+		for i, column := range columns {
+			text := pdf.Fit(pdf.Regular, docFontSize, column.width, cells[i])
+			if column.right {
+				d.TextRight(pdf.Regular, docFontSize, column.x, y, text)
+			} else {
+				d.Text(pdf.Regular, docFontSize, column.x, y, text)
+			}
+		}
+		y += docRowHeight
+	}
+
+	// Totals
+	totals := [][2]string{{"Total hours", l.hours(doc.Duration)}}
+	if l.invoice() {
+		totals = append(totals,
+			[2]string{"Subtotal", l.money(doc.Subtotal)},
+			[2]string{"Tax " + strconv.FormatFloat(doc.TaxRate, 'f', -1, 64) + "%", l.money(doc.Tax)},
+			[2]string{"Total", l.money(doc.Total)},
+		)
+	}
+	if y+float64(len(totals))*docRowHeight > docBottom {
+		d.AddPage()
+		y = 60
+	}
+	d.Line(docMargin, y-10, docRight, y-10, 0.5)
+	y += 4
+	for i, total := range totals {
+		font := pdf.Regular
+		if i == len(totals)-1 {
+			font = pdf.Bold
+		}
+		d.TextRight(font, docFontSize, docRight-110, y, total[0])
+		d.TextRight(font, docFontSize, docRight, y, total[1])
+		y += docRowHeight
+	}
+
+	// Notes
+	if notes := strings.TrimSpace(l.notes); notes != "" {
+		y += docRowHeight
+		for _, line := range strings.Split(notes, "\n") {
+			if y > docBottom {
+				d.AddPage()
+				y = 60
+			}
+			d.Text(pdf.Regular, docFontSize, docMargin, y, pdf.Fit(pdf.Regular, docFontSize, docRight-docMargin, line))
+			y += 12
+		}
+	}
+
+	return d.Bytes()
+}
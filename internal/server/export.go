@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/export"
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushRows is how many rows are written between flushes to the client
+const exportFlushRows = 100
+
+// handleExportSessions downloads one row per session as
+// ?format=csv (default), xlsx or json. It takes the report filters, a
+// comma separated ?columns= list and a ?locale= such as de or en-US for
+// CSV numbers and dates.
+func (s *Server) handleExportSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, ok := export.Formats[c.DefaultQuery("format", export.FormatCSV)]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or json"})
+			return
+		}
+
+		keys := export.DefaultColumns
+		if v := c.Query("columns"); v != "" {
+			keys = strings.Split(v, ",")
+			for i := range keys {
+				keys[i] = strings.TrimSpace(keys[i])
+			}
+		}
+		columns, err := export.SelectColumns(keys)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		locale, ok := export.LookupLocale(c.Query("locale"))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+			return
+		}
+
+		filter, ok := s.reportFilter(c)
+		if !ok {
+			return
+		}
+		loc, _ := loadTimezone(filter.Timezone)
+
+		// Headers are sent before the rows are read, so failures part way
+		// through can only be logged
+		filename := fmt.Sprintf("sessions-%s-%s.%s",
+			filter.From.In(loc).Format("2006-01-02"),
+			filter.To.Add(-time.Second).In(loc).Format("2006-01-02"),
+			format.Extension)
+		c.Header("Content-Type", format.ContentType)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+
+		w := format.New(c.Writer, locale)
+		if err := w.WriteHeader(columns); err != nil {
+			log.Printf("Failed to write export: %v", err)
+			return
+		}
+
+		rows := 0
+		err = s.reportRepo.ExportSessions(c, filter, func(row *domain.ExportRow) error {
+			if err := w.WriteRow(export.Values(columns, row, loc)); err != nil {
+				return err
+			}
+			rows++
+			if rows%exportFlushRows == 0 {
+				c.Writer.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to export sessions: %v", err)
+			return
+		}
+		if err := w.Close(); err != nil {
+			log.Printf("Failed to finish export: %v", err)
+		}
+	}
+}
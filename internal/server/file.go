@@ -4,7 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
-	
+
+	"github.com/ZigaoWang/zebra-server/internal/audio"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -30,13 +31,19 @@ func (s *Server) handleGetFile() gin.HandlerFunc {
 			return
 		}
 
-		if file == nil || file.Data == nil || len(file.Data) == 0 {
+		if file == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+
+		data, err := s.fileData(c, file)
+		if err != nil || len(data) == 0 {
 			fmt.Printf("File data is empty for ID: %s\n", fileID.String())
 			c.JSON(http.StatusNotFound, gin.H{"error": "File data not found"})
 			return
 		}
 
-		fmt.Printf("Successfully retrieved file: %s, size: %d bytes\n", file.Name, len(file.Data))
+		fmt.Printf("Successfully retrieved file: %s, size: %d bytes\n", file.Name, len(data))
 
 		// Set content type and other headers
 		contentType := file.Type
@@ -55,7 +62,7 @@ func (s *Server) handleGetFile() gin.HandlerFunc {
 		c.Header("Cache-Control", "public, max-age=31536000")
 
 		// Serve the file data
-		c.Data(http.StatusOK, contentType, file.Data)
+		c.Data(http.StatusOK, contentType, data)
 	}
 }
 
@@ -94,12 +101,13 @@ func (s *Server) handleGetAudio() gin.HandlerFunc {
 			c.Header("Access-Control-Allow-Origin", origin)
 			c.Header("Access-Control-Allow-Credentials", "true")
 		}
-		c.Header("Content-Type", "audio/mpeg")
-		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=audio_%s.mp3", recordID))
+		contentType := audio.ContentType(record.AudioFormat)
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=audio_%s.%s", recordID, audio.Extension(record.AudioFormat)))
 		c.Header("Cache-Control", "public, max-age=31536000")
 
 		// Serve the audio data
 		reader := bytes.NewReader(record.AudioData)
-		c.DataFromReader(http.StatusOK, int64(len(record.AudioData)), "audio/mpeg", reader, nil)
+		c.DataFromReader(http.StatusOK, int64(len(record.AudioData)), contentType, reader, nil)
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/ZigaoWang/zebra-server/internal/audio"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -46,13 +47,14 @@ func (s *Server) RegisterFileRoutes(router *gin.Engine) {
 			return
 		}
 
-		if file.Data == nil || len(file.Data) == 0 {
+		data, err := s.fileData(c, file)
+		if err != nil || len(data) == 0 {
 			fmt.Printf("File data is empty for ID: %s\n", fileID.String())
 			c.JSON(http.StatusNotFound, gin.H{"error": "File data not found"})
 			return
 		}
 
-		fmt.Printf("Successfully retrieved file: %s, size: %d bytes\n", file.Name, len(file.Data))
+		fmt.Printf("Successfully retrieved file: %s, size: %d bytes\n", file.Name, len(data))
 
 		// Set content type and other headers
 		contentType := file.Type
@@ -65,7 +67,7 @@ func (s *Server) RegisterFileRoutes(router *gin.Engine) {
 		c.Header("Cache-Control", "public, max-age=31536000")
 
 		// Serve the file data
-		c.Data(http.StatusOK, contentType, file.Data)
+		c.Data(http.StatusOK, contentType, data)
 	})
 	
 	// Audio endpoint
@@ -110,13 +112,14 @@ func (s *Server) RegisterFileRoutes(router *gin.Engine) {
 		fmt.Printf("Successfully retrieved audio for record: %s, size: %d bytes\n", recordID.String(), len(record.AudioData))
 
 		// Set content type and other headers
-		c.Header("Content-Type", "audio/mpeg")
-		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=audio_%s.mp3", recordID))
+		contentType := audio.ContentType(record.AudioFormat)
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=audio_%s.%s", recordID, audio.Extension(record.AudioFormat)))
 		c.Header("Cache-Control", "public, max-age=31536000")
 
 		// Serve the audio data
 		reader := bytes.NewReader(record.AudioData)
-		c.DataFromReader(http.StatusOK, int64(len(record.AudioData)), "audio/mpeg", reader, nil)
+		c.DataFromReader(http.StatusOK, int64(len(record.AudioData)), contentType, reader, nil)
 	})
 	
 	fmt.Println("File and audio routes registered")
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateGoalRequest struct {
+	Kind       string     `json:"kind" binding:"required"`   // target or budget
+	Period     string     `json:"period" binding:"required"` // day, week, month or total
+	Seconds    int64      `json:"seconds" binding:"required"`
+	Deadline   *time.Time `json:"deadline"`
+	Thresholds []int      `json:"thresholds"` // percentages, 80 and 100 by default
+}
+
+type UpdateGoalRequest struct {
+	Seconds       *int64     `json:"seconds"`
+	Deadline      *time.Time `json:"deadline"`
+	ClearDeadline bool       `json:"clear_deadline"`
+	Thresholds    []int      `json:"thresholds"`
+}
+
+// handleCreateGoal adds a target or budget to a project. Budgets that are
+// already past a threshold notify right away.
+func (s *Server) handleCreateGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		var req CreateGoalRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		project, ok := s.authorizeProject(c, projectID)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		goal := &domain.Goal{
+			ID:          uuid.New(),
+			ProjectID:   project.ID,
+			UserID:      project.UserID,
+			Kind:        req.Kind,
+			Period:      req.Period,
+			Seconds:     req.Seconds,
+			Deadline:    req.Deadline,
+			Thresholds:  domain.IntArray(req.Thresholds),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			ProjectName: project.Name,
+		}
+		if err := goal.Validate(); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+
+		if err := s.goalRepo.Create(c, goal); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create goal"})
+			return
+		}
+
+		progress, err := s.goalProgress(c, project.UserID, []domain.Goal{*goal})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute goal progress"})
+			return
+		}
+		s.notifyBudgets(c, progress)
+
+		c.JSON(http.StatusCreated, progress[0])
+	}
+}
+
+// handleGetProjectGoals lists a project's goals with their progress
+func (s *Server) handleGetProjectGoals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		project, ok := s.authorizeProject(c, projectID)
+		if !ok {
+			return
+		}
+
+		goals, err := s.goalRepo.GetByUserID(c, project.UserID, []uuid.UUID{project.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goals"})
+			return
+		}
+		progress, err := s.goalProgress(c, project.UserID, goals)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute goal progress"})
+			return
+		}
+
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// handleGetGoals is the goals dashboard: every goal of the user's projects
+// with the time tracked in its current period, in the user's time zone
+func (s *Server) handleGetGoals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		goals, err := s.goalRepo.GetByUserID(c, userID, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goals"})
+			return
+		}
+		progress, err := s.goalProgress(c, userID, goals)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute goal progress"})
+			return
+		}
+
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// handleUpdateGoal changes a goal's amount, deadline or thresholds. Its
+// kind and period are fixed, since notifications already sent refer to them.
+func (s *Server) handleUpdateGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		goal, ok := s.ownedGoal(c)
+		if !ok {
+			return
+		}
+
+		var req UpdateGoalRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.Seconds != nil {
+			goal.Seconds = *req.Seconds
+		}
+		if req.Deadline != nil {
+			goal.Deadline = req.Deadline
+		}
+		if req.ClearDeadline {
+			goal.Deadline = nil
+		}
+		if req.Thresholds != nil {
+			goal.Thresholds = domain.IntArray(req.Thresholds)
+		}
+		if err := goal.Validate(); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+		goal.UpdatedAt = time.Now()
+
+		if err := s.goalRepo.Update(c, goal); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
+			return
+		}
+
+		progress, err := s.goalProgress(c, goal.UserID, []domain.Goal{*goal})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute goal progress"})
+			return
+		}
+		s.notifyBudgets(c, progress)
+
+		c.JSON(http.StatusOK, progress[0])
+	}
+}
+
+func (s *Server) handleDeleteGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		goal, ok := s.ownedGoal(c)
+		if !ok {
+			return
+		}
+
+		if err := s.goalRepo.Delete(c, goal.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goal"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ownedGoal loads the goal in the :id parameter, responding with an error
+// unless it belongs to the user
+func (s *Server) ownedGoal(c *gin.Context) (*domain.Goal, bool) {
+	goalID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return nil, false
+	}
+
+	goal, err := s.goalRepo.GetByID(c, goalID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goal"})
+		return nil, false
+	}
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	if goal == nil || goal.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return nil, false
+	}
+	return goal, true
+}
+
+// goalProgress evaluates goals in the user's time zone
+func (s *Server) goalProgress(c *gin.Context, userID uuid.UUID, goals []domain.Goal) ([]domain.GoalProgress, error) {
+	loc := time.UTC
+	user, err := s.userRepo.GetByID(c, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tz, ok := loadTimezone(user.Timezone); ok {
+		loc = tz
+	}
+
+	now := time.Now()
+	progress := make([]domain.GoalProgress, 0, len(goals))
+	for i := range goals {
+		goal := &goals[i]
+		from, to, _ := goal.PeriodBounds(now, loc)
+		tracked, err := s.goalRepo.Tracked(c, goal.ProjectID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, goal.Progress(tracked, now, loc))
+	}
+	return progress, nil
+}
+
+// attachGoals fills in the goal progress of projects for their responses
+func (s *Server) attachGoals(c *gin.Context, userID uuid.UUID, projects []domain.Project) error {
+	ids := make([]uuid.UUID, len(projects))
+	for i := range projects {
+		ids[i] = projects[i].ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	goals, err := s.goalRepo.GetByUserID(c, userID, ids)
+	if err != nil {
+		return err
+	}
+	progress, err := s.goalProgress(c, userID, goals)
+	if err != nil {
+		return err
+	}
+
+	for i := range projects {
+		for _, p := range progress {
+			if p.ProjectID == projects[i].ID {
+				projects[i].Goals = append(projects[i].Goals, p)
+			}
+		}
+	}
+	return nil
+}
+
+// checkBudgets notifies the user of budget thresholds on the projects that
+// were crossed by tracked time. Failures are logged, since the change that
+// added the time has already been saved.
+func (s *Server) checkBudgets(c *gin.Context, projectIDs ...uuid.UUID) {
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+
+	goals, err := s.goalRepo.GetByUserID(c, userID, projectIDs)
+	if err != nil {
+		log.Printf("Failed to fetch goals for budget check: %v", err)
+		return
+	}
+	budgets := goals[:0]
+	for _, goal := range goals {
+		if goal.Kind == domain.GoalBudget {
+			budgets = append(budgets, goal)
+		}
+	}
+	if len(budgets) == 0 {
+		return
+	}
+
+	progress, err := s.goalProgress(c, userID, budgets)
+	if err != nil {
+		log.Printf("Failed to compute budget progress: %v", err)
+		return
+	}
+	s.notifyBudgets(c, progress)
+}
+
+// notifyBudgets saves a notification for each crossed threshold of the
+// budgets, once per period
+func (s *Server) notifyBudgets(c *gin.Context, progress []domain.GoalProgress) {
+	now := time.Now()
+	for i := range progress {
+		p := &progress[i]
+		if p.Kind != domain.GoalBudget {
+			continue
+		}
+		for _, threshold := range p.CrossedThresholds() {
+			notification := domain.NewBudgetNotification(p, threshold, now)
+			created, err := s.notifyRepo.CreateOnce(c, notification)
+			if err != nil {
+				log.Printf("Failed to save notification %s: %v", notification.Key, err)
+				continue
+			}
+			if created {
+				log.Printf("Budget %s of project %s reached %d%% in %s", p.ID, p.ProjectID, threshold, p.PeriodKey)
+			}
+		}
+	}
+}
+
+// handleGetNotifications lists the user's notifications, newest first, or
+// only unread ones with ?unread=true
+func (s *Server) handleGetNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset, ok := pagination(c)
+		if !ok {
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		notifications, total, err := s.notifyRepo.GetByUserID(c, userID, c.Query("unread") == "true", limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+			return
+		}
+
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+		c.JSON(http.StatusOK, notifications)
+	}
+}
+
+func (s *Server) handleReadNotification() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		if _, err := s.notifyRepo.MarkRead(c, userID, &id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handleReadAllNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		count, err := s.notifyRepo.MarkRead(c, userID, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"read": count})
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ResolveOverlapsRequest struct {
+	Strategy string `json:"strategy"` // trim (default) or split
+}
+
+// checkOverlaps applies the user's overlap policy to a session about to be
+// saved. Under warn the conflicts are attached to the session; under reject
+// a 409 listing them has been written and false is returned.
+func (s *Server) checkOverlaps(c *gin.Context, userID uuid.UUID, session *domain.Session) bool {
+	user, err := s.userRepo.GetByID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return false
+	}
+	if user.OverlapPolicy == domain.OverlapAllow {
+		return true
+	}
+
+	others, err := s.sessionRepo.FindOverlapping(c, userID, session.StartTime, session.EndTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for overlapping sessions"})
+		return false
+	}
+
+	conflicts := session.FindConflicts(others)
+	if len(conflicts) == 0 {
+		return true
+	}
+	if user.OverlapPolicy == domain.OverlapReject {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Session overlaps existing sessions",
+			"conflicts": conflicts,
+		})
+		return false
+	}
+
+	session.Conflicts = conflicts
+	return true
+}
+
+// recordOverlapAdjustments saves revisions for the sessions trimmed or split
+// while resolving overlaps, and for the records moved to the target
+func (s *Server) recordOverlapAdjustments(ctx context.Context, targetID uuid.UUID, others []domain.Session, adjustments []domain.OverlapAdjustment) error {
+	originals := make(map[uuid.UUID]domain.Session, len(others))
+	for _, other := range others {
+		originals[other.ID] = other
+	}
+
+	for _, adj := range adjustments {
+		original, ok := originals[adj.SessionID]
+		if !ok || adj.Action == domain.AdjustUnresolved {
+			continue
+		}
+
+		adjusted := original
+		adjusted.StartTime = adj.StartTime
+		adjusted.EndTime = adj.EndTime
+		adjusted.Duration = adj.Duration
+		if err := s.recordChange(ctx, domain.EntitySession, adjusted.ID, domain.RevisionUpdate, original.Snapshot(), adjusted.Snapshot()); err != nil {
+			return err
+		}
+
+		if adj.NewSessionID != nil {
+			part, err := s.sessionRepo.GetByID(ctx, *adj.NewSessionID)
+			if err != nil {
+				return err
+			}
+			if err := s.recordChange(ctx, domain.EntitySession, part.ID, domain.RevisionCreate, nil, part.Snapshot()); err != nil {
+				return err
+			}
+			if err := s.recordRecordsMoved(ctx, part.Records, original.ID, part.ID); err != nil {
+				return err
+			}
+		}
+
+		var moved []domain.Record
+		for _, id := range adj.MovedRecordIDs {
+			record, err := s.sessionRepo.GetRecordByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				moved = append(moved, *record)
+			}
+		}
+		if err := s.recordRecordsMoved(ctx, moved, original.ID, targetID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleResolveOverlaps keeps a session as it is and trims or splits every
+// other session of the user that overlaps it
+func (s *Server) handleResolveOverlaps() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResolveOverlapsRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if req.Strategy == "" {
+			req.Strategy = domain.ResolveTrim
+		}
+		if req.Strategy != domain.ResolveTrim && req.Strategy != domain.ResolveSplit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "strategy must be trim or split"})
+			return
+		}
+
+		session, ok := s.authorizeSession(c)
+		if !ok {
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		others, err := s.sessionRepo.FindOverlapping(c, userID, session.StartTime, session.EndTime)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for overlapping sessions"})
+			return
+		}
+
+		adjustments := domain.PlanOverlapResolution(*session, others, req.Strategy)
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.ApplyOverlapAdjustments(ctx, session.ID, adjustments); err != nil {
+				return err
+			}
+			return s.recordOverlapAdjustments(ctx, session.ID, others, adjustments)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve overlapping sessions"})
+			return
+		}
+
+		if adjustments == nil {
+			adjustments = []domain.OverlapAdjustment{}
+		}
+		c.JSON(http.StatusOK, gin.H{"adjustments": adjustments})
+	}
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultPlanDays is the number of days from today listed when no from/to
+// is given
+const defaultPlanDays = 7
+
+type CreatePlanRequest struct {
+	ProjectID uuid.UUID `json:"project_id" binding:"required"`
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+	Note      string    `json:"note"`
+}
+
+type UpdatePlanRequest struct {
+	ProjectID *uuid.UUID `json:"project_id"`
+	StartTime *time.Time `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	Note      *string    `json:"note"`
+}
+
+// handleCreatePlan schedules a block of time on a project
+func (s *Server) handleCreatePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreatePlanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		project, ok := s.authorizeProject(c, req.ProjectID)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		block := &domain.PlannedBlock{
+			ID:          uuid.New(),
+			UserID:      project.UserID,
+			ProjectID:   project.ID,
+			StartTime:   req.StartTime,
+			EndTime:     req.EndTime,
+			Note:        req.Note,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			ProjectName: project.Name,
+		}
+		if err := block.Validate(now, true); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+
+		if err := s.planRepo.Create(c, block); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create planned block"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, block)
+	}
+}
+
+// handleGetPlans lists the blocks overlapping ?from= and ?to=, which are
+// dates or RFC 3339 times and default to the coming week
+func (s *Server) handleGetPlans() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		loc, ok := s.requestLocation(c, userID)
+		if !ok {
+			return
+		}
+
+		from := domain.StartOfDay(time.Now(), loc)
+		if v := c.Query("from"); v != "" {
+			if from, ok = parseReportTime(v, loc, false); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+				return
+			}
+		}
+		to := from.AddDate(0, 0, defaultPlanDays)
+		if v := c.Query("to"); v != "" {
+			if to, ok = parseReportTime(v, loc, true); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+				return
+			}
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+			return
+		}
+
+		blocks, err := s.planRepo.GetByUserID(c, userID, from, to, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch planned blocks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, blocks)
+	}
+}
+
+// handleUpdatePlan moves, resizes or reassigns a block. Blocks may be
+// changed after they are over, to correct the plan that was made.
+func (s *Server) handleUpdatePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		block, ok := s.ownedPlan(c)
+		if !ok {
+			return
+		}
+
+		var req UpdatePlanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.ProjectID != nil && *req.ProjectID != block.ProjectID {
+			project, ok := s.authorizeProject(c, *req.ProjectID)
+			if !ok {
+				return
+			}
+			block.ProjectID = project.ID
+			block.ProjectName = project.Name
+		}
+		if req.StartTime != nil {
+			block.StartTime = *req.StartTime
+		}
+		if req.EndTime != nil {
+			block.EndTime = *req.EndTime
+		}
+		if req.Note != nil {
+			block.Note = *req.Note
+		}
+		if err := block.Validate(time.Now(), false); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+		block.UpdatedAt = time.Now()
+
+		if err := s.planRepo.Update(c, block); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update planned block"})
+			return
+		}
+
+		c.JSON(http.StatusOK, block)
+	}
+}
+
+func (s *Server) handleDeletePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		block, ok := s.ownedPlan(c)
+		if !ok {
+			return
+		}
+
+		if err := s.planRepo.Delete(c, block.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete planned block"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleComparePlans reports planned against tracked time for the blocks
+// in the report range, per block and per project and day, with whether
+// each plan was skipped, partially done, done or exceeded. Tracked time is
+// the overlap of the project's sessions with the block or day.
+func (s *Server) handleComparePlans() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, ok := s.reportFilter(c)
+		if !ok {
+			return
+		}
+		loc, _ := loadTimezone(filter.Timezone)
+
+		blocks, err := s.planRepo.GetByUserID(c, filter.UserID, filter.From, filter.To, filter.ProjectIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch planned blocks"})
+			return
+		}
+
+		// Sessions are needed for the whole of every day a block touches
+		var sessions []domain.Session
+		if len(blocks) > 0 {
+			start := domain.StartOfDay(blocks[0].StartTime, loc)
+			end := start
+			for _, block := range blocks {
+				if dayEnd := domain.StartOfDay(block.EndTime, loc).AddDate(0, 0, 1); dayEnd.After(end) {
+					end = dayEnd
+				}
+			}
+			sessions, err = s.sessionRepo.FindOverlapping(c, filter.UserID, start, end)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, domain.ComparePlans(blocks, sessions, filter.From, filter.To, loc, time.Now()))
+	}
+}
+
+// ownedPlan loads the block in the :id parameter, responding with an error
+// unless it belongs to the user
+func (s *Server) ownedPlan(c *gin.Context) (*domain.PlannedBlock, bool) {
+	blockID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid planned block ID"})
+		return nil, false
+	}
+
+	block, err := s.planRepo.GetByID(c, blockID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch planned block"})
+		return nil, false
+	}
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	if block == nil || block.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Planned block not found"})
+		return nil, false
+	}
+	return block, true
+}
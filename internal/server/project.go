@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -10,15 +11,26 @@ import (
 )
 
 type CreateProjectRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
+	Name            string `json:"name" binding:"required"`
+	Description     string `json:"description"`
+	RoundingMode    string `json:"rounding_mode"`
+	RoundingMinutes int    `json:"rounding_minutes"`
+	HourlyRate      *int64 `json:"hourly_rate"` // minor units per hour
 }
 
 type UpdateProjectRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
+	Name            string  `json:"name" binding:"required"`
+	Description     string  `json:"description"`
+	RoundingMode    *string `json:"rounding_mode"`
+	RoundingMinutes *int    `json:"rounding_minutes"`
+	HourlyRate      *int64  `json:"hourly_rate"`
+	ClearHourlyRate bool    `json:"clear_hourly_rate"` // fall back to the user's rate
 }
 
+const invalidRoundingMessage = "rounding_mode must be nearest, up or down with rounding_minutes of 1, 5, 6 or 15, or empty with no rounding_minutes"
+
+const invalidRateMessage = "hourly_rate must not be negative"
+
 func (s *Server) handleCreateProject() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CreateProjectRequest
@@ -27,17 +39,35 @@ func (s *Server) handleCreateProject() gin.HandlerFunc {
 			return
 		}
 
-		userID, _ := uuid.Parse(c.GetString("user_id"))
-		project := &domain.Project{
-			ID:          uuid.New(),
-			UserID:      userID,
-			Name:        req.Name,
-			Description: req.Description,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+		if !domain.ValidRounding(req.RoundingMode, req.RoundingMinutes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalidRoundingMessage})
+			return
+		}
+		if req.HourlyRate != nil && *req.HourlyRate < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalidRateMessage})
+			return
 		}
 
-		if err := s.projectRepo.Create(c, project); err != nil {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		project := &domain.Project{
+			ID:              uuid.New(),
+			UserID:          userID,
+			Name:            req.Name,
+			Description:     req.Description,
+			RoundingMode:    req.RoundingMode,
+			RoundingMinutes: req.RoundingMinutes,
+			HourlyRate:      req.HourlyRate,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+
+		err := s.transaction(c, func(ctx context.Context) error {
+			if err := s.projectRepo.Create(ctx, project); err != nil {
+				return err
+			}
+			return s.recordChange(ctx, domain.EntityProject, project.ID, domain.RevisionCreate, nil, project.Snapshot())
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
 			return
 		}
@@ -56,6 +86,14 @@ func (s *Server) handleGetProjects() gin.HandlerFunc {
 			return
 		}
 
+		for i := range projects {
+			projects[i].ApplyRounding(projects[i].Sessions)
+		}
+		if err := s.attachGoals(c, userID, projects); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute goal progress"})
+			return
+		}
+
 		c.JSON(http.StatusOK, projects)
 	}
 }
@@ -87,6 +125,10 @@ func (s *Server) handleGetProject() gin.HandlerFunc {
 		}
 
 		// Project already includes sessions due to preloading
+		project.ApplyRounding(project.Sessions)
+		if !s.attachProjectGoals(c, project) {
+			return
+		}
 		c.JSON(http.StatusOK, project)
 	}
 }
@@ -118,19 +160,62 @@ func (s *Server) handleUpdateProject() gin.HandlerFunc {
 			return
 		}
 
+		before := project.Snapshot()
 		project.Name = req.Name
 		project.Description = req.Description
+		if req.RoundingMode != nil {
+			project.RoundingMode = *req.RoundingMode
+		}
+		if req.RoundingMinutes != nil {
+			project.RoundingMinutes = *req.RoundingMinutes
+		}
+		if !domain.ValidRounding(project.RoundingMode, project.RoundingMinutes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalidRoundingMessage})
+			return
+		}
+		if req.HourlyRate != nil {
+			if *req.HourlyRate < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": invalidRateMessage})
+				return
+			}
+			project.HourlyRate = req.HourlyRate
+		}
+		if req.ClearHourlyRate {
+			project.HourlyRate = nil
+		}
 		project.UpdatedAt = time.Now()
 
-		if err := s.projectRepo.Update(c, project); err != nil {
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.projectRepo.Update(ctx, project); err != nil {
+				return err
+			}
+			return s.recordChange(ctx, domain.EntityProject, project.ID, domain.RevisionUpdate, before, project.Snapshot())
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
 			return
 		}
 
+		project.ApplyRounding(project.Sessions)
+		if !s.attachProjectGoals(c, project) {
+			return
+		}
 		c.JSON(http.StatusOK, project)
 	}
 }
 
+// attachProjectGoals fills in one project's goal progress, writing the
+// error response on failure
+func (s *Server) attachProjectGoals(c *gin.Context, project *domain.Project) bool {
+	projects := []domain.Project{*project}
+	if err := s.attachGoals(c, project.UserID, projects); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute goal progress"})
+		return false
+	}
+	project.Goals = projects[0].Goals
+	return true
+}
+
 func (s *Server) handleDeleteProject() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		projectID, err := uuid.Parse(c.Param("id"))
@@ -152,7 +237,13 @@ func (s *Server) handleDeleteProject() gin.HandlerFunc {
 			return
 		}
 
-		if err := s.projectRepo.Delete(c, projectID); err != nil {
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.projectRepo.Delete(ctx, projectID); err != nil {
+				return err
+			}
+			return s.recordChange(ctx, domain.EntityProject, project.ID, domain.RevisionDelete, project.Snapshot(), nil)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
 			return
 		}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultReportDays is the number of days up to today reported when no
+// from/to is given
+const defaultReportDays = 30
+
+// loadTimezone loads an IANA time zone. The empty name and Local are
+// rejected because the database does not know them.
+func loadTimezone(name string) (*time.Location, bool) {
+	if name == "" || name == "Local" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(name)
+	return loc, err == nil
+}
+
+// requestLocation loads the time zone in ?tz=, or the user's, writing the
+// error response if it is invalid
+func (s *Server) requestLocation(c *gin.Context, userID uuid.UUID) (*time.Location, bool) {
+	tz := c.Query("tz")
+	if tz == "" {
+		user, err := s.userRepo.GetByID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+			return nil, false
+		}
+		tz = user.Timezone
+	}
+	loc, ok := loadTimezone(tz)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tz must be an IANA time zone such as Europe/Berlin"})
+		return nil, false
+	}
+	return loc, true
+}
+
+// parseReportTime reads a query parameter as an RFC 3339 time or as a date
+// in loc. Dates given as to are inclusive, so the end of that day is used.
+func parseReportTime(value string, loc *time.Location, end bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, true
+}
+
+// reportFilter reads the filters shared by reports and exports: from and to
+// as dates or RFC 3339 times, tz, and any number of project_id and tag
+// parameters. The time zone defaults to the user's.
+func (s *Server) reportFilter(c *gin.Context) (repository.ReportFilter, bool) {
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	filter := repository.ReportFilter{UserID: userID}
+
+	loc, ok := s.requestLocation(c, userID)
+	if !ok {
+		return filter, false
+	}
+	filter.Timezone = loc.String()
+
+	today := domain.StartOfDay(time.Now(), loc)
+	filter.To = today.AddDate(0, 0, 1)
+	if v := c.Query("to"); v != "" {
+		if filter.To, ok = parseReportTime(v, loc, true); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return filter, false
+		}
+	}
+	filter.From = filter.To.AddDate(0, 0, -defaultReportDays)
+	if v := c.Query("from"); v != "" {
+		if filter.From, ok = parseReportTime(v, loc, false); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return filter, false
+		}
+	}
+	if !filter.From.Before(filter.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return filter, false
+	}
+
+	for _, v := range c.QueryArray("project_id") {
+		projectID, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID", "project_id": v})
+			return filter, false
+		}
+		filter.ProjectIDs = append(filter.ProjectIDs, projectID)
+	}
+	filter.Tags = normalizeTags(c.QueryArray("tag"))
+
+	return filter, true
+}
+
+// handleGetReportSummary totals the user's tracked time, grouped by
+// ?group_by=project (default), day, week, month or tag
+func (s *Server) handleGetReportSummary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupBy := c.DefaultQuery("group_by", domain.GroupByProject)
+		if !domain.ValidReportGrouping(groupBy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be project, day, week, month or tag"})
+			return
+		}
+
+		filter, ok := s.reportFilter(c)
+		if !ok {
+			return
+		}
+
+		summary, err := s.reportRepo.Summary(c, filter, groupBy)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, summary)
+	}
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RevertRequest struct {
+	RevisionID uuid.UUID `json:"revision_id" binding:"required"`
+}
+
+// actorID returns the user making the request, read from the gin context
+// ctx is or derives from, or nil outside of requests
+func actorID(ctx context.Context) *uuid.UUID {
+	value, _ := ctx.Value("user_id").(string)
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return nil
+	}
+	return &userID
+}
+
+// transaction runs a change and the revisions recording it in one
+// transaction, so there is no change without its history
+func (s *Server) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.transactor.WithinTransaction(ctx, fn)
+}
+
+// saveRevision stores the revision of a change. It is called in the
+// change's transaction, which fails along with it.
+func (s *Server) saveRevision(ctx context.Context, revision *domain.Revision) error {
+	if err := s.revisionRepo.Create(ctx, revision); err != nil {
+		return fmt.Errorf("save %s revision of %s %s: %w", revision.Action, revision.EntityType, revision.EntityID, err)
+	}
+	return nil
+}
+
+// recordChange saves a revision of a change made by the request's user
+func (s *Server) recordChange(ctx context.Context, entityType string, entityID uuid.UUID, action string, before, after domain.JSON) error {
+	return s.saveRevision(ctx, domain.NewRevision(entityType, entityID, action, actorID(ctx), before, after))
+}
+
+// recordSessionCreated saves revisions for a new session and its records
+func (s *Server) recordSessionCreated(ctx context.Context, session *domain.Session) error {
+	if err := s.recordChange(ctx, domain.EntitySession, session.ID, domain.RevisionCreate, nil, session.Snapshot()); err != nil {
+		return err
+	}
+	for i := range session.Records {
+		record := &session.Records[i]
+		if err := s.recordChange(ctx, domain.EntityRecord, record.ID, domain.RevisionCreate, nil, record.Snapshot()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSessions saves new sessions with plain text records and their
+// revisions in one transaction. It returns the indexes of imported sessions
+// that were skipped because their event was already imported.
+func (s *Server) createSessions(ctx context.Context, sessions []domain.Session) ([]int, error) {
+	var skipped []int
+	err := s.transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if skipped, err = s.sessionRepo.CreateMany(ctx, sessions); err != nil {
+			return err
+		}
+		next := 0
+		for i := range sessions {
+			if next < len(skipped) && skipped[next] == i {
+				next++
+				continue
+			}
+			if err := s.recordSessionCreated(ctx, &sessions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return skipped, err
+}
+
+// recordRecordsMoved saves revisions for records moved between sessions
+func (s *Server) recordRecordsMoved(ctx context.Context, records []domain.Record, from, to uuid.UUID) error {
+	for _, record := range records {
+		record.SessionID = from
+		before := record.Snapshot()
+		record.SessionID = to
+		if err := s.recordChange(ctx, domain.EntityRecord, record.ID, domain.RevisionUpdate, before, record.Snapshot()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHistory responds with one page of an entity's revisions, newest
+// first, with the total in X-Total-Count
+func (s *Server) writeHistory(c *gin.Context, entityType string, entityID uuid.UUID) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	revisions, total, err := s.revisionRepo.GetByEntity(c, entityType, entityID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, revisions)
+}
+
+// revertTarget loads the revision named in the request body and checks it
+// belongs to the entity and holds a state to go back to
+func (s *Server) revertTarget(c *gin.Context, entityType string, entityID uuid.UUID) (*domain.Revision, bool) {
+	var req RevertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	revision, err := s.revisionRepo.GetByID(c, req.RevisionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revision"})
+		return nil, false
+	}
+	if revision == nil || revision.EntityType != entityType || revision.EntityID != entityID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return nil, false
+	}
+	if revision.After == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot revert to a deletion; restore the item from the trash instead"})
+		return nil, false
+	}
+	return revision, true
+}
+
+func (s *Server) handleGetProjectHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		if !s.ownsProject(c, projectID) {
+			return
+		}
+
+		s.writeHistory(c, domain.EntityProject, projectID)
+	}
+}
+
+func (s *Server) handleGetSessionHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := s.authorizeSession(c)
+		if !ok {
+			return
+		}
+
+		s.writeHistory(c, domain.EntitySession, session.ID)
+	}
+}
+
+func (s *Server) handleGetRecordHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recordID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+			return
+		}
+
+		if _, ok := s.authorizeRecord(c, recordID); !ok {
+			return
+		}
+
+		s.writeHistory(c, domain.EntityRecord, recordID)
+	}
+}
+
+// handleRevertSession sets a session's times back to those right after a
+// revision. The revert is itself recorded as a new revision.
+func (s *Server) handleRevertSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := s.authorizeSession(c)
+		if !ok {
+			return
+		}
+
+		revision, ok := s.revertTarget(c, domain.EntitySession, session.ID)
+		if !ok {
+			return
+		}
+
+		before := session.Snapshot()
+		if err := session.RestoreSnapshot(revision.After); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read revision"})
+			return
+		}
+
+		if err := session.Validate(time.Now()); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		if !s.checkOverlaps(c, userID, session) {
+			return
+		}
+		session.UpdatedAt = time.Now()
+
+		err := s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.Update(ctx, session); err != nil {
+				return err
+			}
+			reverted := domain.NewRevision(domain.EntitySession, session.ID, domain.RevisionRevert, actorID(ctx), before, session.Snapshot())
+			reverted.RevertedFrom = &revision.ID
+			return s.saveRevision(ctx, reverted)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+			return
+		}
+		s.checkBudgets(c, session.ProjectID)
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// handleRevertRecord sets a record's text, link and timestamp back to those
+// right after a revision
+func (s *Server) handleRevertRecord() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recordID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+			return
+		}
+
+		record, ok := s.authorizeRecord(c, recordID)
+		if !ok {
+			return
+		}
+
+		revision, ok := s.revertTarget(c, domain.EntityRecord, record.ID)
+		if !ok {
+			return
+		}
+
+		before := record.Snapshot()
+		if err := record.RestoreSnapshot(revision.After); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read revision"})
+			return
+		}
+
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.UpdateRecord(ctx, record); err != nil {
+				return err
+			}
+			reverted := domain.NewRevision(domain.EntityRecord, record.ID, domain.RevisionRevert, actorID(ctx), before, record.Snapshot())
+			reverted.RevertedFrom = &revision.ID
+			return s.saveRevision(ctx, reverted)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update record"})
+			return
+		}
+
+		c.JSON(http.StatusOK, record)
+	}
+}
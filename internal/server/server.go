@@ -1,27 +1,54 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/ZigaoWang/zebra-server/internal/config"
+	"github.com/ZigaoWang/zebra-server/internal/domain"
 	"github.com/ZigaoWang/zebra-server/internal/repository"
 	"github.com/ZigaoWang/zebra-server/internal/repository/postgres"
+	"github.com/ZigaoWang/zebra-server/internal/storage"
+	"github.com/ZigaoWang/zebra-server/internal/transcribe"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Server struct {
-	router      *gin.Engine
-	cfg         *config.Config
-	sessionRepo repository.SessionRepository
-	projectRepo repository.ProjectRepository
-	userRepo    repository.UserRepository
-	workLogRepo repository.WorkLogRepository
+	router       *gin.Engine
+	cfg          *config.Config
+	sessionRepo  repository.SessionRepository
+	projectRepo  repository.ProjectRepository
+	userRepo     repository.UserRepository
+	workLogRepo  repository.WorkLogRepository
+	logEntryRepo repository.LogEntryRepository
+	trashRepo    repository.TrashRepository
+	revisionRepo repository.RevisionRepository
+	reportRepo   repository.ReportRepository
+	documentRepo repository.DocumentRepository
+	goalRepo     repository.GoalRepository
+	notifyRepo   repository.NotificationRepository
+	planRepo     repository.PlanRepository
+
+	clubRepo      repository.ClubRepository
+	challengeRepo repository.ChallengeRepository
+
+	thumbnailRepo repository.ThumbnailRepository
+	blobRepo      repository.BlobRepository
+	usageRepo     repository.StorageUsageRepository
+	transactor    repository.Transactor
+	fileStore     storage.FileStore
+
+	transcriber        transcribe.Transcriber
+	transcriptionQueue chan uuid.UUID
+
+	statsCache *statsCache
 }
 
-func NewServer(cfg *config.Config, db *gorm.DB) *Server {
+func NewServer(cfg *config.Config, db *gorm.DB, fileStore storage.FileStore, transcriber transcribe.Transcriber) *Server {
 	// Set gin mode
 	gin.SetMode(cfg.Server.Mode)
 
@@ -40,12 +67,50 @@ func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 	projectRepo := postgres.NewProjectRepository(db)
 	userRepo := postgres.NewUserRepository(db)
 	workLogRepo := postgres.NewWorkLogRepository(db)
+	logEntryRepo := postgres.NewLogEntryRepository(db)
+	trashRepo := postgres.NewTrashRepository(db)
+	revisionRepo := postgres.NewRevisionRepository(db)
+	reportRepo := postgres.NewReportRepository(db)
+	documentRepo := postgres.NewDocumentRepository(db)
+	goalRepo := postgres.NewGoalRepository(db)
+	notifyRepo := postgres.NewNotificationRepository(db)
+	planRepo := postgres.NewPlanRepository(db)
+	clubRepo := postgres.NewClubRepository(db)
+	challengeRepo := postgres.NewChallengeRepository(db)
+	thumbnailRepo := postgres.NewThumbnailRepository(db)
+	blobRepo := postgres.NewBlobRepository(db)
+	usageRepo := postgres.NewStorageUsageRepository(db)
+	transactor := postgres.NewTransactor(db)
 
 	// Create server instance
 	server.sessionRepo = sessionRepo
 	server.projectRepo = projectRepo
 	server.userRepo = userRepo
 	server.workLogRepo = workLogRepo
+	server.logEntryRepo = logEntryRepo
+	server.trashRepo = trashRepo
+	server.revisionRepo = revisionRepo
+	server.reportRepo = reportRepo
+	server.documentRepo = documentRepo
+	server.goalRepo = goalRepo
+	server.notifyRepo = notifyRepo
+	server.planRepo = planRepo
+	server.clubRepo = clubRepo
+	server.challengeRepo = challengeRepo
+	server.thumbnailRepo = thumbnailRepo
+	server.blobRepo = blobRepo
+	server.usageRepo = usageRepo
+	server.transactor = transactor
+	server.fileStore = fileStore
+	server.statsCache = newStatsCache(time.Duration(cfg.Stats.CacheSeconds) * time.Second)
+
+	// Start the transcription worker
+	if transcriber != nil {
+		server.transcriber = transcriber
+		server.transcriptionQueue = make(chan uuid.UUID, transcriptionQueueSize)
+		go server.runTranscriptionWorker()
+		go server.requeueTranscripts(context.Background())
+	}
 
 	// Setup routes
 	server.setupRoutes()
@@ -75,7 +140,16 @@ func (s *Server) setupRoutes() {
 
 	// Direct file and audio access routes (outside of API group)
 	s.router.GET("/files/:id", s.handleGetFile())
+	s.router.GET("/files/:id/thumbnail", s.handleGetThumbnail())
 	s.router.GET("/audio/:id", s.handleGetAudio())
+	s.router.GET("/audio/:id/waveform", s.handleGetWaveform())
+
+	// Calendar feed, authenticated by the secret token in its URL
+	s.router.GET("/calendar/:token", s.handleCalendarFeed())
+
+	// Digest unsubscribe links, authenticated by the secret token in their URL
+	s.router.GET("/digest/unsubscribe/:token", s.handleUnsubscribePage())
+	s.router.POST("/digest/unsubscribe/:token", s.handleUnsubscribe())
 
 	// Protected API v1 group
 	v1 := s.router.Group("/api/v1")
@@ -89,6 +163,11 @@ func (s *Server) setupRoutes() {
 			projects.GET("/:id", s.handleGetProject())
 			projects.PUT("/:id", s.handleUpdateProject())
 			projects.DELETE("/:id", s.handleDeleteProject())
+			projects.GET("/:id/attachments.zip", s.handleGetProjectAttachments())
+			projects.GET("/:id/history", s.handleGetProjectHistory())
+			projects.POST("/:id/calendar/import", s.handleImportCalendar())
+			projects.GET("/:id/goals", s.handleGetProjectGoals())
+			projects.POST("/:id/goals", s.handleCreateGoal())
 
 			// Sessions for a project
 			sessions := projects.Group("/:id/sessions")
@@ -97,9 +176,118 @@ func (s *Server) setupRoutes() {
 				sessions.POST("", s.handleCreateSession())
 				sessions.PUT("/:sessionId", s.handleUpdateSession())
 				sessions.DELETE("/:sessionId", s.handleDeleteSession())
+				sessions.GET("/:sessionId/attachments.zip", s.handleGetSessionAttachments())
+				sessions.POST("/:sessionId/resolve-overlaps", s.handleResolveOverlaps())
+				sessions.POST("/:sessionId/split", s.handleSplitSession())
+				sessions.POST("/:sessionId/move", s.handleMoveSession())
+				sessions.GET("/:sessionId/history", s.handleGetSessionHistory())
+				sessions.POST("/:sessionId/revert", s.handleRevertSession())
+				sessions.POST("/merge", s.handleMergeSessions())
 			}
 		}
 
+		// Users
+		v1.GET("/users/me", s.handleGetProfile())
+		v1.PUT("/users/me", s.handleUpdateProfile())
+		v1.GET("/users/me/usage", s.handleGetUsage())
+
+		// Blobs
+		v1.HEAD("/blobs/:sha256", s.handleHeadBlob())
+
+		// Records
+		records := v1.Group("/records")
+		{
+			records.PUT("/:id", s.handleUpdateRecord())
+			records.DELETE("/:id", s.handleDeleteRecord())
+			records.GET("/:id/history", s.handleGetRecordHistory())
+			records.POST("/:id/revert", s.handleRevertRecord())
+			records.POST("/:id/transcript/retry", s.handleRetryTranscript())
+		}
+
+		// Calendar feed settings
+		v1.GET("/calendar", s.handleGetCalendar())
+		v1.POST("/calendar/token", s.handleRotateCalendarToken())
+		v1.DELETE("/calendar/token", s.handleDisableCalendar())
+
+		// Trash
+		v1.GET("/trash", s.handleGetTrash())
+		v1.POST("/trash/:kind/:id/restore", s.handleRestoreTrash())
+
+		// Timeline of sessions and work logs
+		v1.GET("/timeline", s.handleGetTimeline())
+
+		// Reports
+		reports := v1.Group("/reports")
+		{
+			reports.GET("/summary", s.handleGetReportSummary())
+			reports.GET("/export", s.handleExportSessions())
+		}
+
+		// Timesheets and invoices
+		documents := v1.Group("/documents")
+		{
+			documents.GET("", s.handleGetDocuments())
+			documents.POST("", s.handleCreateDocument())
+			documents.GET("/:id", s.handleGetDocument())
+			documents.GET("/:id/pdf", s.handleGetDocumentPDF())
+		}
+
+		// Goals and budgets
+		goals := v1.Group("/goals")
+		{
+			goals.GET("", s.handleGetGoals())
+			goals.PUT("/:id", s.handleUpdateGoal())
+			goals.DELETE("/:id", s.handleDeleteGoal())
+		}
+
+		// Notifications
+		notifications := v1.Group("/notifications")
+		{
+			notifications.GET("", s.handleGetNotifications())
+			notifications.POST("/read-all", s.handleReadAllNotifications())
+			notifications.POST("/:id/read", s.handleReadNotification())
+		}
+
+		// Planned time blocks
+		plans := v1.Group("/plans")
+		{
+			plans.GET("", s.handleGetPlans())
+			plans.POST("", s.handleCreatePlan())
+			plans.GET("/compare", s.handleComparePlans())
+			plans.PUT("/:id", s.handleUpdatePlan())
+			plans.DELETE("/:id", s.handleDeletePlan())
+		}
+
+		// Clubs, leaderboards and challenges
+		clubs := v1.Group("/clubs")
+		{
+			clubs.GET("", s.handleGetClubs())
+			clubs.POST("", s.handleCreateClub())
+			clubs.POST("/join", s.handleJoinClub())
+			clubs.GET("/:id", s.handleGetClub())
+			clubs.PUT("/:id", s.handleUpdateClub())
+			clubs.DELETE("/:id", s.handleDeleteClub())
+			clubs.POST("/:id/leave", s.handleLeaveClub())
+			clubs.PUT("/:id/membership", s.handleUpdateMembership())
+			clubs.PUT("/:id/members/:user_id", s.handleUpdateMember())
+			clubs.GET("/:id/leaderboard", s.handleGetLeaderboard())
+			clubs.GET("/:id/challenges", s.handleGetChallenges())
+			clubs.POST("/:id/challenges", s.handleCreateChallenge())
+		}
+		challenges := v1.Group("/challenges")
+		{
+			challenges.GET("/:id", s.handleGetChallenge())
+			challenges.DELETE("/:id", s.handleDeleteChallenge())
+			challenges.POST("/:id/join", s.handleJoinChallenge())
+			challenges.POST("/:id/leave", s.handleLeaveChallenge())
+		}
+
+		// Personal statistics
+		v1.GET("/stats/activity", s.handleGetActivityStats())
+
+		// Bulk manual time entry
+		v1.POST("/timesheet", s.handleBulkTimesheet())
+
 		// Work logs
 		logs := v1.Group("/logs")
 		{
@@ -108,6 +296,19 @@ func (s *Server) setupRoutes() {
 			logs.GET("/:id", s.handleGetWorkLog())
 			logs.PUT("/:id", s.handleUpdateWorkLog())
 			logs.DELETE("/:id", s.handleDeleteWorkLog())
+			logs.POST("/:id/pause", s.handleWorkLogTransition(domain.WorkLogPaused))
+			logs.POST("/:id/resume", s.handleWorkLogTransition(domain.WorkLogActive))
+			logs.POST("/:id/complete", s.handleWorkLogTransition(domain.WorkLogCompleted))
+			logs.POST("/:id/convert", s.handleConvertWorkLog())
+
+			// Entries for a work log
+			entries := logs.Group("/:id/entries")
+			{
+				entries.GET("", s.handleGetLogEntries())
+				entries.POST("", s.handleCreateLogEntry())
+				entries.PUT("/:entryId", s.handleUpdateLogEntry())
+				entries.DELETE("/:entryId", s.handleDeleteLogEntry())
+			}
 		}
 	}
 
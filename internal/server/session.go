@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -25,7 +27,16 @@ type RecordDTO struct {
 }
 
 type UpdateSessionRequest struct {
-	Duration int64 `json:"duration" binding:"required"`
+	StartTime *time.Time `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	Duration  *int64     `json:"duration"` // in seconds
+	Tags      *[]string  `json:"tags"`
+}
+
+type UpdateRecordRequest struct {
+	Text      *string    `json:"text"`
+	GitLink   *string    `json:"git_link"`
+	Timestamp *time.Time `json:"timestamp"`
 }
 
 type File struct {
@@ -45,6 +56,11 @@ func (s *Server) handleCreateSession() gin.HandlerFunc {
 			return
 		}
 
+		project, ok := s.authorizeProject(c, projectID)
+		if !ok {
+			return
+		}
+
 		// Parse request body
 		var req domain.Session
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -61,18 +77,84 @@ func (s *Server) handleCreateSession() gin.HandlerFunc {
 			req.StartTime = time.Now().UTC()
 		}
 
-		// Ensure end time is set
+		// Ensure end time is set, from the duration when there is one and
+		// otherwise as a session ending now
 		if req.EndTime.IsZero() {
-			req.EndTime = time.Now().UTC()
+			if req.Duration > 0 {
+				req.EndTime = req.StartTime.Add(time.Duration(req.Duration) * time.Second)
+			} else {
+				req.EndTime = time.Now().UTC()
+			}
+		}
+
+		if err := req.Validate(time.Now()); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+		req.Tags = normalizeTags(req.Tags)
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		if !s.checkOverlaps(c, userID, &req) {
+			return
 		}
 
-		// Create the session
-		err = s.sessionRepo.Create(c, projectID, &req)
+		// Read duration, sample rate and channels of attached audio
+		s.analyzeAudio(&req)
+		s.markTranscriptsPending(&req)
+
+		// Strip image metadata before files are hashed and measured
+		s.prepareImages(&req)
+
+		// Hash uploads and look up referenced blobs for their stored sizes
+		if err := s.resolveFileBlobs(c, userID, &req); err != nil {
+			if errors.Is(err, errUnknownBlob) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to store files: %v", err)})
+			return
+		}
+
+		// Reject uploads that do not fit into the user's storage quota
+		fileBytes, audioBytes := sessionStorageSize(&req)
+		if !s.checkQuota(c, userID, fileBytes+audioBytes) {
+			return
+		}
+
+		// Move file contents into shared blobs
+		if err := s.storeFileBlobs(c, &req); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to store files: %v", err)})
+			return
+		}
+
+		// Create the session, counting its data towards the quota checked
+		// above. The sizes counted are the ones stored.
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.Create(ctx, projectID, &req); err != nil {
+				return err
+			}
+			if err := s.recordSessionCreated(ctx, &req); err != nil {
+				return err
+			}
+			return s.addUsage(ctx, userID, fileBytes, audioBytes)
+		})
+		if errors.Is(err, errQuotaExceeded) {
+			c.JSON(http.StatusInsufficientStorage, gin.H{"error": "Storage quota exceeded"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create session: %v", err)})
 			return
 		}
 
+		s.checkBudgets(c, projectID)
+
+		// Build thumbnails for uploaded images
+		s.processImages(&req)
+		s.processAudio(&req)
+		s.queueTranscripts(&req)
+
+		req.RoundedDuration = project.RoundDuration(req.Duration)
 		c.JSON(http.StatusCreated, req)
 	}
 }
@@ -105,6 +187,7 @@ func (s *Server) handleGetSessions() gin.HandlerFunc {
 			return
 		}
 
+		project.ApplyRounding(sessions)
 		c.JSON(http.StatusOK, sessions)
 	}
 }
@@ -154,14 +237,44 @@ func (s *Server) handleUpdateSession() gin.HandlerFunc {
 			return
 		}
 
-		session.Duration = req.Duration
+		before := session.Snapshot()
+		if req.StartTime != nil {
+			session.StartTime = *req.StartTime
+		}
+		if req.EndTime != nil {
+			session.EndTime = *req.EndTime
+		}
+		if req.Duration != nil {
+			session.Duration = *req.Duration
+		} else if req.StartTime != nil || req.EndTime != nil {
+			session.Duration = int64(session.EndTime.Sub(session.StartTime) / time.Second)
+		}
+		if req.Tags != nil {
+			session.Tags = normalizeTags(*req.Tags)
+		}
+
+		if err := session.Validate(time.Now()); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+		if !s.checkOverlaps(c, userID, session) {
+			return
+		}
 		session.UpdatedAt = time.Now()
 
-		if err := s.sessionRepo.Update(c, session); err != nil {
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.Update(ctx, session); err != nil {
+				return err
+			}
+			return s.recordChange(ctx, domain.EntitySession, session.ID, domain.RevisionUpdate, before, session.Snapshot())
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
 			return
 		}
+		s.checkBudgets(c, project.ID)
 
+		session.RoundedDuration = project.RoundDuration(session.Duration)
 		c.JSON(http.StatusOK, session)
 	}
 }
@@ -205,7 +318,14 @@ func (s *Server) handleDeleteSession() gin.HandlerFunc {
 			return
 		}
 
-		if err := s.sessionRepo.Delete(c, sessionID); err != nil {
+		// Trashed data counts towards storage usage until it is purged
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.Delete(ctx, sessionID); err != nil {
+				return err
+			}
+			return s.recordChange(ctx, domain.EntitySession, session.ID, domain.RevisionDelete, session.Snapshot(), nil)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
 			return
 		}
@@ -213,3 +333,123 @@ func (s *Server) handleDeleteSession() gin.HandlerFunc {
 		c.Status(http.StatusNoContent)
 	}
 }
+
+func (s *Server) handleUpdateRecord() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recordID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+			return
+		}
+
+		var req UpdateRecordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		record, ok := s.authorizeRecord(c, recordID)
+		if !ok {
+			return
+		}
+
+		before := record.Snapshot()
+		if req.Text != nil {
+			record.Text = *req.Text
+		}
+		if req.GitLink != nil {
+			record.GitLink = *req.GitLink
+		}
+		if req.Timestamp != nil {
+			record.Timestamp = *req.Timestamp
+		}
+
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.UpdateRecord(ctx, record); err != nil {
+				return err
+			}
+			return s.recordChange(ctx, domain.EntityRecord, record.ID, domain.RevisionUpdate, before, record.Snapshot())
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update record"})
+			return
+		}
+
+		c.JSON(http.StatusOK, record)
+	}
+}
+
+func (s *Server) handleDeleteRecord() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recordID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+			return
+		}
+
+		record, ok := s.authorizeRecord(c, recordID)
+		if !ok {
+			return
+		}
+
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.DeleteRecord(ctx, recordID); err != nil {
+				return err
+			}
+			return s.recordChange(ctx, domain.EntityRecord, record.ID, domain.RevisionDelete, record.Snapshot(), nil)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete record"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// writeValidationError responds with the invalid fields of a
+// domain.ValidationError, or a plain 400 for other errors
+func writeValidationError(c *gin.Context, err error) {
+	var verr *domain.ValidationError
+	if errors.As(err, &verr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": verr.Fields})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// authorizeRecord loads a record and checks that the project it belongs to
+// is owned by the requesting user. On failure the error response has been
+// written and false is returned.
+func (s *Server) authorizeRecord(c *gin.Context, recordID uuid.UUID) (*domain.Record, bool) {
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+
+	record, err := s.sessionRepo.GetRecordByID(c, recordID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch record"})
+		return nil, false
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return nil, false
+	}
+
+	session, err := s.sessionRepo.GetByID(c, record.SessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return nil, false
+	}
+
+	project, err := s.projectRepo.GetByID(c, session.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+
+	if project.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return record, true
+}
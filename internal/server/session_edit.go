@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SplitSessionRequest struct {
+	At time.Time `json:"at" binding:"required"`
+}
+
+type MergeSessionsRequest struct {
+	SessionIDs []uuid.UUID `json:"session_ids" binding:"required"`
+}
+
+type MoveSessionRequest struct {
+	ProjectID uuid.UUID `json:"project_id" binding:"required"`
+}
+
+// handleSplitSession splits a session in two at a timestamp. Records from
+// that time on move to the second session.
+func (s *Server) handleSplitSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := s.authorizeSession(c)
+		if !ok {
+			return
+		}
+
+		var req SplitSessionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !req.At.After(session.StartTime) || !req.At.Before(session.EndTime) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must lie between the session's start_time and end_time"})
+			return
+		}
+
+		var first, second *domain.Session
+		err := s.transaction(c, func(ctx context.Context) error {
+			part, err := s.sessionRepo.Split(ctx, session.ID, req.At)
+			if err != nil {
+				return err
+			}
+			if first, err = s.sessionRepo.GetByID(ctx, session.ID); err != nil {
+				return err
+			}
+			if second, err = s.sessionRepo.GetByID(ctx, part.ID); err != nil {
+				return err
+			}
+
+			if err := s.recordChange(ctx, domain.EntitySession, first.ID, domain.RevisionUpdate, session.Snapshot(), first.Snapshot()); err != nil {
+				return err
+			}
+			if err := s.recordChange(ctx, domain.EntitySession, second.ID, domain.RevisionCreate, nil, second.Snapshot()); err != nil {
+				return err
+			}
+			return s.recordRecordsMoved(ctx, second.Records, session.ID, second.ID)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to split session"})
+			return
+		}
+
+		c.JSON(http.StatusOK, []*domain.Session{first, second})
+	}
+}
+
+// handleMergeSessions merges sessions of a project that follow each other
+// into the earliest of them
+func (s *Server) handleMergeSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		var req MergeSessionsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ids := make([]uuid.UUID, 0, len(req.SessionIDs))
+		seen := make(map[uuid.UUID]bool, len(req.SessionIDs))
+		for _, id := range req.SessionIDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least two sessions are required"})
+			return
+		}
+
+		if !s.ownsProject(c, projectID) {
+			return
+		}
+
+		originals := make([]*domain.Session, 0, len(ids))
+		for _, id := range ids {
+			session, err := s.sessionRepo.GetByID(c, id)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Session not found", "session_id": id})
+				return
+			}
+			if session.ProjectID != projectID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "session_id": id})
+				return
+			}
+			originals = append(originals, session)
+		}
+
+		var session *domain.Session
+		err = s.transaction(c, func(ctx context.Context) error {
+			merged, err := s.sessionRepo.Merge(ctx, ids)
+			if err != nil {
+				return err
+			}
+			if session, err = s.sessionRepo.GetByID(ctx, merged.ID); err != nil {
+				return err
+			}
+
+			for _, original := range originals {
+				if original.ID == session.ID {
+					if err := s.recordChange(ctx, domain.EntitySession, session.ID, domain.RevisionUpdate, original.Snapshot(), session.Snapshot()); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := s.recordChange(ctx, domain.EntitySession, original.ID, domain.RevisionDelete, original.Snapshot(), nil); err != nil {
+					return err
+				}
+				if err := s.recordRecordsMoved(ctx, original.Records, original.ID, session.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrSessionsNotAdjacent):
+				c.JSON(http.StatusConflict, gin.H{"error": "Sessions must follow each other with no other session of the project between them"})
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge sessions"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// handleMoveSession moves a session with its records and files to another
+// project of the user
+func (s *Server) handleMoveSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := s.authorizeSession(c)
+		if !ok {
+			return
+		}
+
+		var req MoveSessionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.ProjectID == session.ProjectID {
+			c.JSON(http.StatusOK, session)
+			return
+		}
+		if !s.ownsProject(c, req.ProjectID) {
+			return
+		}
+
+		before := session.Snapshot()
+		err := s.transaction(c, func(ctx context.Context) error {
+			if err := s.sessionRepo.Move(ctx, session.ID, req.ProjectID); err != nil {
+				return err
+			}
+			session.ProjectID = req.ProjectID
+			return s.recordChange(ctx, domain.EntitySession, session.ID, domain.RevisionUpdate, before, session.Snapshot())
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move session"})
+			return
+		}
+		s.checkBudgets(c, req.ProjectID)
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// authorizeSession loads the session named by :sessionId and checks it
+// belongs to the project named by :id, which must be owned by the user.
+// On failure the error response has been written and false is returned.
+func (s *Server) authorizeSession(c *gin.Context) (*domain.Session, bool) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return nil, false
+	}
+
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return nil, false
+	}
+
+	if !s.ownsProject(c, projectID) {
+		return nil, false
+	}
+
+	session, err := s.sessionRepo.GetByID(c, sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return nil, false
+	}
+
+	if session.ProjectID != projectID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return session, true
+}
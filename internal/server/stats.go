@@ -0,0 +1,115 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// statsCacheMaxEntries bounds the memory held by cached statistics
+const statsCacheMaxEntries = 10000
+
+// statsCache keeps computed statistics for a while, since they scan all of
+// a user's sessions and are typically fetched on every dashboard visit
+type statsCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]statsCacheEntry
+}
+
+type statsCacheEntry struct {
+	stats   *domain.ActivityStats
+	expires time.Time
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{ttl: ttl, entries: make(map[string]statsCacheEntry)}
+}
+
+func (c *statsCache) get(key string, now time.Time) (*domain.ActivityStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expires) {
+		return nil, false
+	}
+	return entry.stats, true
+}
+
+func (c *statsCache) put(key string, stats *domain.ActivityStats, now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= statsCacheMaxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= statsCacheMaxEntries {
+			c.entries = make(map[string]statsCacheEntry)
+		}
+	}
+	c.entries[key] = statsCacheEntry{stats: stats, expires: now.Add(c.ttl)}
+}
+
+// handleGetActivityStats returns a heatmap of tracked time per day with
+// streaks, the most active hours of the week and the average session
+// length. It covers the year up to today, or the calendar ?year=, in ?tz=,
+// which defaults to the user's time zone. Results are cached for a few
+// minutes, so new sessions may show up late.
+func (s *Server) handleGetActivityStats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		loc, ok := s.requestLocation(c, userID)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		local := now.In(loc)
+		today := domain.StartOfDay(now, loc)
+		first, last := today.AddDate(-1, 0, 1), today
+		if v := c.Query("year"); v != "" {
+			year, err := strconv.Atoi(v)
+			if err != nil || year < 1970 || year > local.Year() {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("year must be between 1970 and %d", local.Year())})
+				return
+			}
+			first = time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+			last = time.Date(year, time.December, 31, 0, 0, 0, 0, loc)
+		}
+
+		from, to := first.Format("2006-01-02"), last.Format("2006-01-02")
+		key := userID.String() + "|" + loc.String() + "|" + from + "|" + to
+		stats, ok := s.statsCache.get(key, now)
+		if !ok {
+			filter := repository.ReportFilter{
+				UserID:   userID,
+				From:     first,
+				To:       last.AddDate(0, 0, 1),
+				Timezone: loc.String(),
+			}
+			data, err := s.reportRepo.Activity(c, filter)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+				return
+			}
+			stats = domain.NewActivityStats(data, from, to, today.Format("2006-01-02"), loc.String(), now)
+			s.statsCache.put(key, stats, now)
+		}
+
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", s.cfg.Stats.CacheSeconds))
+		c.JSON(http.StatusOK, stats)
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/imaging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// prepareImages strips EXIF metadata from uploaded images and records
+// their dimensions. It runs before the files are stored so the content hash
+// is computed over the stripped data.
+func (s *Server) prepareImages(session *domain.Session) {
+	for i := range session.Records {
+		for j := range session.Records[i].Files {
+			file := &session.Records[i].Files[j]
+			if imaging.DetectFormat(file.Data) == "" {
+				continue
+			}
+
+			file.Data = imaging.StripMetadata(file.Data)
+			file.Size = int64(len(file.Data))
+			if width, height, err := imaging.Dimensions(file.Data); err == nil {
+				file.Width, file.Height = width, height
+			}
+		}
+	}
+}
+
+// processImages starts background thumbnail generation for the image files
+// of a newly created session so the upload request does not wait for resizing
+func (s *Server) processImages(session *domain.Session) {
+	var fileIDs []uuid.UUID
+	for _, record := range session.Records {
+		for _, file := range record.Files {
+			if file.Width > 0 || strings.HasPrefix(file.Type, "image/") {
+				fileIDs = append(fileIDs, file.ID)
+			}
+		}
+	}
+	if len(fileIDs) == 0 {
+		return
+	}
+
+	go func() {
+		for _, id := range fileIDs {
+			if err := s.processImageFileSafely(context.Background(), id); err != nil {
+				log.Printf("Failed to process image %s: %v", id, err)
+			}
+		}
+	}()
+}
+
+// processImageFileSafely is processImageFile for background goroutines,
+// which gin's recovery does not cover. A panic is returned as an error.
+func (s *Server) processImageFileSafely(ctx context.Context, fileID uuid.UUID) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.processImageFile(ctx, fileID)
+}
+
+// processImageFile stores a thumbnail of an image file for every configured
+// size, recording the image dimensions if they are not known yet
+func (s *Server) processImageFile(ctx context.Context, fileID uuid.UUID) error {
+	file, err := s.sessionRepo.GetFileByID(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("file not found")
+	}
+
+	data, err := s.fileData(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	format := imaging.DetectFormat(data)
+	if format == "" {
+		return imaging.ErrUnsupportedFormat
+	}
+
+	width, height, err := imaging.Dimensions(data)
+	if err != nil {
+		return err
+	}
+	if file.Width == 0 {
+		if err := s.sessionRepo.UpdateFileDimensions(ctx, file.ID, width, height); err != nil {
+			return err
+		}
+	}
+	if int64(width)*int64(height) > imaging.MaxPixels {
+		// Too large to thumbnail, so clients show the file's icon
+		return imaging.ErrTooLarge
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	for size, edge := range imaging.ThumbnailSizes {
+		thumb, err := imaging.MakeThumbnail(img, format, edge)
+		if err != nil {
+			return err
+		}
+
+		key := fmt.Sprintf("thumbnails/%s/%s", file.ID, size)
+		if err := s.fileStore.Put(ctx, key, thumb.Data); err != nil {
+			return err
+		}
+
+		if err := s.thumbnailRepo.Save(ctx, &domain.Thumbnail{
+			FileID:      file.ID,
+			Size:        size,
+			Width:       thumb.Width,
+			Height:      thumb.Height,
+			ContentType: thumb.ContentType,
+			StorageKey:  key,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleGetThumbnail serves a generated thumbnail of an image file
+func (s *Server) handleGetThumbnail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fileID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID format"})
+			return
+		}
+
+		size := c.DefaultQuery("size", imaging.DefaultThumbnailSize)
+		if _, ok := imaging.ThumbnailSizes[size]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thumbnail size"})
+			return
+		}
+
+		thumbnail, err := s.thumbnailRepo.GetByFileIDAndSize(c, fileID, size)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not available"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thumbnail"})
+			return
+		}
+
+		data, err := s.fileStore.Get(c, thumbnail.StorageKey)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail data not found"})
+			return
+		}
+
+		origin := c.Request.Header.Get("Origin")
+		if origin != "" {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		c.Header("Cache-Control", "public, max-age=31536000")
+
+		c.Data(http.StatusOK, thumbnail.ContentType, data)
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultTimelineRange is the period shown when no from/to is given
+const defaultTimelineRange = 30 * 24 * time.Hour
+
+// Timeline item kinds
+const (
+	TimelineSession = "session"
+	TimelineWorkLog = "work_log"
+)
+
+// TimelineItem is a session or a work log on the unified timeline. Work
+// logs already converted into sessions only appear as their session.
+type TimelineItem struct {
+	Kind      string     `json:"kind"`
+	ID        uuid.UUID  `json:"id"`
+	ProjectID *uuid.UUID `json:"project_id,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	Duration  int64      `json:"duration"` // in seconds
+}
+
+// parseTimeRange reads the from and to query parameters as RFC 3339 times,
+// defaulting to the given period before now
+func parseTimeRange(c *gin.Context, period time.Duration) (from, to time.Time, ok bool) {
+	to = time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 time"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	from = to.Add(-period)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 time"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// handleGetTimeline merges the user's sessions and work logs between
+// ?from= and ?to= into one list ordered by start time
+func (s *Server) handleGetTimeline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to, ok := parseTimeRange(c, defaultTimelineRange)
+		if !ok {
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		sessions, err := s.sessionRepo.GetByUserID(c, userID, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+			return
+		}
+
+		workLogs, _, err := s.workLogRepo.GetByUserID(c, userID, repository.WorkLogFilter{
+			From:        from,
+			To:          to,
+			Unconverted: true,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work logs"})
+			return
+		}
+
+		items := make([]TimelineItem, 0, len(sessions)+len(workLogs))
+		for _, session := range sessions {
+			projectID := session.ProjectID
+			endTime := session.EndTime
+			items = append(items, TimelineItem{
+				Kind:      TimelineSession,
+				ID:        session.ID,
+				ProjectID: &projectID,
+				StartTime: session.StartTime,
+				EndTime:   &endTime,
+				Duration:  session.Duration,
+			})
+		}
+
+		now := time.Now()
+		for _, workLog := range workLogs {
+			item := TimelineItem{
+				Kind:      TimelineWorkLog,
+				ID:        workLog.ID,
+				ProjectID: workLog.ProjectID,
+				Title:     workLog.Title,
+				Status:    workLog.Status,
+				Tags:      workLog.Tags,
+				StartTime: workLog.StartTime,
+				Duration:  int64(workLog.ActiveDuration(now) / time.Second),
+			}
+			if !workLog.EndTime.IsZero() {
+				endTime := workLog.EndTime
+				item.EndTime = &endTime
+			}
+			items = append(items, item)
+		}
+
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].StartTime.Before(items[j].StartTime)
+		})
+
+		c.JSON(http.StatusOK, items)
+	}
+}
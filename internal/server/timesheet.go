@@ -0,0 +1,219 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxTimesheetEntries bounds one bulk request, enough for a week of
+// entries across many projects
+const maxTimesheetEntries = 200
+
+// Timesheet row statuses
+const (
+	TimesheetValid   = "valid"
+	TimesheetCreated = "created"
+	TimesheetInvalid = "invalid"
+)
+
+type TimesheetEntry struct {
+	ProjectID uuid.UUID  `json:"project_id" binding:"required"`
+	StartTime time.Time  `json:"start_time" binding:"required"`
+	EndTime   *time.Time `json:"end_time"`
+	Duration  *int64     `json:"duration"` // in seconds, defaults to end_time - start_time
+	Note      string     `json:"note"`
+	Tags      []string   `json:"tags"`
+}
+
+type TimesheetRequest struct {
+	Entries []TimesheetEntry `json:"entries" binding:"required,dive"`
+}
+
+// TimesheetResult reports what happened to one entry of a bulk request
+type TimesheetResult struct {
+	Index     int                      `json:"index"`
+	Status    string                   `json:"status"`
+	Errors    []domain.FieldError      `json:"errors,omitempty"`
+	Conflicts []domain.SessionConflict `json:"conflicts,omitempty"`
+	Session   *domain.Session          `json:"session,omitempty"`
+}
+
+// handleBulkTimesheet creates manual sessions for many entries at once,
+// typically a week's timesheet. Every entry is validated first; if any is
+// invalid nothing is created and the per-entry results say why. With
+// ?dry_run=true entries are only validated.
+func (s *Server) handleBulkTimesheet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TimesheetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Entries) == 0 || len(req.Entries) > maxTimesheetEntries {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("entries must contain between 1 and %d items", maxTimesheetEntries)})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		user, err := s.userRepo.GetByID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+
+		now := time.Now()
+		projects := make(map[uuid.UUID]*domain.Project)
+		results := make([]TimesheetResult, len(req.Entries))
+		sessions := make([]domain.Session, 0, len(req.Entries))
+		valid := true
+
+		for i, entry := range req.Entries {
+			result := &results[i]
+			result.Index = i
+			result.Status = TimesheetValid
+
+			project := s.timesheetProject(c, projects, entry.ProjectID, userID)
+			if project == nil {
+				result.Errors = append(result.Errors, domain.FieldError{Field: "project_id", Message: "project not found"})
+			}
+
+			session := timesheetSession(entry, now)
+			if err := session.Validate(now); err != nil {
+				var verr *domain.ValidationError
+				if !errors.As(err, &verr) {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				result.Errors = append(result.Errors, verr.Fields...)
+			}
+
+			if len(result.Errors) == 0 && user.OverlapPolicy != domain.OverlapAllow {
+				others, err := s.sessionRepo.FindOverlapping(c, userID, session.StartTime, session.EndTime)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for overlapping sessions"})
+					return
+				}
+				// Entries of the same request must not overlap each other either
+				result.Conflicts = session.FindConflicts(append(others, sessions...))
+				if len(result.Conflicts) > 0 && user.OverlapPolicy == domain.OverlapReject {
+					result.Errors = append(result.Errors, domain.FieldError{Field: "start_time", Message: "overlaps existing sessions"})
+				}
+			}
+
+			if len(result.Errors) > 0 {
+				result.Status = TimesheetInvalid
+				valid = false
+				continue
+			}
+
+			session.RoundedDuration = project.RoundDuration(session.Duration)
+			session.Conflicts = result.Conflicts
+			sessions = append(sessions, session)
+		}
+
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "results": results})
+			return
+		}
+
+		dryRun := c.Query("dry_run") == "true"
+		if !dryRun {
+			if _, err := s.createSessions(c, sessions); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sessions"})
+				return
+			}
+			s.checkBudgets(c, timesheetProjectIDs(sessions)...)
+		}
+
+		for i := range results {
+			if !dryRun {
+				results[i].Status = TimesheetCreated
+			}
+			results[i].Session = &sessions[i]
+		}
+
+		if dryRun {
+			c.JSON(http.StatusOK, gin.H{"results": results})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"results": results})
+	}
+}
+
+// timesheetProjectIDs returns the distinct projects of the sessions
+func timesheetProjectIDs(sessions []domain.Session) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+	for _, session := range sessions {
+		if !seen[session.ProjectID] {
+			seen[session.ProjectID] = true
+			ids = append(ids, session.ProjectID)
+		}
+	}
+	return ids
+}
+
+// timesheetSession builds the manual session for a timesheet entry. A note
+// becomes the session's only record.
+func timesheetSession(entry TimesheetEntry, now time.Time) domain.Session {
+	session := domain.Session{
+		ID:        uuid.New(),
+		ProjectID: entry.ProjectID,
+		StartTime: entry.StartTime,
+		Manual:    true,
+		Tags:      normalizeTags(entry.Tags),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	switch {
+	case entry.EndTime != nil:
+		session.EndTime = *entry.EndTime
+	case entry.Duration != nil:
+		session.EndTime = entry.StartTime.Add(time.Duration(*entry.Duration) * time.Second)
+	default:
+		session.EndTime = entry.StartTime
+	}
+
+	if entry.Duration != nil {
+		session.Duration = *entry.Duration
+	} else {
+		session.Duration = int64(session.EndTime.Sub(session.StartTime) / time.Second)
+	}
+
+	if entry.Note != "" {
+		session.Records = []domain.Record{{
+			ID:        uuid.New(),
+			SessionID: session.ID,
+			Text:      entry.Note,
+			Timestamp: entry.StartTime,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}}
+	}
+	return session
+}
+
+// timesheetProject returns the user's project with the given ID, caching
+// lookups across entries. It returns nil if the project does not exist or
+// belongs to someone else.
+func (s *Server) timesheetProject(c *gin.Context, cache map[uuid.UUID]*domain.Project, projectID, userID uuid.UUID) *domain.Project {
+	if project, ok := cache[projectID]; ok {
+		return project
+	}
+
+	project, err := s.projectRepo.GetByID(c, projectID)
+	if err != nil || project.UserID != userID {
+		// GetByID does not tell missing projects from failures, so both
+		// are reported as not found
+		project = nil
+	}
+	cache[projectID] = project
+	return project
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// transcriptionQueueSize bounds the number of records waiting for a transcript
+const transcriptionQueueSize = 256
+
+// markTranscriptsPending flags records with audio for transcription before
+// they are saved, when a transcriber is configured
+func (s *Server) markTranscriptsPending(session *domain.Session) {
+	if s.transcriber == nil {
+		return
+	}
+	for i := range session.Records {
+		if len(session.Records[i].AudioData) > 0 {
+			session.Records[i].TranscriptStatus = domain.TranscriptPending
+		}
+	}
+}
+
+// queueTranscripts hands the pending records of a session to the worker
+func (s *Server) queueTranscripts(session *domain.Session) {
+	for _, record := range session.Records {
+		if record.TranscriptStatus == domain.TranscriptPending {
+			s.enqueueTranscript(record.ID)
+		}
+	}
+}
+
+func (s *Server) enqueueTranscript(recordID uuid.UUID) {
+	select {
+	case s.transcriptionQueue <- recordID:
+	default:
+		// The record stays pending and can be retried once the queue drains
+		log.Printf("Transcription queue full, skipping record %s", recordID)
+	}
+}
+
+// requeueTranscripts queues the records left pending or stuck processing
+// when the server last stopped. It waits for room in the queue rather than
+// dropping records, as there may be more than fit at once.
+func (s *Server) requeueTranscripts(ctx context.Context) {
+	ids, err := s.sessionRepo.GetPendingTranscripts(ctx, time.Now().Add(-s.transcriptStaleAfter()))
+	if err != nil {
+		log.Printf("Failed to fetch pending transcriptions: %v", err)
+		return
+	}
+	if len(ids) > 0 {
+		log.Printf("Queueing %d pending transcriptions", len(ids))
+	}
+	for _, id := range ids {
+		s.transcriptionQueue <- id
+	}
+}
+
+// transcriptStaleAfter is how long a record may be processing before it is
+// considered abandoned
+func (s *Server) transcriptStaleAfter() time.Duration {
+	return time.Duration(s.cfg.Transcription.StaleMinutes) * time.Minute
+}
+
+// runTranscriptionWorker transcribes queued records one at a time, since
+// speech models are CPU heavy
+func (s *Server) runTranscriptionWorker() {
+	for recordID := range s.transcriptionQueue {
+		if err := s.transcribeRecord(context.Background(), recordID); err != nil {
+			log.Printf("Failed to transcribe record %s: %v", recordID, err)
+		}
+	}
+}
+
+func (s *Server) transcribeRecord(ctx context.Context, recordID uuid.UUID) error {
+	record, err := s.sessionRepo.GetRecordByID(ctx, recordID)
+	if err != nil || record == nil {
+		return err
+	}
+	// Records can be queued twice, by an upload or retry and at startup
+	if record.TranscriptStatus != domain.TranscriptPending {
+		return nil
+	}
+
+	// The outcome is recorded as one revision made by the server
+	before := record.Snapshot()
+	record.TranscriptStatus = domain.TranscriptProcessing
+	record.TranscriptError = ""
+	if err := s.sessionRepo.UpdateRecordTranscript(ctx, record); err != nil {
+		return err
+	}
+
+	transcript, transcribeErr := s.transcriber.Transcribe(ctx, record.AudioData, record.AudioFormat)
+	if transcribeErr != nil {
+		record.TranscriptStatus = domain.TranscriptFailed
+		record.TranscriptError = transcribeErr.Error()
+	} else {
+		record.TranscriptStatus = domain.TranscriptCompleted
+		record.Transcript = transcript.Text
+		record.TranscriptWords = transcript.Words
+	}
+
+	err = s.transaction(ctx, func(ctx context.Context) error {
+		if err := s.sessionRepo.UpdateRecordTranscript(ctx, record); err != nil {
+			return err
+		}
+		return s.saveRevision(ctx, domain.NewRevision(domain.EntityRecord, record.ID, domain.RevisionUpdate, nil, before, record.Snapshot()))
+	})
+	if err != nil {
+		return err
+	}
+	return transcribeErr
+}
+
+// handleRetryTranscript queues a record for transcription again, typically
+// after a failure or when processing has stalled
+func (s *Server) handleRetryTranscript() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.transcriber == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Transcription is not configured"})
+			return
+		}
+
+		recordID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record ID"})
+			return
+		}
+
+		record, ok := s.authorizeRecord(c, recordID)
+		if !ok {
+			return
+		}
+
+		if len(record.AudioData) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Record has no audio"})
+			return
+		}
+		if record.TranscriptStatus == domain.TranscriptProcessing && time.Since(record.UpdatedAt) < s.transcriptStaleAfter() {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transcription already in progress"})
+			return
+		}
+
+		record.TranscriptStatus = domain.TranscriptPending
+		record.TranscriptError = ""
+		if err := s.sessionRepo.UpdateRecordTranscript(c, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update record"})
+			return
+		}
+		s.enqueueTranscript(record.ID)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"record_id":         record.ID,
+			"transcript_status": record.TranscriptStatus,
+		})
+	}
+}
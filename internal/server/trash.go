@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// handleGetTrash lists the user's deleted projects, sessions and records
+// with the time each will be purged
+func (s *Server) handleGetTrash() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		items, err := s.trashRepo.List(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+			return
+		}
+
+		retention := s.cfg.Trash.Retention()
+		if retention > 0 {
+			for i := range items {
+				purgeAt := items[i].DeletedAt.Add(retention)
+				items[i].PurgeAt = &purgeAt
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": items, "retention_days": s.cfg.Trash.RetentionDays})
+	}
+}
+
+// recordRestore saves a revision for an item brought back from the trash.
+// It returns the project whose budgets the item's time counts towards
+// again, or uuid.Nil for records.
+func (s *Server) recordRestore(ctx context.Context, item *domain.TrashItem) (uuid.UUID, error) {
+	switch item.Kind {
+	case domain.TrashProject:
+		project, err := s.projectRepo.GetByID(ctx, item.ID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		return project.ID, s.recordChange(ctx, domain.EntityProject, item.ID, domain.RevisionRestore, nil, project.Snapshot())
+	case domain.TrashSession:
+		session, err := s.sessionRepo.GetByID(ctx, item.ID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		return session.ProjectID, s.recordChange(ctx, domain.EntitySession, item.ID, domain.RevisionRestore, nil, session.Snapshot())
+	case domain.TrashRecord:
+		record, err := s.sessionRepo.GetRecordByID(ctx, item.ID)
+		if err != nil || record == nil {
+			return uuid.Nil, err
+		}
+		return uuid.Nil, s.recordChange(ctx, domain.EntityRecord, item.ID, domain.RevisionRestore, nil, record.Snapshot())
+	}
+	return uuid.Nil, nil
+}
+
+// handleRestoreTrash restores an item and the children deleted with it
+func (s *Server) handleRestoreTrash() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := c.Param("kind")
+		if !domain.ValidTrashKind(kind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be projects, sessions or records"})
+			return
+		}
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		item, err := s.trashRepo.Get(c, kind, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+			return
+		}
+		if item == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found in trash"})
+			return
+		}
+
+		if item.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+
+		if item.ParentDeleted {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":     "The parent of this item is in the trash and must be restored first",
+				"parent_id": item.ParentID,
+			})
+			return
+		}
+
+		var projectID uuid.UUID
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.trashRepo.Restore(ctx, item); err != nil {
+				return err
+			}
+			id, err := s.recordRestore(ctx, item)
+			projectID = id
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore item"})
+			return
+		}
+		if projectID != uuid.Nil {
+			s.checkBudgets(c, projectID)
+		}
+
+		c.JSON(http.StatusOK, item)
+	}
+}
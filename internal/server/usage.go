@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// sessionStorageSize returns the bytes of file and audio data held by the
+// records of a session. Files count with the size of their blob, so their
+// blobs must have been resolved.
+func sessionStorageSize(session *domain.Session) (fileBytes, audioBytes int64) {
+	for _, record := range session.Records {
+		audioBytes += int64(len(record.AudioData))
+		for _, file := range record.Files {
+			fileBytes += file.Size
+		}
+	}
+	return fileBytes, audioBytes
+}
+
+// errQuotaExceeded is returned when stored data no longer fits into the
+// user's quota
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
+// quotaBytes returns the per-user storage limit, or 0 when unlimited
+func (s *Server) quotaBytes() int64 {
+	return int64(s.cfg.Storage.QuotaMB) * 1024 * 1024
+}
+
+// checkQuota verifies that a user may store another requested bytes. An upload
+// larger than the whole quota is rejected with 413, one that merely does not
+// fit into the remaining space with 507. On failure the error response has
+// been written and false is returned.
+func (s *Server) checkQuota(c *gin.Context, userID uuid.UUID, requested int64) bool {
+	quota := s.quotaBytes()
+	if quota == 0 || requested == 0 {
+		return true
+	}
+
+	usage, err := s.usageRepo.GetByUserID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage usage"})
+		return false
+	}
+
+	details := gin.H{
+		"used_bytes":      usage.Total(),
+		"quota_bytes":     quota,
+		"requested_bytes": requested,
+	}
+	if requested > quota {
+		details["error"] = "Upload exceeds the storage quota"
+		c.JSON(http.StatusRequestEntityTooLarge, details)
+		return false
+	}
+	if usage.Total()+requested > quota {
+		details["error"] = "Storage quota exceeded"
+		c.JSON(http.StatusInsufficientStorage, details)
+		return false
+	}
+	return true
+}
+
+// addUsage counts stored bytes towards the user's usage, failing with
+// errQuotaExceeded when they do not fit. Run it in the transaction storing
+// the data, so data and usage are only committed together.
+func (s *Server) addUsage(ctx context.Context, userID uuid.UUID, fileBytes, audioBytes int64) error {
+	if fileBytes == 0 && audioBytes == 0 {
+		return nil
+	}
+	quota := s.quotaBytes()
+	if quota == 0 {
+		return s.usageRepo.Add(ctx, userID, fileBytes, audioBytes)
+	}
+	ok, err := s.usageRepo.Reserve(ctx, userID, fileBytes, audioBytes, quota)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// handleGetUsage reports how much storage the user has consumed
+func (s *Server) handleGetUsage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		usage, err := s.usageRepo.GetByUserID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch storage usage"})
+			return
+		}
+
+		response := gin.H{
+			"user_id":     userID,
+			"file_bytes":  usage.FileBytes,
+			"audio_bytes": usage.AudioBytes,
+			"total_bytes": usage.Total(),
+			"quota_bytes": nil,
+		}
+		if quota := s.quotaBytes(); quota > 0 {
+			response["quota_bytes"] = quota
+			response["remaining_bytes"] = max(quota-usage.Total(), 0)
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
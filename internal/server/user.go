@@ -2,7 +2,9 @@ package server
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/ZigaoWang/zebra-server/internal/domain"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -25,10 +27,94 @@ func (s *Server) handleGetProfile() gin.HandlerFunc {
 	}
 }
 
+type UpdateProfileRequest struct {
+	Name          *string `json:"name"`
+	OverlapPolicy *string `json:"overlap_policy"`
+	Timezone      *string `json:"timezone"`
+	HourlyRate    *int64  `json:"hourly_rate"` // minor units per hour
+	Currency      *string `json:"currency"`
+	DigestEnabled *bool   `json:"digest_enabled"`
+	DigestWeekday *int    `json:"digest_weekday"` // 1 for Monday to 7 for Sunday
+	DigestHour    *int    `json:"digest_hour"`    // 0 to 23 in the user's time zone
+}
+
 // handleUpdateProfile handles requests to update the user's profile
 func (s *Server) handleUpdateProfile() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implementation will be added later
-		c.JSON(http.StatusOK, gin.H{"message": "Update profile endpoint"})
+		var req UpdateProfileRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.OverlapPolicy != nil && !domain.ValidOverlapPolicy(*req.OverlapPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "overlap_policy must be allow, warn or reject"})
+			return
+		}
+		if req.Timezone != nil {
+			if _, ok := loadTimezone(*req.Timezone); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "timezone must be an IANA time zone such as Europe/Berlin"})
+				return
+			}
+		}
+		if req.HourlyRate != nil && *req.HourlyRate < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalidRateMessage})
+			return
+		}
+		if req.Currency != nil && !domain.ValidCurrency(*req.Currency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be an ISO 4217 code such as EUR"})
+			return
+		}
+
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+
+		user, err := s.userRepo.GetByID(c, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+			return
+		}
+
+		weekday, hour := user.DigestWeekday, user.DigestHour
+		if req.DigestWeekday != nil {
+			weekday = *req.DigestWeekday
+		}
+		if req.DigestHour != nil {
+			hour = *req.DigestHour
+		}
+		if !domain.ValidDigestSchedule(weekday, hour) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "digest_weekday must be 1 (Monday) to 7 (Sunday) and digest_hour 0 to 23"})
+			return
+		}
+
+		if req.Name != nil {
+			user.Name = *req.Name
+		}
+		if req.OverlapPolicy != nil {
+			user.OverlapPolicy = *req.OverlapPolicy
+		}
+		if req.Timezone != nil {
+			user.Timezone = *req.Timezone
+		}
+		if req.HourlyRate != nil {
+			user.HourlyRate = *req.HourlyRate
+		}
+		if req.Currency != nil {
+			user.Currency = *req.Currency
+		}
+		if req.DigestEnabled != nil {
+			user.DigestEnabled = *req.DigestEnabled
+		}
+		user.DigestWeekday, user.DigestHour = weekday, hour
+		user.UpdatedAt = time.Now()
+
+		if err := s.userRepo.Update(c, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user profile"})
+			return
+		}
+
+		// Don't return the password hash
+		user.Password = ""
+
+		c.JSON(http.StatusOK, user)
 	}
 }
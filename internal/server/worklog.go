@@ -1,18 +1,101 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"github.com/ZigaoWang/zebra-server/internal/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
 type CreateWorkLogRequest struct {
-	Title       string   `json:"title" binding:"required"`
-	Description string   `json:"description"`
-	Tags        []string `json:"tags"`
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	ProjectID   *uuid.UUID `json:"project_id"`
+	Tags        []string   `json:"tags"`
+}
+
+type UpdateWorkLogRequest struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	ProjectID   *uuid.UUID `json:"project_id"`
+	Tags        []string   `json:"tags"`
+}
+
+type ConvertWorkLogRequest struct {
+	ProjectID *uuid.UUID `json:"project_id"`
+}
+
+type CreateLogEntryRequest struct {
+	Type     string      `json:"type" binding:"required"`
+	Content  string      `json:"content"`
+	Metadata domain.JSON `json:"metadata"`
+}
+
+type UpdateLogEntryRequest struct {
+	Content  *string     `json:"content"`
+	Metadata domain.JSON `json:"metadata"`
+}
+
+// pagination reads the limit and offset query parameters, writing a 400
+// response if they are invalid
+func pagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit, offset = defaultPageSize, 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxPageSize)})
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones
+func normalizeTags(tags []string) domain.StringArray {
+	result := domain.StringArray{}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// validateLogEntry checks the entry type and that text entries have content
+func validateLogEntry(entryType, content string) string {
+	if !domain.ValidLogEntryType(entryType) {
+		return "type must be one of commit, note, voice, media"
+	}
+	if (entryType == domain.LogEntryCommit || entryType == domain.LogEntryNote) && strings.TrimSpace(content) == "" {
+		return "content is required for " + entryType + " entries"
+	}
+	return ""
 }
 
 func (s *Server) handleCreateWorkLog() gin.HandlerFunc {
@@ -23,15 +106,20 @@ func (s *Server) handleCreateWorkLog() gin.HandlerFunc {
 			return
 		}
 
+		if req.ProjectID != nil && !s.ownsProject(c, *req.ProjectID) {
+			return
+		}
+
 		userID, _ := uuid.Parse(c.GetString("user_id"))
 		workLog := &domain.WorkLog{
 			ID:          uuid.New(),
 			UserID:      userID,
+			ProjectID:   req.ProjectID,
 			Title:       req.Title,
 			Description: req.Description,
 			StartTime:   time.Now(),
-			Status:      "active",
-			Tags:        req.Tags,
+			Status:      domain.WorkLogActive,
+			Tags:        normalizeTags(req.Tags),
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
 		}
@@ -46,64 +134,431 @@ func (s *Server) handleCreateWorkLog() gin.HandlerFunc {
 	}
 }
 
+// handleGetWorkLogs lists the user's work logs a page at a time. Every
+// ?tag= given must be present on a log; the total is sent in X-Total-Count.
 func (s *Server) handleGetWorkLogs() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		limit, offset, ok := pagination(c)
+		if !ok {
+			return
+		}
+
 		userID, _ := uuid.Parse(c.GetString("user_id"))
-		workLogs, err := s.workLogRepo.GetByUserID(c, userID, 10, 0)
+		workLogs, total, err := s.workLogRepo.GetByUserID(c, userID, repository.WorkLogFilter{
+			Tags:   normalizeTags(c.QueryArray("tag")),
+			Limit:  limit,
+			Offset: offset,
+		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		now := time.Now()
+		for i := range workLogs {
+			workLogs[i].Duration = int64(workLogs[i].ActiveDuration(now) / time.Second)
+		}
+
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
 		c.JSON(http.StatusOK, workLogs)
 	}
 }
 
 func (s *Server) handleGetWorkLog() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implementation will be added later
-		c.JSON(http.StatusOK, gin.H{"message": "Get work log endpoint"})
+		workLog, ok := s.authorizeWorkLog(c)
+		if !ok {
+			return
+		}
+
+		workLog.Duration = int64(workLog.ActiveDuration(time.Now()) / time.Second)
+		c.JSON(http.StatusOK, workLog)
 	}
 }
 
 func (s *Server) handleUpdateWorkLog() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implementation will be added later
-		c.JSON(http.StatusOK, gin.H{"message": "Update work log endpoint"})
+		workLog, ok := s.authorizeWorkLog(c)
+		if !ok {
+			return
+		}
+
+		var req UpdateWorkLogRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.Title != nil {
+			if strings.TrimSpace(*req.Title) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "title cannot be empty"})
+				return
+			}
+			workLog.Title = *req.Title
+		}
+		if req.Description != nil {
+			workLog.Description = *req.Description
+		}
+		if req.ProjectID != nil {
+			if workLog.SessionID != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "Work log has already been converted into a session"})
+				return
+			}
+			if !s.ownsProject(c, *req.ProjectID) {
+				return
+			}
+			workLog.ProjectID = req.ProjectID
+		}
+		if req.Tags != nil {
+			workLog.Tags = normalizeTags(req.Tags)
+		}
+		workLog.UpdatedAt = time.Now()
+
+		if err := s.workLogRepo.Update(c, workLog); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work log"})
+			return
+		}
+
+		c.JSON(http.StatusOK, workLog)
 	}
 }
 
 func (s *Server) handleDeleteWorkLog() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implementation will be added later
-		c.JSON(http.StatusOK, gin.H{"message": "Delete work log endpoint"})
+		workLog, ok := s.authorizeWorkLog(c)
+		if !ok {
+			return
+		}
+
+		if err := s.workLogRepo.Delete(c, workLog.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete work log"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Work log deleted successfully"})
 	}
 }
 
 func (s *Server) handleCreateLogEntry() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implementation will be added later
-		c.JSON(http.StatusOK, gin.H{"message": "Create log entry endpoint"})
+		workLog, ok := s.authorizeWorkLog(c)
+		if !ok {
+			return
+		}
+
+		var req CreateLogEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if msg := validateLogEntry(req.Type, req.Content); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		entry := &domain.LogEntry{
+			ID:        uuid.New(),
+			WorkLogID: workLog.ID,
+			Type:      req.Type,
+			Content:   req.Content,
+			Metadata:  req.Metadata,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+
+		if err := s.logEntryRepo.Create(c, entry); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create log entry"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, entry)
 	}
 }
 
+// handleGetLogEntries lists a work log's entries a page at a time,
+// optionally filtered by ?type=
 func (s *Server) handleGetLogEntries() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implementation will be added later
-		c.JSON(http.StatusOK, gin.H{"message": "Get log entries endpoint"})
+		workLog, ok := s.authorizeWorkLog(c)
+		if !ok {
+			return
+		}
+
+		limit, offset, ok := pagination(c)
+		if !ok {
+			return
+		}
+
+		entryType := c.Query("type")
+		if entryType != "" && entryType != domain.LogEntryStatus && !domain.ValidLogEntryType(entryType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry type"})
+			return
+		}
+
+		entries, total, err := s.logEntryRepo.GetByWorkLogID(c, workLog.ID, entryType, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch log entries"})
+			return
+		}
+
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+		c.JSON(http.StatusOK, entries)
 	}
 }
 
 func (s *Server) handleUpdateLogEntry() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implementation will be added later
-		c.JSON(http.StatusOK, gin.H{"message": "Update log entry endpoint"})
+		entry, ok := s.authorizeLogEntry(c)
+		if !ok {
+			return
+		}
+		if entry.Type == domain.LogEntryStatus {
+			c.JSON(http.StatusConflict, gin.H{"error": "Status entries cannot be modified"})
+			return
+		}
+
+		var req UpdateLogEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.Content != nil {
+			entry.Content = *req.Content
+		}
+		if req.Metadata != nil {
+			entry.Metadata = req.Metadata
+		}
+		if msg := validateLogEntry(entry.Type, entry.Content); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		entry.UpdatedAt = time.Now()
+
+		if err := s.logEntryRepo.Update(c, entry); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update log entry"})
+			return
+		}
+
+		c.JSON(http.StatusOK, entry)
 	}
 }
 
 func (s *Server) handleDeleteLogEntry() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implementation will be added later
-		c.JSON(http.StatusOK, gin.H{"message": "Delete log entry endpoint"})
+		entry, ok := s.authorizeLogEntry(c)
+		if !ok {
+			return
+		}
+		if entry.Type == domain.LogEntryStatus {
+			c.JSON(http.StatusConflict, gin.H{"error": "Status entries cannot be modified"})
+			return
+		}
+
+		if err := s.logEntryRepo.Delete(c, entry.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete log entry"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Log entry deleted successfully"})
 	}
 }
+
+// handleWorkLogTransition moves a work log to status, recording the change
+// as a status entry. Transitions the state machine does not allow get a 409.
+func (s *Server) handleWorkLogTransition(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workLog, ok := s.authorizeWorkLog(c)
+		if !ok {
+			return
+		}
+
+		from := workLog.Status
+		now := time.Now()
+		if err := workLog.Transition(status, now); err != nil {
+			var transitionErr *domain.TransitionError
+			if errors.As(err, &transitionErr) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error": err.Error(),
+					"from":  transitionErr.From,
+					"to":    transitionErr.To,
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		entry := &domain.LogEntry{
+			ID:        uuid.New(),
+			WorkLogID: workLog.ID,
+			Type:      domain.LogEntryStatus,
+			Content:   fmt.Sprintf("Status changed from %s to %s", from, status),
+			Metadata:  domain.JSON{"from": from, "to": status},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		if err := s.workLogRepo.Transition(c, workLog, entry); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work log status"})
+			return
+		}
+
+		c.JSON(http.StatusOK, workLog)
+	}
+}
+
+// handleConvertWorkLog turns a completed work log into a session of its
+// project, its entries into records. A project_id in the body sets the
+// project for logs that have none.
+func (s *Server) handleConvertWorkLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workLog, ok := s.authorizeWorkLog(c)
+		if !ok {
+			return
+		}
+
+		var req ConvertWorkLogRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if workLog.SessionID != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Work log has already been converted into a session", "session_id": workLog.SessionID})
+			return
+		}
+		if workLog.Status != domain.WorkLogCompleted {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only completed work logs can be converted"})
+			return
+		}
+		if req.ProjectID != nil {
+			workLog.ProjectID = req.ProjectID
+		}
+		if workLog.ProjectID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id is required for work logs without a project"})
+			return
+		}
+		if !s.ownsProject(c, *workLog.ProjectID) {
+			return
+		}
+
+		entries, _, err := s.logEntryRepo.GetByWorkLogID(c, workLog.ID, "", 0, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch log entries"})
+			return
+		}
+
+		// The session is checked like any other created session
+		session := workLog.ToSession(entries)
+		if err := session.Validate(time.Now()); err != nil {
+			writeValidationError(c, err)
+			return
+		}
+		userID, _ := uuid.Parse(c.GetString("user_id"))
+		if !s.checkOverlaps(c, userID, &session) {
+			return
+		}
+
+		err = s.transaction(c, func(ctx context.Context) error {
+			if err := s.workLogRepo.ConvertToSession(ctx, workLog, &session); err != nil {
+				return err
+			}
+			return s.recordSessionCreated(ctx, &session)
+		})
+		if err != nil {
+			if errors.Is(err, repository.ErrAlreadyConverted) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Work log has already been converted into a session"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to convert work log"})
+			return
+		}
+		s.checkBudgets(c, session.ProjectID)
+
+		c.JSON(http.StatusCreated, session)
+	}
+}
+
+// ownsProject checks the project exists and belongs to the current user,
+// writing the error response if not
+func (s *Server) ownsProject(c *gin.Context, projectID uuid.UUID) bool {
+	_, ok := s.authorizeProject(c, projectID)
+	return ok
+}
+
+// authorizeProject loads a project and checks it belongs to the current
+// user, writing the error response if not
+func (s *Server) authorizeProject(c *gin.Context, projectID uuid.UUID) (*domain.Project, bool) {
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+
+	project, err := s.projectRepo.GetByID(c, projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+
+	if project.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return project, true
+}
+
+// authorizeWorkLog loads the work log named by the :id parameter and checks
+// it belongs to the current user, writing the error response if not
+func (s *Server) authorizeWorkLog(c *gin.Context) (*domain.WorkLog, bool) {
+	workLogID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work log ID"})
+		return nil, false
+	}
+
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+
+	workLog, err := s.workLogRepo.GetByID(c, workLogID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work log"})
+		return nil, false
+	}
+	if workLog == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work log not found"})
+		return nil, false
+	}
+
+	if workLog.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return workLog, true
+}
+
+// authorizeLogEntry loads the entry named by :entryId and checks it belongs
+// to the work log named by :id, which must belong to the current user
+func (s *Server) authorizeLogEntry(c *gin.Context) (*domain.LogEntry, bool) {
+	workLog, ok := s.authorizeWorkLog(c)
+	if !ok {
+		return nil, false
+	}
+
+	entryID, err := uuid.Parse(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid log entry ID"})
+		return nil, false
+	}
+
+	entry, err := s.logEntryRepo.GetByID(c, entryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch log entry"})
+		return nil, false
+	}
+	if entry == nil || entry.WorkLogID != workLog.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Log entry not found"})
+		return nil, false
+	}
+
+	return entry, true
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DatabaseStore keeps objects in the stored_objects table next to the
+// file rows, which is the default so a single Postgres holds everything
+type DatabaseStore struct {
+	db *gorm.DB
+}
+
+func NewDatabaseStore(db *gorm.DB) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+func (s *DatabaseStore) Put(ctx context.Context, key string, data []byte) error {
+	object := domain.StoredObject{
+		Key:       key,
+		Data:      data,
+		Size:      int64(len(data)),
+		CreatedAt: time.Now(),
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "size"}),
+	}).Create(&object).Error
+}
+
+func (s *DatabaseStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var object domain.StoredObject
+	if err := s.db.WithContext(ctx).First(&object, "key = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object.Data, nil
+}
+
+func (s *DatabaseStore) Delete(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Delete(&domain.StoredObject{}, "key = ?", key).Error
+}
+
+func (s *DatabaseStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := s.db.WithContext(ctx).Model(&domain.StoredObject{}).
+		Select("key, size, created_at").
+		Where("key LIKE ?", escapeLike(prefix)+"%").
+		Order("key").
+		Scan(&objects).Error
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a literal prefix
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/config"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when no object is stored under the requested key
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object without its content
+type ObjectInfo struct {
+	Key       string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// FileStore persists binary objects such as thumbnails under opaque keys
+type FileStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// New returns the file store selected by the storage configuration
+func New(cfg *config.Config, db *gorm.DB) (FileStore, error) {
+	switch cfg.Storage.Driver {
+	case "", "database":
+		return NewDatabaseStore(db), nil
+	case "local":
+		return NewLocalStore(cfg.Storage.Path)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Storage.Driver)
+	}
+}
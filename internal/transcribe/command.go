@@ -0,0 +1,143 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/audio"
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+)
+
+// CommandTranscriber runs a local speech-to-text program such as
+// whisper.cpp. Command is split on spaces; {input} is replaced with the
+// path of a 16 kHz mono WAV file and {output} with a path prefix the
+// program may write <prefix>.json to. Both the whisper.cpp JSON format
+// (-oj) and plain text on stdout are understood. For word timestamps run
+// whisper.cpp with -ml 1 so each segment holds a single word, e.g.:
+//
+//	whisper-cli -m ggml-base.en.bin -ml 1 -oj -of {output} -f {input}
+type CommandTranscriber struct {
+	Command    string
+	FFmpegPath string
+	Timeout    time.Duration
+}
+
+// whisperOutput is the subset of whisper.cpp's JSON output that is used
+type whisperOutput struct {
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"`
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+func (t *CommandTranscriber) Transcribe(ctx context.Context, data []byte, format string) (*Transcript, error) {
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+
+	dir, err := os.MkdirTemp("", "transcribe-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.wav")
+	if err := t.prepareInput(ctx, data, format, input); err != nil {
+		return nil, err
+	}
+	output := filepath.Join(dir, "output")
+
+	fields := strings.Fields(t.Command)
+	for i, f := range fields {
+		f = strings.ReplaceAll(f, "{input}", input)
+		fields[i] = strings.ReplaceAll(f, "{output}", output)
+	}
+
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("transcription command failed: %v: %s", err, lastLine(stderr.String()))
+	}
+
+	result, err := os.ReadFile(output + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		result = stdout.Bytes()
+	} else if err != nil {
+		return nil, err
+	}
+
+	return parseOutput(result), nil
+}
+
+// prepareInput writes the recording as 16 kHz mono WAV, which is what
+// whisper.cpp expects, converting other formats with ffmpeg
+func (t *CommandTranscriber) prepareInput(ctx context.Context, data []byte, format, path string) error {
+	if info, err := audio.Analyze(data); err == nil && info.Format == audio.FormatWAV &&
+		info.Codec == "pcm" && info.SampleRate == 16000 && info.Channels == 1 {
+		return os.WriteFile(path, data, 0o600)
+	}
+
+	if t.FFmpegPath == "" {
+		return fmt.Errorf("ffmpeg is required to convert %s audio", format)
+	}
+	cmd := exec.CommandContext(ctx, t.FFmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le",
+		"-y", path,
+	)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %v: %s", err, lastLine(stderr.String()))
+	}
+	return nil
+}
+
+// parseOutput reads whisper.cpp JSON output, falling back to treating the
+// output as plain text without word timestamps
+func parseOutput(result []byte) *Transcript {
+	var parsed whisperOutput
+	if err := json.Unmarshal(result, &parsed); err != nil || parsed.Transcription == nil {
+		return &Transcript{Text: strings.TrimSpace(string(result))}
+	}
+
+	transcript := &Transcript{}
+	var text []string
+	for _, segment := range parsed.Transcription {
+		word := strings.TrimSpace(segment.Text)
+		if word == "" {
+			continue
+		}
+		text = append(text, word)
+		transcript.Words = append(transcript.Words, domain.TranscriptWord{
+			Word:  word,
+			Start: segment.Offsets.From,
+			End:   segment.Offsets.To,
+		})
+	}
+	transcript.Text = strings.Join(text, " ")
+	return transcript
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,43 @@
+package transcribe
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+)
+
+// fakeVocabulary is the word list the fake transcriber draws from
+var fakeVocabulary = []string{
+	"today", "I", "worked", "on", "the", "project", "and", "fixed",
+	"a", "bug", "in", "session", "sync", "then", "reviewed", "code",
+}
+
+// FakeTranscriber produces a deterministic transcript derived from the
+// audio bytes, so the same recording always yields the same words. It is
+// meant for development and tests where no speech model is installed.
+type FakeTranscriber struct{}
+
+func (FakeTranscriber) Transcribe(ctx context.Context, data []byte, format string) (*Transcript, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no audio data")
+	}
+
+	sum := sha256.Sum256(data)
+	count := 3 + int(sum[0])%6
+
+	transcript := &Transcript{}
+	words := make([]string, count)
+	for i := 0; i < count; i++ {
+		words[i] = fakeVocabulary[int(sum[i+1])%len(fakeVocabulary)]
+		transcript.Words = append(transcript.Words, domain.TranscriptWord{
+			Word:  words[i],
+			Start: int64(i) * 500,
+			End:   int64(i)*500 + 400,
+		})
+	}
+	transcript.Text = strings.Join(words, " ")
+	return transcript, nil
+}
@@ -0,0 +1,62 @@
+package transcribe
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFakeTranscriberIsDeterministic(t *testing.T) {
+	audio := []byte("some recorded audio")
+
+	first, err := FakeTranscriber{}.Transcribe(context.Background(), audio, "webm")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	second, err := FakeTranscriber{}.Transcribe(context.Background(), audio, "webm")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("same audio gave %q and %q", first.Text, second.Text)
+	}
+
+	other, err := FakeTranscriber{}.Transcribe(context.Background(), []byte("other audio"), "webm")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if reflect.DeepEqual(first, other) {
+		t.Errorf("different audio gave the same transcript %q", first.Text)
+	}
+}
+
+func TestFakeTranscriberWords(t *testing.T) {
+	transcript, err := FakeTranscriber{}.Transcribe(context.Background(), []byte("some recorded audio"), "mp3")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+
+	if n := len(transcript.Words); n < 3 || n > 8 {
+		t.Fatalf("got %d words, want 3 to 8", n)
+	}
+	words := make([]string, len(transcript.Words))
+	for i, word := range transcript.Words {
+		words[i] = word.Word
+		if word.Start >= word.End {
+			t.Errorf("word %d ends at %d, before its start %d", i, word.End, word.Start)
+		}
+		if i > 0 && word.Start < transcript.Words[i-1].End {
+			t.Errorf("word %d starts at %d, before the previous word ends", i, word.Start)
+		}
+	}
+	if text := strings.Join(words, " "); transcript.Text != text {
+		t.Errorf("text is %q, want the words %q", transcript.Text, text)
+	}
+}
+
+func TestFakeTranscriberRejectsEmptyAudio(t *testing.T) {
+	if _, err := (FakeTranscriber{}).Transcribe(context.Background(), nil, "webm"); err == nil {
+		t.Error("expected an error for empty audio")
+	}
+}
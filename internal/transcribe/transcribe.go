@@ -0,0 +1,44 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ZigaoWang/zebra-server/internal/config"
+	"github.com/ZigaoWang/zebra-server/internal/domain"
+)
+
+// Transcript is the result of transcribing a recording
+type Transcript struct {
+	Text  string
+	Words []domain.TranscriptWord
+}
+
+// Transcriber turns recorded speech into text
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, format string) (*Transcript, error)
+}
+
+// New returns the transcriber selected by the configuration, or nil when
+// transcription is disabled
+func New(cfg *config.Config) (Transcriber, error) {
+	switch cfg.Transcription.Driver {
+	case "":
+		return nil, nil
+	case "command":
+		if strings.TrimSpace(cfg.Transcription.Command) == "" {
+			return nil, fmt.Errorf("TRANSCRIPTION_COMMAND is required for the command driver")
+		}
+		return &CommandTranscriber{
+			Command:    cfg.Transcription.Command,
+			FFmpegPath: cfg.Audio.FFmpegPath,
+			Timeout:    time.Duration(cfg.Transcription.TimeoutSeconds) * time.Second,
+		}, nil
+	case "fake":
+		return FakeTranscriber{}, nil
+	default:
+		return nil, fmt.Errorf("unknown transcription driver: %s", cfg.Transcription.Driver)
+	}
+}